@@ -399,16 +399,6 @@ func GetShopOverviewWithOpts(marketID, optsJSON *C.char) *C.char {
 
 // --- Basket ---
 
-// basketSessionResult wraps basket creation response with session info
-type basketSessionResult struct {
-	BasketID    string `json:"basketId"`
-	DeviceID    string `json:"deviceId"`
-	Version     int    `json:"version"`
-	MarketID    string `json:"marketId"`
-	ZipCode     string `json:"zipCode"`
-	ServiceType string `json:"serviceType"`
-}
-
 //export CreateBasket
 func CreateBasket(marketID, zipCode, serviceType *C.char) *C.char {
 	mid, err := requireString(marketID)
@@ -427,14 +417,8 @@ func CreateBasket(marketID, zipCode, serviceType *C.char) *C.char {
 	if err != nil {
 		return errorJSON(err)
 	}
-	return successJSON(basketSessionResult{
-		BasketID:    session.ID,
-		DeviceID:    session.DeviceID,
-		Version:     session.Version,
-		MarketID:    session.MarketID,
-		ZipCode:     session.ZipCode,
-		ServiceType: string(session.ServiceType),
-	})
+	// BasketSession marshals to {basketId, deviceId, version, marketId, zipCode, serviceType}
+	return successJSON(session)
 }
 
 // reconstructSession rebuilds a BasketSession from individual parameters
@@ -495,8 +479,8 @@ func SetBasketItemQuantity(basketID, marketID, zipCode, serviceType, listingID *
 }
 
 //export RemoveBasketItem
-func RemoveBasketItem(basketID, marketID, zipCode, serviceType, listingID *C.char) *C.char {
-	session, err := reconstructSession(basketID, marketID, zipCode, serviceType, 0)
+func RemoveBasketItem(basketID, marketID, zipCode, serviceType, listingID *C.char, version C.int) *C.char {
+	session, err := reconstructSession(basketID, marketID, zipCode, serviceType, int(version))
 	if err != nil {
 		return errorJSON(err)
 	}
@@ -511,6 +495,76 @@ func RemoveBasketItem(basketID, marketID, zipCode, serviceType, listingID *C.cha
 	return successJSON(basket)
 }
 
+// sessionResult is the basket together with the session carrying its new version
+type sessionResult struct {
+	Session *rewerse.BasketSession `json:"session"`
+	Basket  rewerse.Basket         `json:"basket"`
+}
+
+// sessionFromJSON resumes a session marshalled by CreateBasket or a previous *WithSession call
+func sessionFromJSON(sessionJSON *C.char) (*rewerse.BasketSession, error) {
+	s, err := requireString(sessionJSON)
+	if err != nil {
+		return nil, err
+	}
+	var session rewerse.BasketSession
+	if err := json.Unmarshal([]byte(s), &session); err != nil {
+		return nil, err
+	}
+	store := rewerse.NewMemorySessionStore()
+	if err := store.Save(&session); err != nil {
+		return nil, err
+	}
+	return rewerse.ResumeBasket(store)
+}
+
+//export GetBasketWithSession
+func GetBasketWithSession(sessionJSON *C.char) *C.char {
+	session, err := sessionFromJSON(sessionJSON)
+	if err != nil {
+		return errorJSON(errors.New("session: " + err.Error()))
+	}
+	basket, err := session.GetBasket()
+	if err != nil {
+		return errorJSON(err)
+	}
+	return successJSON(sessionResult{Session: session, Basket: basket})
+}
+
+//export SetBasketItemQuantityWithSession
+func SetBasketItemQuantityWithSession(sessionJSON, listingID *C.char, quantity C.int) *C.char {
+	session, err := sessionFromJSON(sessionJSON)
+	if err != nil {
+		return errorJSON(errors.New("session: " + err.Error()))
+	}
+	lid, err := requireString(listingID)
+	if err != nil {
+		return errorJSON(errors.New("listingID: " + err.Error()))
+	}
+	basket, err := session.SetItemQuantity(lid, int(quantity))
+	if err != nil {
+		return errorJSON(err)
+	}
+	return successJSON(sessionResult{Session: session, Basket: basket})
+}
+
+//export RemoveBasketItemWithSession
+func RemoveBasketItemWithSession(sessionJSON, listingID *C.char) *C.char {
+	session, err := sessionFromJSON(sessionJSON)
+	if err != nil {
+		return errorJSON(errors.New("session: " + err.Error()))
+	}
+	lid, err := requireString(listingID)
+	if err != nil {
+		return errorJSON(errors.New("listingID: " + err.Error()))
+	}
+	basket, err := session.RemoveItem(lid)
+	if err != nil {
+		return errorJSON(err)
+	}
+	return successJSON(sessionResult{Session: session, Basket: basket})
+}
+
 // --- Delivery ---
 
 //export GetBulkyGoodsConfig
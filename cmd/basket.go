@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
+)
+
+const defaultStateFile = "basket-session.json"
+
+func handleBasket(args []string) (any, error) {
+	if wantsHelp(args) {
+		basketHelp()
+		return nil, nil
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("basket create", flag.ContinueOnError)
+		market := fs.String("market", "", "Market ID")
+		zip := fs.String("zip", "", "Zip code")
+		service := fs.String("service", "PICKUP", "Service type: PICKUP or DELIVERY")
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if err := validateNumeric("market", *market); err != nil {
+			return nil, err
+		}
+		if err := validateZipCode(*zip); err != nil {
+			return nil, err
+		}
+
+		session, err := rewerse.CreateBasket(*market, *zip, rewerse.ServiceType(*service))
+		if err != nil {
+			return nil, err
+		}
+		basket, err := session.GetBasket()
+		if err != nil {
+			return nil, err
+		}
+		if err := rewerse.NewFileSessionStore(*state).Save(session); err != nil {
+			return nil, err
+		}
+		return basket, nil
+
+	case "show":
+		fs := flag.NewFlagSet("basket show", flag.ContinueOnError)
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		return withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return s.GetBasket()
+		})
+
+	case "check":
+		fs := flag.NewFlagSet("basket check", flag.ContinueOnError)
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		return withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return s.Report()
+		})
+
+	case "set":
+		fs := flag.NewFlagSet("basket set", flag.ContinueOnError)
+		listing := fs.String("listing", "", "Listing ID")
+		qty := fs.Int("qty", 1, "Quantity (0 removes the item)")
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if err := validateFlag("listing", *listing); err != nil {
+			return nil, err
+		}
+		return withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return s.SetItemQuantity(*listing, *qty)
+		})
+
+	case "remove":
+		fs := flag.NewFlagSet("basket remove", flag.ContinueOnError)
+		listing := fs.String("listing", "", "Listing ID")
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if err := validateFlag("listing", *listing); err != nil {
+			return nil, err
+		}
+		return withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return s.RemoveItem(*listing)
+		})
+
+	case "clear":
+		fs := flag.NewFlagSet("basket clear", flag.ContinueOnError)
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		return withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return s.Clear()
+		})
+
+	case "import":
+		fs := flag.NewFlagSet("basket import", flag.ContinueOnError)
+		file := fs.String("file", "", "Shopping list file (- for stdin)")
+		state := fs.String("state", defaultStateFile, "Session state file")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if err := validateFlag("file", *file); err != nil {
+			return nil, err
+		}
+
+		var text []byte
+		var err error
+		if *file == "-" {
+			text, err = io.ReadAll(os.Stdin)
+		} else {
+			text, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return s.ImportShoppingList(string(text))
+		})
+
+	default:
+		basketHelp()
+		return nil, fmt.Errorf("unknown basket subcommand: %s", args[0])
+	}
+}
+
+// withBasketSession resumes the session from the state file, runs fn and saves the
+// updated session (fn usually bumps the version) back to the state file.
+func withBasketSession(stateFile string, fn func(s *rewerse.BasketSession) (any, error)) (any, error) {
+	store := rewerse.NewFileSessionStore(stateFile)
+	session, err := rewerse.ResumeBasket(store)
+	if errors.Is(err, rewerse.ErrNoSession) {
+		return nil, fmt.Errorf("no active basket in %s (use 'basket create' first)", stateFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fn(session)
+	if saveErr := store.Save(session); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func basketHelp() {
+	fmt.Printf(`Usage: %s basket <subcommand> [flags]
+
+Subcommands:
+  create      Create a new basket and make it the active session
+  show        Show the active basket
+  check       Check violations, fees and minimum order values
+  set         Set the quantity of an item
+  remove      Remove an item
+  clear       Remove all items
+  import      Fill the basket from a shopping list
+
+All subcommands accept:
+  -state      Session state file (default: %s)
+
+basket create:
+  -market     Market ID (required)
+  -zip        Zip code (required, 5 digits)
+  -service    PICKUP or DELIVERY (default: PICKUP)
+
+basket set:
+  -listing    Listing ID (required, from product search results)
+  -qty        Quantity (default: 1, 0 removes the item)
+
+basket remove:
+  -listing    Listing ID (required)
+
+basket import:
+  -file       Shopping list file, plain text or CSV (required, - for stdin)
+              One item per line or comma-separated: "2x Milch, Bananen 1kg"
+
+Examples:
+  %s basket create -market 831002 -zip 67065
+  %s basket set -listing "8-FP05LLPR-rewe-online-services|48465001-320516" -qty 2
+  %s basket show
+  %s basket check
+  %s basket import -file einkauf.txt
+  %s basket clear
+`, binaryName, defaultStateFile, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+}
@@ -50,6 +50,9 @@ func main() {
 	switch flag.Arg(0) {
 	case "markets":
 		data, err = handleMarkets(flag.Args()[1:])
+		if data == nil && err == nil {
+			return // help or export displayed
+		}
 	case "products":
 		data, err = handleProducts(flag.Args()[1:])
 	case "recipes":
@@ -61,13 +64,34 @@ func main() {
 		data, err = handleDiscounts(flag.Args()[1:])
 	case "categories":
 		data, err = handleCategories(flag.Args()[1:], *jsonOutput)
-		if data == nil {
+		if data == nil && err == nil {
 			return // already printed
 		}
 	case "recalls":
-		data, err = rewerse.GetRecalls()
+		data, err = handleRecalls(flag.Args()[1:])
+		if data == nil && err == nil {
+			return // help displayed
+		}
 	case "services":
 		data, err = handleServices(flag.Args()[1:])
+		if data == nil && err == nil {
+			return // help or export displayed
+		}
+	case "basket":
+		data, err = handleBasket(flag.Args()[1:])
+		if data == nil && err == nil {
+			return // help displayed
+		}
+	case "plan":
+		data, err = handlePlan(flag.Args()[1:])
+		if data == nil && err == nil {
+			return // help displayed
+		}
+	case "compare":
+		data, err = handleCompare(flag.Args()[1:], *jsonOutput)
+		if data == nil && err == nil {
+			return // help displayed
+		}
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", flag.Arg(0))
 		mainHelp()
@@ -105,18 +129,27 @@ Commands:
   recipes         Search and browse recipes
   discounts       Get market discounts
   categories      Get product categories
-  recalls         Get product recalls
-  services        Get service portfolio by zip
+  recalls         Get and watch product recalls
+  services        Get service portfolio by zip, scan coverage
+  basket          Create and manage a basket session
+  plan            Plan meals around current discounts
+  compare         Compare prices across markets
 
 Examples:
   %s markets search -query Köln
   %s products search -market 831002 -query Milch
   %s products category -market 831002 -slug obst-gemuese
+  %s products lookup -ean 4001686301265
   %s recipes search -term Pasta
   %s discounts -market 840174
   %s categories -market 831002
+  %s categories -market 831002 -find obst
   %s services -zip 50667
+  %s services scan -from 50000 -to 51999 -out koeln.csv
+  %s basket create -market 831002 -zip 67065
+  %s plan -market 840174 -days 5
+  %s compare -query Hafermilch -near 50667 -radius 10
 
 Run '%s <command>' for subcommand help.
-`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
+)
+
+func handleCompare(args []string, jsonOutput bool) (any, error) {
+	if wantsHelp(args) {
+		compareHelp()
+		return nil, nil
+	}
+
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	query := fs.String("query", "", "Search query")
+	product := fs.String("product", "", "Product ID (instead of -query)")
+	near := fs.String("near", "", "Zip code to pick markets around")
+	radius := fs.Float64("radius", 5, "Radius around -near in km")
+	maxMarkets := fs.Int("max", 5, "Maximum number of markets")
+	marketList := fs.String("markets", "", "Comma-separated market IDs (instead of -near)")
+	service := fs.String("service", "", "Service type: PICKUP or DELIVERY")
+	candidates := fs.Int("candidates", 0, "Search results per market (with -query)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedArgs(fs); err != nil {
+		return nil, err
+	}
+	if (*query == "") == (*product == "") {
+		return nil, fmt.Errorf("either -query or -product is required")
+	}
+
+	marketIDs := splitList(*marketList)
+	if len(marketIDs) == 0 {
+		if err := validateZipCode(*near); err != nil {
+			return nil, fmt.Errorf("-markets or -near is required: %w", err)
+		}
+		markets, err := rewerse.MarketsNearZip(*near, *radius)
+		if err != nil {
+			return nil, err
+		}
+		if *maxMarkets > 0 && len(markets) > *maxMarkets {
+			markets = markets[:*maxMarkets]
+		}
+		if !jsonOutput {
+			fmt.Print(markets.String())
+			fmt.Println()
+		}
+		for _, m := range markets {
+			marketIDs = append(marketIDs, m.WWIdent)
+		}
+	}
+	for _, id := range marketIDs {
+		if err := validateNumeric("markets", id); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &rewerse.CompareOpts{
+		ServiceType: rewerse.ServiceType(*service),
+		Candidates:  *candidates,
+	}
+	if *product != "" {
+		return rewerse.ComparePrices(*product, marketIDs, opts)
+	}
+	return rewerse.ComparePricesByQuery(*query, marketIDs, opts)
+}
+
+func compareHelp() {
+	fmt.Printf(`Usage: %s compare [flags]
+
+Compares the prices of a product or search query across several markets.
+
+Flags:
+  -query      Search query (or -product)
+  -product    Product ID (or -query)
+  -near       Zip code to pick markets around (or -markets)
+  -radius     Radius around -near in km (default: 5)
+  -max        Maximum number of markets (default: 5)
+  -markets    Comma-separated market IDs (or -near)
+  -service    PICKUP or DELIVERY (default: PICKUP, must match all markets)
+  -candidates Search results per market with -query (default: 10)
+
+Examples:
+  %s compare -query Hafermilch -near 50667 -radius 10
+  %s compare -product 7535400 -markets 831002,840174
+`, binaryName, binaryName, binaryName)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 
 	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
@@ -49,10 +50,18 @@ func handleCategories(args []string, jsonOutput bool) (any, error) {
 		return nil, nil
 	}
 
+	if args[0] == "diff" {
+		return handleCategoriesDiff(args[1:])
+	}
+
 	fs := flag.NewFlagSet("categories", flag.ContinueOnError)
 	market := fs.String("market", "", "Market ID")
 	service := fs.String("service", "", "Service type: PICKUP or DELIVERY")
 	all := fs.Bool("all", false, "Print all categories")
+	find := fs.String("find", "", "Find categories by name or slug")
+	leaves := fs.Bool("leaves", false, "List the categories without subcategories")
+	format := fs.String("format", "", "Export as json, csv or dot")
+	out := fs.String("out", "", "Output file for -format (default: stdout)")
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
@@ -63,16 +72,33 @@ func handleCategories(args []string, jsonOutput bool) (any, error) {
 	if err := validateNumeric("market", *market); err != nil {
 		return nil, err
 	}
-
-	var opts *rewerse.ShopOverviewOpts
-	if *service != "" {
-		opts = &rewerse.ShopOverviewOpts{ServiceType: rewerse.ServiceType(*service)}
+	var exportFormat rewerse.CategoryFormat
+	if *format != "" {
+		f, err := rewerse.ParseCategoryFormat(*format)
+		if err != nil {
+			return nil, err
+		}
+		exportFormat = f
+	} else if *out != "" {
+		return nil, fmt.Errorf("-out requires -format")
 	}
-	so, err := rewerse.GetShopOverviewWithOpts(*market, opts)
+
+	so, err := getShopOverview(*market, *service)
 	if err != nil {
 		return nil, err
 	}
 
+	switch {
+	case exportFormat != "":
+		return nil, writeExport(*out, func(w io.Writer) error {
+			return rewerse.ExportCategories(w, so, exportFormat)
+		})
+	case *find != "":
+		return so.Search(*find), nil
+	case *leaves:
+		return so.Leaves(), nil
+	}
+
 	if *all && jsonOutput {
 		fmt.Fprintln(os.Stderr, "Cannot use -all with -json (json includes all by default)")
 		return nil, nil
@@ -93,9 +119,13 @@ func handleServices(args []string) (any, error) {
 		servicesHelp()
 		return nil, nil
 	}
+	if args[0] == "scan" {
+		return handleServicesScan(args[1:])
+	}
 
 	fs := flag.NewFlagSet("services", flag.ContinueOnError)
 	zip := fs.String("zip", "", "Zip code")
+	export := mapExportFlags(fs)
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
@@ -106,7 +136,16 @@ func handleServices(args []string) (any, error) {
 	if err := validateZipCode(*zip); err != nil {
 		return nil, err
 	}
-	return rewerse.GetServicePortfolio(*zip)
+	if err := export.validate(); err != nil {
+		return nil, err
+	}
+	portfolio, err := rewerse.GetServicePortfolio(*zip)
+	if err != nil || export.parsed == "" {
+		return portfolio, err
+	}
+	return nil, writeExport(export.out, func(w io.Writer) error {
+		return rewerse.ExportServicePortfolio(w, portfolio, export.parsed)
+	})
 }
 
 func discountsHelp() {
@@ -126,11 +165,22 @@ Examples:
 
 func categoriesHelp() {
 	fmt.Printf(`Usage: %s categories [flags]
+       %s categories diff [flags]
 
 Flags:
   -market     Market ID (required)
   -service    PICKUP or DELIVERY (default: PICKUP, must match market capabilities)
   -all        Print all categories with subcategories
+  -find       Find categories whose name or slug contains the text, with their path
+  -leaves     List the categories without subcategories
+  -format     Export the tree: json, csv or dot (Graphviz)
+  -out        Output file for -format (default: stdout)
+
+categories diff (compare two exports, e.g. from different dates):
+  -old        Earlier export from -format json (required)
+  -new        Later export from -format json
+  -market     Compare with the current categories of this market instead
+  -service    Service type for -market
 
 Note: Use 'markets details -id <market>' to check hasPickup field for service support.
 
@@ -138,16 +188,92 @@ Examples:
   %s categories -market 831002
   %s categories -market 320509 -service DELIVERY
   %s categories -market 831002 -all
-`, binaryName, binaryName, binaryName, binaryName)
+  %s categories -market 831002 -find obst
+  %s categories -market 831002 -format json -out categories-2025-05.json
+  %s categories -market 831002 -format dot -out categories.dot
+  %s categories diff -old categories-2025-05.json -market 831002
+`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+}
+
+func handleCategoriesDiff(args []string) (any, error) {
+	fs := flag.NewFlagSet("categories diff", flag.ContinueOnError)
+	oldFile := fs.String("old", "", "Earlier category export (JSON)")
+	newFile := fs.String("new", "", "Later category export (JSON)")
+	market := fs.String("market", "", "Compare with the current categories of this market instead of -new")
+	service := fs.String("service", "", "Service type: PICKUP or DELIVERY")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedArgs(fs); err != nil {
+		return nil, err
+	}
+	if err := validateFlag("old", *oldFile); err != nil {
+		return nil, err
+	}
+	if (*newFile == "") == (*market == "") {
+		return nil, fmt.Errorf("either -new or -market is required")
+	}
+
+	old, err := readCategories(*oldFile)
+	if err != nil {
+		return nil, err
+	}
+	var current rewerse.ShopOverview
+	if *newFile != "" {
+		current, err = readCategories(*newFile)
+	} else if err = validateNumeric("market", *market); err == nil {
+		current, err = getShopOverview(*market, *service)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rewerse.DiffCategories(old, current), nil
+}
+
+func getShopOverview(market, service string) (rewerse.ShopOverview, error) {
+	var opts *rewerse.ShopOverviewOpts
+	if service != "" {
+		opts = &rewerse.ShopOverviewOpts{ServiceType: rewerse.ServiceType(service)}
+	}
+	return rewerse.GetShopOverviewWithOpts(market, opts)
+}
+
+func readCategories(path string) (rewerse.ShopOverview, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return rewerse.ShopOverview{}, err
+	}
+	defer f.Close()
+	so, err := rewerse.ReadCategories(f)
+	if err != nil {
+		return so, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return so, nil
 }
 
 func servicesHelp() {
 	fmt.Printf(`Usage: %s services [flags]
+       %s services scan [flags]
 
 Flags:
   -zip        Zip code (required, 5 digits)
+  -format     Export pickup markets as map: geojson or kml
+  -out        Output file for -format (default: stdout)
+
+services scan (delivery/pickup coverage of many zip codes):
+  -from       First zip code of the range
+  -to         Last zip code of the range
+  -zips       Comma-separated zip codes (instead of -from/-to)
+  -resume     Progress file; finished zip codes are skipped, failed ones retried
+  -format     Dataset format: csv or json (default: from -out, else summary)
+  -out        Output file (default: stdout)
+  -workers    Concurrent requests (default: 4)
+  -interval   Minimum time between requests (default: 250ms)
 
 Examples:
   %s services -zip 50667
-`, binaryName, binaryName)
+  %s services -zip 50667 -format kml -out abholung.kml
+  %s services scan -from 50000 -to 51999 -resume koeln.jsonl -out koeln.csv
+  %s services scan -zips 50667,68199 -format json
+`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
 }
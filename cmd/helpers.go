@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
 	"strings"
 
@@ -75,3 +77,39 @@ func checkUnexpectedArgs(fs *flag.FlagSet) error {
 	}
 	return nil
 }
+
+// writeExport runs write against the output file, or stdout if path is empty
+func writeExport(path string, write func(w io.Writer) error) (err error) {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	defer rewerse.CloseWithWrap(f, &err)
+	return write(f)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// stringList is a repeatable string flag: -id A -id B
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
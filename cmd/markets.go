@@ -1,8 +1,14 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
 )
@@ -17,6 +23,10 @@ func handleMarkets(args []string) (any, error) {
 	case "search":
 		fs := flag.NewFlagSet("markets search", flag.ContinueOnError)
 		query := fs.String("query", "", "Search query (city, zip, street)")
+		filter := marketFilterFlags(fs)
+		services := fs.String("services", "", "Only markets offering these services: fischtheke,parking")
+		export := mapExportFlags(fs)
+		openAt := fs.String("open-at", "", "Only markets open at this day and time: \"Sa 21:30\"")
 		if err := fs.Parse(args[1:]); err != nil {
 			return nil, err
 		}
@@ -26,7 +36,66 @@ func handleMarkets(args []string) (any, error) {
 		if err := validateFlag("query", *query); err != nil {
 			return nil, err
 		}
-		return rewerse.MarketSearch(*query)
+		if err := export.validate(); err != nil {
+			return nil, err
+		}
+		storeServices, err := rewerse.ParseStoreServices(*services)
+		if err != nil {
+			return nil, err
+		}
+		if *openAt != "" {
+			t, err := rewerse.NextWeekdayTime(*openAt, time.Now())
+			if err != nil {
+				return nil, fmt.Errorf("-open-at: %w", err)
+			}
+			filter.OpenAt = t
+		}
+		markets, err := rewerse.MarketSearch(*query)
+		if err != nil {
+			return nil, err
+		}
+		markets, err = rewerse.FilterByServices(markets.Filter(*filter), storeServices, nil)
+		if err != nil {
+			return nil, err
+		}
+		return export.write(markets)
+
+	case "near":
+		fs := flag.NewFlagSet("markets near", flag.ContinueOnError)
+		lat := fs.Float64("lat", 0, "Latitude")
+		lon := fs.Float64("lon", 0, "Longitude")
+		radius := fs.Float64("radius", 5, "Radius in km")
+		filter := marketFilterFlags(fs)
+		services := fs.String("services", "", "Only markets offering these services: fischtheke,parking")
+		export := mapExportFlags(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if *lat == 0 && *lon == 0 {
+			return nil, fmt.Errorf("-lat and -lon are required")
+		}
+		if err := export.validate(); err != nil {
+			return nil, err
+		}
+		storeServices, err := rewerse.ParseStoreServices(*services)
+		if err != nil {
+			return nil, err
+		}
+		markets, err := rewerse.MarketSearchNear(*lat, *lon, *radius)
+		if err != nil {
+			return nil, err
+		}
+		markets, err = rewerse.FilterByServices(markets.Filter(*filter), storeServices, nil)
+		if err != nil {
+			return nil, err
+		}
+		return export.write(markets)
+
+	case "crawl":
+		return handleMarketsCrawl(args[1:])
 
 	case "details":
 		fs := flag.NewFlagSet("markets details", flag.ContinueOnError)
@@ -53,17 +122,191 @@ func marketsHelp() {
 
 Subcommands:
   search      Search for markets
+  near        Find markets around a coordinate
+  crawl       Build a directory of all markets
   details     Get market details
 
 markets search:
   -query      Search query (city, zip code, street)
+  -type       Market type: REWE or CENTER
+  -pickup     Only markets offering pickup
+  -open       Only markets that are open now
+  -open-at    Only markets open at a day and time, per their opening hours
+              and public holidays: "Sa 21:30"
+  -services   Only markets offering all of these services (loads the details
+              of every market): fischtheke,parking,wlan
+  -format     Export as map: geojson or kml (default: list)
+  -out        Output file for -format (default: stdout)
+
+markets near:
+  -lat        Latitude (required)
+  -lon        Longitude (required)
+  -radius     Radius in km (default: 5)
+  Note: it's unverified that the API searches around the coordinate;
+  markets it doesn't return are missing from the result.
+  -type, -pickup, -open, -services, -format, -out as for search
+
+markets crawl:
+  -out        Directory file (required); the previous version is compared and
+              kept as <name>.v<version>.jsonl
+  -seeds      Comma-separated search queries (default: large cities and
+              zip prefixes 01-99)
+  -zip-digits Search all zip prefixes of this length instead (1-4)
+  -details    Enrich markets with their details (default: true)
+  -workers    Concurrent requests (default: 4)
+  -interval   Minimum time between requests (default: 250ms)
 
 markets details:
   -id         Market ID
 
 Examples:
   %s markets search -query Mannheim
-  %s markets search -query 68199
+  %s markets search -query 68199 -pickup
+  %s markets search -query Köln -open-at "Sa 21:30"
+  %s markets search -query Köln -format geojson -out koeln.geojson
+  %s markets near -lat 50.9413 -lon 6.9583 -radius 3 -open
+  %s markets near -lat 50.9413 -lon 6.9583 -services fischtheke,parking
+  %s markets crawl -out markets.jsonl
   %s markets details -id 840174
-`, binaryName, binaryName, binaryName, binaryName)
+`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+}
+
+// marketFilterFlags registers -type, -pickup and -open
+func marketFilterFlags(fs *flag.FlagSet) *rewerse.MarketFilter {
+	f := &rewerse.MarketFilter{}
+	fs.StringVar(&f.TypeID, "type", "", "Market type: REWE or CENTER")
+	fs.BoolVar(&f.HasPickup, "pickup", false, "Only markets offering pickup")
+	fs.BoolVar(&f.Open, "open", false, "Only markets that are open now")
+	return f
+}
+
+// mapExport holds the -format and -out flags of market listings
+type mapExport struct {
+	format string
+	out    string
+	parsed rewerse.MapFormat
+}
+
+// mapExportFlags registers -format and -out
+func mapExportFlags(fs *flag.FlagSet) *mapExport {
+	e := &mapExport{}
+	fs.StringVar(&e.format, "format", "", "Export as map: geojson or kml")
+	fs.StringVar(&e.out, "out", "", "Output file for -format (default: stdout)")
+	return e
+}
+
+func (e *mapExport) validate() (err error) {
+	if e.format == "" {
+		if e.out != "" {
+			return fmt.Errorf("-out requires -format")
+		}
+		return nil
+	}
+	e.parsed, err = rewerse.ParseMapFormat(e.format)
+	return err
+}
+
+// write exports the markets if -format is set, otherwise returns them for printing
+func (e *mapExport) write(markets rewerse.Markets) (any, error) {
+	if e.parsed == "" {
+		return markets, nil
+	}
+	return nil, writeExport(e.out, func(w io.Writer) error {
+		return rewerse.ExportMarkets(w, markets, e.parsed)
+	})
+}
+
+func handleMarketsCrawl(args []string) (any, error) {
+	fs := flag.NewFlagSet("markets crawl", flag.ContinueOnError)
+	out := fs.String("out", "", "Directory file")
+	seeds := fs.String("seeds", "", "Comma-separated search queries")
+	zipDigits := fs.Int("zip-digits", 0, "Search all zip prefixes of this length")
+	details := fs.Bool("details", true, "Enrich markets with their details")
+	workers := fs.Int("workers", rewerse.DefaultRateLimit.Workers, "Concurrent requests")
+	interval := fs.Duration("interval", rewerse.DefaultRateLimit.Interval, "Minimum time between requests")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedArgs(fs); err != nil {
+		return nil, err
+	}
+	if err := validateFlag("out", *out); err != nil {
+		return nil, err
+	}
+	if *workers <= 0 {
+		return nil, fmt.Errorf("-workers must be positive")
+	}
+
+	opts := &rewerse.CrawlOpts{
+		Seeds:     splitList(*seeds),
+		Details:   *details,
+		RateLimit: &rewerse.RateLimit{Workers: *workers, Interval: *interval},
+		Progress: func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\r%d/%d", done, total)
+		},
+	}
+	if *zipDigits != 0 {
+		if len(opts.Seeds) > 0 {
+			return nil, fmt.Errorf("use either -seeds or -zip-digits")
+		}
+		prefixes, err := rewerse.ZipPrefixSeeds(*zipDigits)
+		if err != nil {
+			return nil, err
+		}
+		opts.Seeds = prefixes
+	}
+
+	previous, err := readMarketDirectory(*out)
+	if err != nil {
+		return nil, err
+	}
+	opts.Previous = previous
+
+	dir, err := rewerse.CrawlMarkets(opts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	// write the new directory before archiving the old one, so a failed write keeps it in place
+	tmp := *out + ".tmp"
+	if err := writeExport(tmp, dir.WriteJSONLines); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if previous != nil {
+		ext := filepath.Ext(*out)
+		archive := fmt.Sprintf("%s.v%d%s", strings.TrimSuffix(*out, ext), previous.Version, ext)
+		if err := os.Rename(*out, archive); err != nil {
+			os.Remove(tmp)
+			return nil, fmt.Errorf("error archiving previous directory: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		return nil, fmt.Errorf("error writing %s: %w", *out, err)
+	}
+
+	fmt.Fprint(os.Stderr, dir)
+	if previous == nil {
+		return nil, nil
+	}
+	return dir.Diff(*previous), nil
+}
+
+// readMarketDirectory loads the directory at path, or nil if it doesn't exist yet
+func readMarketDirectory(path string) (*rewerse.MarketDirectory, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, err := rewerse.ReadMarketDirectory(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return &dir, nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
+)
+
+func handlePlan(args []string) (any, error) {
+	if wantsHelp(args) {
+		planHelp()
+		return nil, nil
+	}
+
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	market := fs.String("market", "", "Market ID")
+	days := fs.Int("days", 5, "Number of days to plan")
+	portions := fs.Int("portions", 0, "Portions per meal")
+	term := fs.String("term", "", "Recipe search term")
+	collection := fs.String("collection", "", "Collection filter (Vegetarisch, Vegan)")
+	difficulty := fs.String("difficulty", "", "Difficulty (Gering, Mittel, Hoch)")
+	candidates := fs.Int("candidates", 0, "Number of recipes to evaluate")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedArgs(fs); err != nil {
+		return nil, err
+	}
+	if err := validateNumeric("market", *market); err != nil {
+		return nil, err
+	}
+	if *days <= 0 {
+		return nil, fmt.Errorf("-days must be positive (got %d)", *days)
+	}
+
+	return rewerse.PlanMeals(*market, &rewerse.MealPlanOpts{
+		Days:       *days,
+		Portions:   *portions,
+		Candidates: *candidates,
+		Search: &rewerse.RecipeSearchOpts{
+			SearchTerm: *term,
+			Collection: rewerse.RecipeCollection(*collection),
+			Difficulty: rewerse.RecipeDifficulty(*difficulty),
+		},
+	})
+}
+
+func planHelp() {
+	fmt.Printf(`Usage: %s plan [flags]
+
+Plans meals around the current discounts of a market and prints a combined shopping list.
+
+Flags:
+  -market     Market ID (required)
+  -days       Number of days to plan (default: 5)
+  -portions   Portions per meal (default: as in the recipe)
+  -term       Recipe search term
+  -collection Collection filter (e.g. Vegetarisch)
+  -difficulty Difficulty (Gering, Mittel, Hoch)
+  -candidates Number of recipes to evaluate (default: 3 per day, max 30)
+
+Examples:
+  %s plan -market 840174 -days 5
+  %s plan -market 840174 -days 3 -portions 4 -collection Vegetarisch
+`, binaryName, binaryName, binaryName)
+}
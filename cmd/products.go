@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
 )
@@ -21,6 +22,12 @@ func handleProducts(args []string) (any, error) {
 		service := fs.String("service", "", "Service type: PICKUP or DELIVERY")
 		page := fs.Int("page", 0, "Page number")
 		perPage := fs.Int("perPage", 0, "Results per page")
+		only := fs.String("only", "", "Only products with these attributes (e.g. vegan,glutenfree)")
+		noRestricted := fs.Bool("no-restricted", false, "Exclude age-restricted and biocidal products")
+		sortBy := fs.String("sort", "", "Sort order: unitprice")
+		byCategory := fs.Bool("by-category", false, "Group the products by category")
+		facets := fs.Bool("facets", false, "Count the products per category")
+		depth := fs.Int("depth", 2, "Category levels for -by-category and -facets (0 = all)")
 		if err := fs.Parse(args[1:]); err != nil {
 			return nil, err
 		}
@@ -33,7 +40,32 @@ func handleProducts(args []string) (any, error) {
 		if err := validateFlag("query", *query); err != nil {
 			return nil, err
 		}
-		return rewerse.GetProducts(*market, *query, buildOpts(*page, *perPage, *service))
+		if err := validateFilterPage(*page, *only, *noRestricted); err != nil {
+			return nil, err
+		}
+		if err := validateProductSort(*sortBy); err != nil {
+			return nil, err
+		}
+		if err := validateCategoryView(*sortBy, *byCategory, *facets); err != nil {
+			return nil, err
+		}
+
+		var results rewerse.ProductResults
+		var err error
+		if *only != "" || *noRestricted {
+			opts := &rewerse.ProductOpts{ObjectsPerPage: *perPage, ServiceType: rewerse.ServiceType(*service)}
+			pred, perr := productPredicate(*only, *noRestricted, opts)
+			if perr != nil {
+				return nil, perr
+			}
+			results, err = collectFiltered(rewerse.IterateProducts(*market, *query, opts).Where(pred), *query, *perPage)
+		} else {
+			results, err = rewerse.GetProducts(*market, *query, buildOpts(*page, *perPage, *service))
+		}
+		if *byCategory || *facets {
+			return categorizeProducts(*market, *service, results, *facets, *depth, err)
+		}
+		return sortProducts(results, *sortBy, err)
 
 	case "category":
 		fs := flag.NewFlagSet("products category", flag.ContinueOnError)
@@ -42,6 +74,12 @@ func handleProducts(args []string) (any, error) {
 		service := fs.String("service", "", "Service type: PICKUP or DELIVERY")
 		page := fs.Int("page", 0, "Page number")
 		perPage := fs.Int("perPage", 0, "Results per page")
+		only := fs.String("only", "", "Only products with these attributes (e.g. vegan,glutenfree)")
+		noRestricted := fs.Bool("no-restricted", false, "Exclude age-restricted and biocidal products")
+		sortBy := fs.String("sort", "", "Sort order: unitprice")
+		byCategory := fs.Bool("by-category", false, "Group the products by category")
+		facets := fs.Bool("facets", false, "Count the products per category")
+		depth := fs.Int("depth", 2, "Category levels for -by-category and -facets (0 = all)")
 		if err := fs.Parse(args[1:]); err != nil {
 			return nil, err
 		}
@@ -54,7 +92,32 @@ func handleProducts(args []string) (any, error) {
 		if err := validateFlag("slug", *slug); err != nil {
 			return nil, err
 		}
-		return rewerse.GetCategoryProducts(*market, *slug, buildOpts(*page, *perPage, *service))
+		if err := validateFilterPage(*page, *only, *noRestricted); err != nil {
+			return nil, err
+		}
+		if err := validateProductSort(*sortBy); err != nil {
+			return nil, err
+		}
+		if err := validateCategoryView(*sortBy, *byCategory, *facets); err != nil {
+			return nil, err
+		}
+
+		var results rewerse.ProductResults
+		var err error
+		if *only != "" || *noRestricted {
+			opts := &rewerse.ProductOpts{ObjectsPerPage: *perPage, ServiceType: rewerse.ServiceType(*service)}
+			pred, perr := productPredicate(*only, *noRestricted, opts)
+			if perr != nil {
+				return nil, perr
+			}
+			results, err = collectFiltered(rewerse.IterateCategoryProducts(*market, *slug, opts).Where(pred), *slug, *perPage)
+		} else {
+			results, err = rewerse.GetCategoryProducts(*market, *slug, buildOpts(*page, *perPage, *service))
+		}
+		if *byCategory || *facets {
+			return categorizeProducts(*market, *service, results, *facets, *depth, err)
+		}
+		return sortProducts(results, *sortBy, err)
 
 	case "details":
 		fs := flag.NewFlagSet("products details", flag.ContinueOnError)
@@ -74,6 +137,9 @@ func handleProducts(args []string) (any, error) {
 		}
 		return rewerse.GetProductByID(*market, *product)
 
+	case "lookup":
+		return handleProductsLookup(args[1:])
+
 	case "suggest":
 		fs := flag.NewFlagSet("products suggest", flag.ContinueOnError)
 		query := fs.String("query", "", "Search query")
@@ -108,6 +174,46 @@ func handleProducts(args []string) (any, error) {
 		}
 		return rewerse.GetProductRecommendations(*market, *listing)
 
+	case "compare":
+		fs := flag.NewFlagSet("products compare", flag.ContinueOnError)
+		market := fs.String("market", "", "Market ID")
+		var ids stringList
+		fs.Var(&ids, "id", "Product ID (repeat for each product)")
+		state := fs.String("state", "", "Preparation state: Unzubereitet or Zubereitet")
+		sortBy := fs.String("sort", "", "Sort by nutrient (kcal, fat, sugar, protein, salt, ...)")
+		desc := fs.Bool("desc", false, "Sort descending")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if err := validateNumeric("market", *market); err != nil {
+			return nil, err
+		}
+		if len(ids) < 2 {
+			return nil, fmt.Errorf("-id is required at least twice")
+		}
+
+		var products []rewerse.ProductDetail
+		for _, id := range ids {
+			pd, err := rewerse.GetProductByID(*market, id)
+			if err != nil {
+				return nil, fmt.Errorf("product %s: %w", id, err)
+			}
+			products = append(products, pd)
+		}
+
+		comparison := rewerse.CompareNutrition(products, *state)
+		if *sortBy != "" {
+			nutrient, err := rewerse.ParseNutrient(*sortBy)
+			if err != nil {
+				return nil, err
+			}
+			comparison.SortBy(nutrient, *desc)
+		}
+		return comparison, nil
+
 	default:
 		productsHelp()
 		return nil, fmt.Errorf("unknown products subcommand: %s", args[0])
@@ -121,27 +227,52 @@ Subcommands:
   search      Search for products
   category    Get products from a category
   details     Get product details
+  lookup      Find a product by barcode (EAN) or article number (NAN)
   suggest     Get search suggestions
   recommend   Get product recommendations
+  compare     Compare nutrition values and Nutri-Score of products
 
 products search:
   -market     Market ID (required)
   -query      Search query (required)
   -service    PICKUP or DELIVERY (default: PICKUP, must match market capabilities)
-  -page       Page number
+  -page       Page number (not with -only or -no-restricted)
   -perPage    Results per page
+  -only       Only products with these attributes, comma-separated: vegan, vegetarian,
+              organic, glutenfree, dairyfree, regional, new, lowestprice, bulky
+  -no-restricted  Exclude age-restricted and biocidal products
+  -sort       unitprice: order the page by price per kg, l or Stück
+  -by-category  Group the products by their category in the market
+  -facets     Count the products per category instead of listing them
+  -depth      Category levels for -by-category and -facets (default: 2, 0 = all)
 
 products category:
   -market     Market ID (required)
   -slug       Category slug (required, use 'categories' command to list)
   -service    PICKUP or DELIVERY (default: PICKUP, must match market capabilities)
-  -page       Page number
+  -page       Page number (not with -only or -no-restricted)
   -perPage    Results per page
+  -only       Only products with these attributes (see products search)
+  -no-restricted  Exclude age-restricted and biocidal products
+  -sort       unitprice: order the page by price per kg, l or Stück
+  -by-category, -facets, -depth as for search
 
 products details:
   -market     Market ID (required)
   -product    Product ID (required)
 
+products lookup:
+  -ean        Barcode, e.g. scanned from the package. The API returns no EANs,
+              so a match is marked unverified until it's confirmed
+  -nan        Article number (Artikelnummer), e.g. from a discount or basket
+  -market     Market ID; matches the code with its search and returns the
+              product details (default: only the product ID, an EAN must
+              then be suggested for a single product)
+  -table      Local mapping table; found products are added and looked up
+              there first (default: %s)
+  -offline    Only look up the mapping table
+  -confirm    Mark the table's product for -ean as verified, after checking it
+
 products suggest:
   -query      Search query (required)
   -page       Page number
@@ -151,13 +282,207 @@ products recommend:
   -market     Market ID (required)
   -listing    Listing ID (required)
 
+products compare:
+  -market     Market ID (required)
+  -id         Product ID (required, repeat for each product)
+  -state      Unzubereitet or Zubereitet (default: Unzubereitet where available)
+  -sort       Sort by nutrient: kj, kcal, fat, saturates, carbs, sugar, fiber, protein, salt
+  -desc       Sort descending
+
 Note: Use 'markets details -id <market>' to check hasPickup field for service support.
 
 Examples:
   %s products search -market 831002 -query Karotten
   %s products search -market 840174 -query Tomaten -service DELIVERY
+  %s products search -market 831002 -query Schokolade -only vegan,glutenfree
+  %s products search -market 831002 -query Milch -sort unitprice
+  %s products search -market 831002 -query Bio -perPage 80 -facets
   %s products category -market 831002 -slug obst-gemuese
   %s products details -market 831002 -product 9900011
+  %s products lookup -ean 4001686301265
+  %s products lookup -ean 4001686301265 -confirm
+  %s products lookup -ean 4001686301265 -market 831002
+  %s products lookup -nan 7772669 -market 831002
   %s products suggest -query Milch
-`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+  %s products compare -market 831002 -id 7535400 -id 9900011 -sort protein -desc
+`, binaryName, defaultProductCodeFile, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+}
+
+// validateProductSort checks the -sort flag of product listings
+func validateProductSort(sortBy string) error {
+	if sortBy != "" && sortBy != "unitprice" {
+		return fmt.Errorf("-sort must be unitprice (got %q)", sortBy)
+	}
+	return nil
+}
+
+// sortProducts applies -sort to a result page
+func sortProducts(results rewerse.ProductResults, sortBy string, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	if sortBy == "unitprice" {
+		return rewerse.RankByUnitPrice(results.Products), nil
+	}
+	return results, nil
+}
+
+const defaultProductCodeFile = "product-codes.json"
+
+func handleProductsLookup(args []string) (any, error) {
+	fs := flag.NewFlagSet("products lookup", flag.ContinueOnError)
+	ean := fs.String("ean", "", "Barcode (EAN/GTIN)")
+	nan := fs.String("nan", "", "Article number")
+	market := fs.String("market", "", "Market ID; returns the product details")
+	table := fs.String("table", defaultProductCodeFile, "Local mapping table")
+	offline := fs.Bool("offline", false, "Only look up the local mapping table")
+	confirm := fs.Bool("confirm", false, "Confirm the table's product for -ean")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedArgs(fs); err != nil {
+		return nil, err
+	}
+	if (*ean == "") == (*nan == "") {
+		return nil, fmt.Errorf("either -ean or -nan is required")
+	}
+	if *market != "" {
+		if err := validateNumeric("market", *market); err != nil {
+			return nil, err
+		}
+	}
+	if (*offline || *confirm) && *market != "" {
+		return nil, fmt.Errorf("-offline and -confirm can't be combined with -market")
+	}
+	if *confirm && *ean == "" {
+		return nil, fmt.Errorf("-confirm requires -ean")
+	}
+
+	codes, err := rewerse.LoadProductCodeTable(*table)
+	if err != nil {
+		return nil, err
+	}
+	if *confirm {
+		code, ok := codes.ByEAN(*ean)
+		if ok {
+			code, ok = codes.Confirm(code.ProductID)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: EAN %s is not in %s, look it up first", rewerse.ErrProductNotFound, *ean, *table)
+		}
+		return code, codes.Save(*table)
+	}
+
+	var code rewerse.ProductCode
+	if *offline {
+		ok := false
+		if *ean != "" {
+			code, ok = codes.ByEAN(*ean)
+		} else {
+			code, ok = codes.ByNAN(*nan)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: not in %s", rewerse.ErrProductNotFound, *table)
+		}
+	} else {
+		if *ean != "" {
+			code, err = rewerse.ResolveEAN(*ean, *market, codes)
+		} else {
+			code, err = rewerse.ResolveNAN(*nan, *market, codes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := codes.Save(*table); err != nil {
+			return nil, err
+		}
+	}
+	if code.Unverified {
+		fmt.Fprintf(os.Stderr, "Warning: EAN %s is unverified: %s is only the likely product. Check it and confirm with -confirm\n", code.EAN, code.ProductID)
+	}
+	if *market == "" {
+		return code, nil
+	}
+	return rewerse.GetProductByID(*market, code.ProductID)
+}
+
+func validateCategoryView(sortBy string, byCategory, facets bool) error {
+	if byCategory && facets {
+		return fmt.Errorf("use either -by-category or -facets")
+	}
+	if sortBy != "" && (byCategory || facets) {
+		return fmt.Errorf("-sort can't be combined with -by-category or -facets")
+	}
+	return nil
+}
+
+// categorizeProducts resolves the categories of the results with the market's category tree
+func categorizeProducts(market, service string, results rewerse.ProductResults, facets bool, depth int, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	var opts *rewerse.ShopOverviewOpts
+	if service != "" {
+		opts = &rewerse.ShopOverviewOpts{ServiceType: rewerse.ServiceType(service)}
+	}
+	decorated, err := rewerse.NewCategoryResolver(market, opts).Decorate(results.Products)
+	if err != nil {
+		return nil, err
+	}
+	if facets {
+		return decorated.Facets(depth), nil
+	}
+	return decorated.GroupByCategory(depth), nil
+}
+
+// maxFilterPages limits the pages scanned for client-side filters
+const maxFilterPages = 10
+
+// validateFilterPage rejects -page with client-side filters, which always scan from page 1
+func validateFilterPage(page int, only string, noRestricted bool) error {
+	if page > 0 && (only != "" || noRestricted) {
+		return fmt.Errorf("-page can't be combined with -only or -no-restricted")
+	}
+	return nil
+}
+
+// productPredicate builds the client-side filter for -only and -no-restricted.
+// Attributes with a server-side filter are also added to opts to scan fewer pages.
+func productPredicate(only string, noRestricted bool, opts *rewerse.ProductOpts) (rewerse.ProductPredicate, error) {
+	attrs, err := rewerse.ParseProductAttributes(only)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attrs {
+		if f, ok := a.ServerFilter(); ok {
+			opts.Filters = append(opts.Filters, f)
+		}
+	}
+
+	preds := []rewerse.ProductPredicate{rewerse.HasAttributes(attrs...)}
+	if noRestricted {
+		preds = append(preds, rewerse.NotAgeRestricted(), rewerse.NotBiocide())
+	}
+	return rewerse.AllOf(preds...), nil
+}
+
+// collectFiltered collects up to limit matching products (default 30) into one result page
+func collectFiltered(it *rewerse.ProductIterator, query string, limit int) (rewerse.ProductResults, error) {
+	if limit <= 0 {
+		limit = 30
+	}
+	it.MaxPages = maxFilterPages
+	products, err := it.Collect(limit)
+	if err != nil {
+		return rewerse.ProductResults{}, err
+	}
+
+	var res rewerse.ProductResults
+	res.Products = products
+	res.SearchTerm.Original = query
+	res.Pagination.CurrentPage = 1
+	res.Pagination.PageCount = 1
+	res.Pagination.ObjectCount = len(products)
+	res.Pagination.ObjectsPerPage = limit
+	return res, nil
 }
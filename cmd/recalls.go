@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
+)
+
+const defaultRecallHistoryFile = "recalls-history.json"
+
+func handleRecalls(args []string) (any, error) {
+	if len(args) == 0 {
+		return rewerse.GetRecalls()
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		recallsHelp()
+		return nil, nil
+
+	case "details":
+		fs := flag.NewFlagSet("recalls details", flag.ContinueOnError)
+		url := fs.String("url", "", "URL of a single recall page")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if *url != "" {
+			return rewerse.FetchRecallDetails(rewerse.Recall{URL: *url})
+		}
+		recalls, err := rewerse.GetRecalls()
+		if err != nil {
+			return nil, err
+		}
+		return rewerse.EnrichRecalls(recalls, nil)
+
+	case "watch":
+		fs := flag.NewFlagSet("recalls watch", flag.ContinueOnError)
+		history := fs.String("history", defaultRecallHistoryFile, "Recall history file")
+		basket := fs.String("basket", "", "Basket session state file to match against")
+		watchlist := fs.String("watchlist", "", "Watchlist file, one product per line")
+		webhook := fs.String("webhook", "", "URL to post notifications to as JSON")
+		minScore := fs.Float64("min-score", rewerse.DefaultRecallMatchScore, "Minimum match score (0-1)")
+		every := fs.Duration("every", 0, "Repeat the check in this interval (0 = once)")
+		details := fs.Bool("details", false, "Load the recall pages to match barcodes")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if *minScore <= 0 || *minScore > 1 {
+			return nil, fmt.Errorf("-min-score must be between 0 and 1")
+		}
+
+		h, err := rewerse.LoadRecallHistory(*history)
+		if err != nil {
+			return nil, err
+		}
+		monitor := &rewerse.RecallMonitor{
+			History:   &h,
+			Notifiers: []rewerse.Notifier{rewerse.WriterNotifier{W: os.Stderr}},
+			MinScore:  *minScore,
+			Details:   *details,
+		}
+		if *webhook != "" {
+			monitor.Notifiers = append(monitor.Notifiers, rewerse.WebhookNotifier{URL: *webhook})
+		}
+
+		check := func() (rewerse.RecallReport, error) {
+			var err error
+			monitor.Candidates, err = recallCandidates(*basket, *watchlist)
+			if err != nil {
+				return rewerse.RecallReport{}, err
+			}
+			report, err := monitor.Check()
+			if err != nil {
+				return report, err
+			}
+			return report, h.Save(*history)
+		}
+		if *every <= 0 {
+			return check()
+		}
+
+		// a failed check is retried in the next interval
+		for {
+			report, err := check()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			} else {
+				fmt.Println(report)
+			}
+			time.Sleep(*every)
+		}
+
+	default:
+		recallsHelp()
+		return nil, fmt.Errorf("unknown recalls subcommand: %s", args[0])
+	}
+}
+
+// recallCandidates loads the products to match recalls against
+func recallCandidates(basketState, watchlist string) ([]rewerse.RecallCandidate, error) {
+	var candidates []rewerse.RecallCandidate
+	if basketState != "" {
+		b, err := withBasketSession(basketState, func(s *rewerse.BasketSession) (any, error) {
+			return s.GetBasket()
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, rewerse.BasketRecallCandidates(b.(rewerse.Basket))...)
+	}
+	if watchlist != "" {
+		f, err := os.Open(watchlist)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		watched, err := rewerse.ReadWatchlist(f)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", watchlist, err)
+		}
+		candidates = append(candidates, watched...)
+	}
+	return candidates, nil
+}
+
+func recallsHelp() {
+	fmt.Printf(`Usage: %s recalls [subcommand] [flags]
+
+Without subcommand, the current recalls are listed.
+
+Subcommands:
+  details     Extract EANs, lot numbers, best-before dates and regions from
+              the recall pages
+  watch       Report new and withdrawn recalls and possibly affected products
+
+recalls details:
+  -url        Only this recall page (default: all current recalls)
+
+recalls watch:
+  -history    Recall history file (default: %s)
+  -basket     Basket session state file to match against
+  -watchlist  Watchlist file, one product per line ("Brand | Product" also works,
+              a barcode can follow as "Brand | Product | 4001686301265")
+  -details    Load the page of each new recall and match barcodes of the
+              watchlist exactly
+  -webhook    URL to post each notification to as JSON
+  -min-score  Minimum match score between 0 and 1 (default: %.1f)
+  -every      Repeat the check in this interval, e.g. 6h (default: once)
+
+Notifications are written to stderr, the report to stdout. The first check of a
+new history file records the current recalls without notifying them; failed
+notifications are sent again in the next check.
+
+Examples:
+  %s recalls
+  %s recalls details
+  %s recalls watch -watchlist watchlist.txt -details
+  %s recalls watch -basket basket-session.json -every 6h -webhook https://example.org/hook
+`, binaryName, defaultRecallHistoryFile, rewerse.DefaultRecallMatchScore, binaryName, binaryName, binaryName, binaryName)
+}
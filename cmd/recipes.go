@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"math"
 
 	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
 )
@@ -22,6 +24,9 @@ func handleRecipes(args []string, jsonOutput bool) (any, error) {
 		page := fs.Int("page", 0, "Page number")
 		perPage := fs.Int("perPage", 0, "Results per page")
 		all := fs.Bool("all", false, "Fetch all results")
+		maxTime := fs.Duration("max-time", 0, "Maximum cooking time (e.g. 30m, 1h)")
+		maxLevel := fs.Int("max-level", 0, "Maximum difficulty level (1=easy, 2=medium, 3=hard)")
+		tags := fs.String("tags", "", "Comma-separated tags the recipes must have (e.g. schnell,Vegan)")
 		if err := fs.Parse(args[1:]); err != nil {
 			return nil, err
 		}
@@ -36,6 +41,23 @@ func handleRecipes(args []string, jsonOutput bool) (any, error) {
 			Page:           *page,
 			ObjectsPerPage: *perPage,
 		}
+		if err := validateRecipeOpts(opts); err != nil {
+			return nil, err
+		}
+
+		// Client-side filters page through the results until enough recipes match
+		filter := rewerse.RecipeFilter{
+			MaxDuration:        *maxTime,
+			MaxDifficultyLevel: *maxLevel,
+			Tags:               splitList(*tags),
+		}
+		if !filter.IsZero() {
+			limit := *perPage
+			if *all {
+				limit = math.MaxInt32
+			}
+			return rewerse.SearchRecipesFiltered(opts, filter, limit)
+		}
 
 		results, err := rewerse.RecipeSearch(opts)
 		if err != nil {
@@ -108,6 +130,109 @@ func handleRecipes(args []string, jsonOutput bool) (any, error) {
 		}
 		return rewerse.GetRecipeDetails(*id)
 
+	case "shop":
+		fs := flag.NewFlagSet("recipes shop", flag.ContinueOnError)
+		id := fs.String("id", "", "Recipe UUID")
+		market := fs.String("market", "", "Market ID")
+		portions := fs.Int("portions", 0, "Number of portions")
+		service := fs.String("service", "", "Service type: PICKUP or DELIVERY")
+		add := fs.Bool("add", false, "Add the proposed products to the active basket")
+		state := fs.String("state", defaultStateFile, "Basket session state file (with -add)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		if err := validateFlag("id", *id); err != nil {
+			return nil, err
+		}
+		if err := validateNumeric("market", *market); err != nil {
+			return nil, err
+		}
+
+		details, err := rewerse.GetRecipeDetails(*id)
+		if err != nil {
+			return nil, err
+		}
+		proposal, err := rewerse.ProposeRecipeProducts(details.Recipe, *market, &rewerse.RecipeShopOpts{
+			Portions:    *portions,
+			ServiceType: rewerse.ServiceType(*service),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !*add {
+			return proposal, nil
+		}
+
+		if _, err := withBasketSession(*state, func(s *rewerse.BasketSession) (any, error) {
+			return proposal.AddToBasket(s)
+		}); err != nil {
+			return nil, err
+		}
+		return proposal, nil
+
+	case "export":
+		fs := flag.NewFlagSet("recipes export", flag.ContinueOnError)
+		id := fs.String("id", "", "Recipe UUID")
+		format := fs.String("format", "jsonld", "Export format: jsonld, md or paprika")
+		out := fs.String("out", "", "Output file (default: stdout)")
+		term := fs.String("term", "", "Export all recipes of a search (instead of -id)")
+		collection := fs.String("collection", "", "Collection filter for the search")
+		difficulty := fs.String("difficulty", "", "Difficulty filter for the search")
+		perPage := fs.Int("perPage", 0, "Number of search results to export")
+		if err := fs.Parse(args[1:]); err != nil {
+			return nil, err
+		}
+		if err := checkUnexpectedArgs(fs); err != nil {
+			return nil, err
+		}
+		exportFormat, err := rewerse.ParseRecipeExportFormat(*format)
+		if err != nil {
+			return nil, err
+		}
+		if *id == "" && *term == "" && *collection == "" && *difficulty == "" {
+			return nil, fmt.Errorf("either -id or a search (-term, -collection, -difficulty) is required")
+		}
+		if exportFormat == rewerse.ExportPaprika && *out == "" {
+			return nil, fmt.Errorf("-out is required for the paprika format")
+		}
+
+		var recipes []rewerse.RecipeDetail
+		if *id != "" {
+			details, err := rewerse.GetRecipeDetails(*id)
+			if err != nil {
+				return nil, err
+			}
+			recipes = append(recipes, details.Recipe)
+		} else {
+			opts := &rewerse.RecipeSearchOpts{
+				SearchTerm:     *term,
+				Collection:     rewerse.RecipeCollection(*collection),
+				Difficulty:     rewerse.RecipeDifficulty(*difficulty),
+				ObjectsPerPage: *perPage,
+			}
+			if err := validateRecipeOpts(opts); err != nil {
+				return nil, err
+			}
+			results, err := rewerse.RecipeSearch(opts)
+			if err != nil {
+				return nil, err
+			}
+			recipes, err = rewerse.GetRecipeDetailsAll(results.Recipes)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, writeExport(*out, func(w io.Writer) error {
+			return rewerse.ExportRecipes(w, recipes, exportFormat)
+		})
+
+	case "facets":
+		return rewerse.GetRecipeFacets()
+
 	case "popular":
 		return rewerse.GetRecipePopularTerms()
 
@@ -126,6 +251,9 @@ func recipesHelp() {
 Subcommands:
   search      Search for recipes
   details     Get recipe details
+  shop        Propose products for a recipe in a market
+  export      Export recipes as JSON-LD, Markdown or Paprika
+  facets      List valid collections, tags and difficulties
   popular     Get popular search terms
   hub         Get recipe hub (featured recipes)
 
@@ -136,15 +264,60 @@ recipes search:
   -page       Page number
   -perPage    Results per page
   -all        Fetch all results
+  -max-time   Maximum cooking time, e.g. 30m or 1h (filtered client-side)
+  -max-level  Maximum difficulty level: 1=easy, 2=medium, 3=hard (filtered client-side)
+  -tags       Comma-separated tags, e.g. schnell,Vegan (see search metadata);
+              the API has no known tag filter, so this is approximated by a
+              full-text search for each tag
 
 recipes details:
   -id         Recipe UUID (required)
 
+recipes shop:
+  -id         Recipe UUID (required)
+  -market     Market ID (required)
+  -portions   Number of portions (default: as in the recipe)
+  -service    PICKUP or DELIVERY (default: PICKUP, must match market capabilities)
+  -add        Add the proposed products to the active basket (see 'basket create')
+  -state      Basket session state file (default: %s)
+
+recipes export:
+  -id         Recipe UUID (or use a search below)
+  -format     jsonld (schema.org, also for Mealie), md or paprika (default: jsonld)
+  -out        Output file (default: stdout, required for paprika)
+  -term       Export all recipes of a search
+  -collection Collection filter for the search
+  -difficulty Difficulty filter for the search
+  -perPage    Number of search results to export
+
 Examples:
   %s recipes search -term Pasta
   %s recipes search -collection Vegetarisch -difficulty Mittel
+  %s recipes search -term Pasta -max-time 30m -tags schnell
   %s recipes details -id 30ce3caf-4b3b-4c9e-8ea0-645fe75d1303
+  %s recipes shop -id 30ce3caf-4b3b-4c9e-8ea0-645fe75d1303 -market 831002 -portions 4
+  %s recipes export -id 30ce3caf-4b3b-4c9e-8ea0-645fe75d1303 -format md
+  %s recipes export -term Pasta -perPage 10 -format paprika -out pasta.paprikarecipes
+  %s recipes facets
   %s recipes popular
   %s recipes hub
-`, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+`, binaryName, defaultStateFile, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName, binaryName)
+}
+
+// validateRecipeOpts checks collection and difficulty against the current facets and
+// corrects their spelling. Skipped if neither is set to avoid the extra request.
+func validateRecipeOpts(opts *rewerse.RecipeSearchOpts) error {
+	if opts.Collection == "" && opts.Difficulty == "" {
+		return nil
+	}
+	facets, err := rewerse.GetRecipeFacets()
+	if err != nil {
+		return err
+	}
+	valid, err := facets.Validate(*opts)
+	if err != nil {
+		return err
+	}
+	*opts = valid
+	return nil
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rewerse "github.com/ByteSizedMarius/rewerse-engineering/pkg"
+)
+
+func handleServicesScan(args []string) (any, error) {
+	fs := flag.NewFlagSet("services scan", flag.ContinueOnError)
+	from := fs.String("from", "", "First zip code of the range")
+	to := fs.String("to", "", "Last zip code of the range")
+	zipList := fs.String("zips", "", "Comma-separated zip codes")
+	resume := fs.String("resume", "", "Progress file for resuming")
+	format := fs.String("format", "", "Dataset format: csv or json")
+	out := fs.String("out", "", "Output file (default: stdout)")
+	workers := fs.Int("workers", rewerse.DefaultRateLimit.Workers, "Concurrent requests")
+	interval := fs.Duration("interval", rewerse.DefaultRateLimit.Interval, "Minimum time between requests")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedArgs(fs); err != nil {
+		return nil, err
+	}
+
+	var zips []string
+	switch {
+	case *zipList != "" && (*from != "" || *to != ""):
+		return nil, fmt.Errorf("use either -zips or -from/-to")
+	case *zipList != "":
+		zips = splitList(*zipList)
+	case *from != "" && *to != "":
+		var err error
+		if zips, err = rewerse.ZipRange(*from, *to); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("-from and -to or -zips is required")
+	}
+	if *workers <= 0 {
+		return nil, fmt.Errorf("-workers must be positive")
+	}
+
+	dataFormat := strings.ToLower(*format)
+	if dataFormat == "" && *out != "" {
+		dataFormat = "csv"
+		if strings.EqualFold(filepath.Ext(*out), ".json") {
+			dataFormat = "json"
+		}
+	}
+	if dataFormat != "" && dataFormat != "csv" && dataFormat != "json" {
+		return nil, fmt.Errorf("-format must be csv or json (got %q)", *format)
+	}
+
+	opts := &rewerse.ScanOpts{RateLimit: &rewerse.RateLimit{Workers: *workers, Interval: *interval}}
+	var scanned, total int
+	progress := func(e rewerse.CoverageEntry) {
+		scanned++
+		fmt.Fprintf(os.Stderr, "\r%d/%d %s", scanned, total, e.ZipCode)
+	}
+	opts.OnResult = progress
+
+	if *resume != "" {
+		progressLog, done, err := rewerse.OpenCoverageLog(*resume)
+		if err != nil {
+			return nil, err
+		}
+		defer progressLog.Close()
+		opts.Done = done
+		var logErr error
+		opts.OnResult = func(e rewerse.CoverageEntry) {
+			if err := progressLog.Append(e); err != nil && logErr == nil {
+				logErr = err
+				fmt.Fprintf(os.Stderr, "\nError writing %s: %v\n", *resume, err)
+			}
+			progress(e)
+		}
+	}
+
+	// Duplicates and zip codes done in an earlier run are not queried
+	total = len(rewerse.PendingZips(zips, opts.Done))
+	start := time.Now()
+	coverage, err := rewerse.ScanCoverage(zips, opts)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "\rScanned %d zip codes in %s\n", scanned, time.Since(start).Round(time.Second))
+
+	if dataFormat == "" {
+		return coverage, nil
+	}
+	return nil, writeExport(*out, func(w io.Writer) error {
+		if dataFormat == "json" {
+			return coverage.WriteJSON(w)
+		}
+		return coverage.WriteCSV(w)
+	})
+}
@@ -3,11 +3,54 @@ package rewerse
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 )
 
+// ErrBasketConflict is returned (wrapped) when a basket change is rejected because the
+// basket was modified concurrently and re-applying the change after a refresh failed too.
+// Check with errors.Is(err, ErrBasketConflict).
+var ErrBasketConflict = errors.New("basket version conflict")
+
+// conflictError keeps the underlying HTTP error while matching ErrBasketConflict
+type conflictError struct {
+	version int
+	err     error
+}
+
+func (e *conflictError) Error() string {
+	return fmt.Sprintf("%s (local version %d): %v", ErrBasketConflict, e.version, e.err)
+}
+
+func (e *conflictError) Is(target error) bool {
+	return target == ErrBasketConflict
+}
+
+func (e *conflictError) Unwrap() error {
+	return e.err
+}
+
+// isVersionConflict reports whether err is the server rejecting a stale basket version.
+// 409 Conflict and 412 Precondition Failed always count; a 400 only if its body mentions
+// the version, since 400 is also used for invalid market selections.
+func isVersionConflict(err error) bool {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	switch httpErr.StatusCode {
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return true
+	case http.StatusBadRequest:
+		return strings.Contains(strings.ToLower(httpErr.Body), "version")
+	}
+	return false
+}
+
 // ServiceType represents the delivery/pickup service type
 type ServiceType string
 
@@ -25,19 +68,35 @@ func validateServiceType(st ServiceType) error {
 
 // BasketSession holds the context for basket operations.
 // Create one with CreateBasket, then use it for subsequent operations.
+// The session marshals to JSON, so it can be persisted with a SessionStore and resumed later.
 type BasketSession struct {
 	// ID is the server-generated basket UUID
-	ID string
+	ID string `json:"basketId"`
 	// DeviceID is the server-assigned device identifier
-	DeviceID string
+	DeviceID string `json:"deviceId"`
 	// Version tracks basket changes for optimistic locking
-	Version int
+	Version int `json:"version"`
 	// MarketID is the selected market
-	MarketID string
+	MarketID string `json:"marketId"`
 	// ZipCode is the customer's postal code
-	ZipCode string
+	ZipCode string `json:"zipCode"`
 	// ServiceType is "PICKUP" or "DELIVERY"
-	ServiceType ServiceType
+	ServiceType ServiceType `json:"serviceType"`
+}
+
+// Validate checks that the session contains everything needed for basket operations.
+// Useful after unmarshalling a session from an external source.
+func (s *BasketSession) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("basketId: cannot be empty")
+	}
+	if s.MarketID == "" {
+		return fmt.Errorf("marketId: cannot be empty")
+	}
+	if s.ZipCode == "" {
+		return fmt.Errorf("zipCode: cannot be empty")
+	}
+	return validateServiceType(s.ServiceType)
 }
 
 // CreateBasket creates a new basket session for the given market and service type.
@@ -102,11 +161,19 @@ func (s *BasketSession) GetBasket() (Basket, error) {
 // SetItemQuantity sets the quantity of an item in the basket.
 // Use listingId from product search results (e.g., "8-FP05LLPR-rewe-online-services|48465001-320516").
 // Setting quantity to 0 removes the item.
+// If the basket was changed by another client, the version is refreshed and the change
+// is applied once more; if that fails as well, the error wraps ErrBasketConflict.
 func (s *BasketSession) SetItemQuantity(listingID string, quantity int) (Basket, error) {
 	if quantity < 0 {
 		return Basket{}, fmt.Errorf("quantity must be non-negative")
 	}
 
+	return s.retryOnConflict(func() (Basket, error) {
+		return s.setItemQuantity(listingID, quantity)
+	})
+}
+
+func (s *BasketSession) setItemQuantity(listingID string, quantity int) (Basket, error) {
 	body, err := json.Marshal(setQuantityRequest{
 		BasketVersion:   s.Version,
 		Quantity:        quantity,
@@ -133,8 +200,15 @@ func (s *BasketSession) SetItemQuantity(listingID string, quantity int) (Basket,
 	return res.Data.Basket, nil
 }
 
-// RemoveItem removes an item from the basket entirely
+// RemoveItem removes an item from the basket entirely.
+// Version conflicts are handled like in SetItemQuantity.
 func (s *BasketSession) RemoveItem(listingID string) (Basket, error) {
+	return s.retryOnConflict(func() (Basket, error) {
+		return s.removeItem(listingID)
+	})
+}
+
+func (s *BasketSession) removeItem(listingID string) (Basket, error) {
 	path := fmt.Sprintf("baskets/%s/listings/%s", s.ID, url.PathEscape(listingID))
 	req, err := BuildDeleteRequest(clientHost, path)
 	if err != nil {
@@ -152,6 +226,76 @@ func (s *BasketSession) RemoveItem(listingID string) (Basket, error) {
 	return res.Data.Basket, nil
 }
 
+// retryOnConflict runs op and, if the server rejected it because of a stale version,
+// refreshes the version via GetBasket and runs op a second time.
+// Both operations used with it set absolute state, so re-applying them is safe.
+func (s *BasketSession) retryOnConflict(op func() (Basket, error)) (Basket, error) {
+	basket, err := op()
+	if !isVersionConflict(err) {
+		return basket, err
+	}
+
+	if _, err := s.GetBasket(); err != nil {
+		return Basket{}, fmt.Errorf("error refreshing basket after version conflict: %w", err)
+	}
+
+	basket, err = op()
+	if isVersionConflict(err) {
+		return Basket{}, &conflictError{version: s.Version, err: err}
+	}
+	return basket, err
+}
+
+// ApplyItems sets the quantities of several items, keyed by listing ID.
+// The server requires the current version for every change, so the requests are sent
+// one after another (sorted by listing ID), each using the version returned by the previous one.
+// A quantity of 0 removes the item. On error, the basket returned is the state after the
+// last successful change.
+func (s *BasketSession) ApplyItems(items map[string]int) (Basket, error) {
+	for listingID, qty := range items {
+		if qty < 0 {
+			return Basket{}, fmt.Errorf("quantity for %s must be non-negative", listingID)
+		}
+	}
+
+	listingIDs := make([]string, 0, len(items))
+	for listingID := range items {
+		listingIDs = append(listingIDs, listingID)
+	}
+	sort.Strings(listingIDs)
+
+	if len(listingIDs) == 0 {
+		return s.GetBasket()
+	}
+
+	var basket Basket
+	for _, listingID := range listingIDs {
+		b, err := s.SetItemQuantity(listingID, items[listingID])
+		if err != nil {
+			return basket, fmt.Errorf("error setting %s: %w", listingID, err)
+		}
+		basket = b
+	}
+	return basket, nil
+}
+
+// Clear removes all items from the basket.
+func (s *BasketSession) Clear() (Basket, error) {
+	basket, err := s.GetBasket()
+	if err != nil {
+		return Basket{}, err
+	}
+
+	for _, li := range basket.LineItems {
+		b, err := s.RemoveItem(li.Product.Listing.ListingID)
+		if err != nil {
+			return basket, fmt.Errorf("error removing %s: %w", li.Product.Listing.ListingID, err)
+		}
+		basket = b
+	}
+	return basket, nil
+}
+
 // setBasketHeaders adds the required headers for basket operations
 func setBasketHeaders(req *http.Request, basketID, marketID, zipCode string, serviceType ServiceType) {
 	if basketID != "" {
@@ -0,0 +1,292 @@
+package rewerse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// blockingViolationIDs are the violation IDs known to prevent checkout. Only IDs seen in
+// API responses are listed; everything else is treated as informational.
+var blockingViolationIDs = map[string]bool{
+	"minimum.delivery.not.reached": true,
+}
+
+// ReportViolation is a basket or line item violation with its interpretation
+type ReportViolation struct {
+	Violation
+	// Blocking is true if the violation prevents checkout
+	Blocking bool
+	// Product is the title of the affected line item (empty for basket-level violations)
+	Product string
+}
+
+// FeeLine is a single fee of the basket in euros
+type FeeLine struct {
+	// Name is the German fee name: "Getränkezuschlag", "Servicegebühr"
+	Name string
+	// Euros is the fee amount, negative for refunds
+	Euros float64
+}
+
+// BeverageForecast predicts the beverage surcharge from the bulky goods in the basket
+// and the market's BulkyGoodsConfig limits.
+type BeverageForecast struct {
+	// BulkyItems is the number of bulky goods (crates, packs) in the basket
+	BulkyItems int
+	// SoftLimit is the number of bulky goods allowed without surcharge
+	SoftLimit int
+	// HardLimit is the maximum number of bulky goods per order
+	HardLimit int
+	// SurchargeApplies is true once BulkyItems exceeds SoftLimit
+	SurchargeApplies bool
+	// Surcharge is the expected surcharge in euros (0 if it doesn't apply)
+	Surcharge float64
+	// RemainingBeforeSurcharge is how many bulky goods can be added without surcharge
+	RemainingBeforeSurcharge int
+	// OverHardLimit is true if the basket holds more bulky goods than allowed
+	OverHardLimit bool
+	// DisplayTexts are REWE's explanations of the rules
+	DisplayTexts []string
+}
+
+// BasketReport is an interpreted view of a Basket: what blocks checkout, how much is
+// missing for the next staggering and which fees apply.
+type BasketReport struct {
+	// Blocking violations prevent checkout
+	Blocking []ReportViolation
+	// Informational violations are hints that don't prevent checkout
+	Informational []ReportViolation
+	// ArticlePrice is the subtotal in euros
+	ArticlePrice float64
+	// TotalPrice is the total including fees in euros
+	TotalPrice float64
+	// MinimumOrderRemaining is the amount in euros missing for the minimum order value (0 if reached)
+	MinimumOrderRemaining float64
+	// NextStaggering is the next pricing threshold (nil if there is none)
+	NextStaggering *Staggering
+	// NextStaggeringRemaining is the amount in euros missing for NextStaggering
+	NextStaggeringRemaining float64
+	// Fees contains the fees that are set on the basket
+	Fees []FeeLine
+	// Beverage is the beverage surcharge forecast (nil if no config is available)
+	Beverage *BeverageForecast
+	// BeverageError is why the bulky goods configuration couldn't be loaded (empty if it was)
+	BeverageError string
+}
+
+// CanCheckout reports whether the basket has no blocking violations
+func (r BasketReport) CanCheckout() bool {
+	return len(r.Blocking) == 0
+}
+
+func (r BasketReport) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep("Status"))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Bestellbar"))
+	if r.CanCheckout() {
+		sb.WriteString("Ja")
+	} else {
+		sb.WriteString("Nein")
+	}
+	sb.WriteByte('\n')
+	for _, v := range r.Blocking {
+		sb.WriteString(align("Blockiert"))
+		sb.WriteString(v.describe())
+		sb.WriteByte('\n')
+	}
+	for _, v := range r.Informational {
+		sb.WriteString(align("Hinweis"))
+		sb.WriteString(v.describe())
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteByte('\n')
+	sb.WriteString(sep("Beträge"))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Artikel"))
+	sb.WriteString(fmt.Sprintf("%.2f €", r.ArticlePrice))
+	sb.WriteByte('\n')
+	for _, f := range r.Fees {
+		sb.WriteString(align(f.Name))
+		sb.WriteString(fmt.Sprintf("%.2f €", f.Euros))
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(align("Gesamt"))
+	sb.WriteString(fmt.Sprintf("%.2f €", r.TotalPrice))
+	sb.WriteByte('\n')
+	if r.MinimumOrderRemaining > 0 {
+		sb.WriteString(align("Bis Mindestwert"))
+		sb.WriteString(fmt.Sprintf("%.2f €", r.MinimumOrderRemaining))
+		sb.WriteByte('\n')
+	}
+	if r.NextStaggering != nil {
+		sb.WriteString(align("Bis nächste Stufe"))
+		sb.WriteString(fmt.Sprintf("%.2f € (%s)", r.NextStaggeringRemaining, r.NextStaggering.DisplayText))
+		sb.WriteByte('\n')
+	}
+
+	if r.Beverage != nil {
+		b := r.Beverage
+		sb.WriteByte('\n')
+		sb.WriteString(sep("Getränke"))
+		sb.WriteByte('\n')
+		sb.WriteString(align("Sperrige Artikel"))
+		sb.WriteString(fmt.Sprintf("%d (Zuschlag ab %d, max. %d)", b.BulkyItems, b.SoftLimit+1, b.HardLimit))
+		sb.WriteByte('\n')
+		sb.WriteString(align("Zuschlag"))
+		if b.SurchargeApplies {
+			sb.WriteString(fmt.Sprintf("%.2f €", b.Surcharge))
+		} else {
+			sb.WriteString(fmt.Sprintf("keiner (noch %d ohne Zuschlag)", b.RemainingBeforeSurcharge))
+		}
+		sb.WriteByte('\n')
+		if b.OverHardLimit {
+			sb.WriteString(align("Achtung"))
+			sb.WriteString("Höchstmenge überschritten")
+			sb.WriteByte('\n')
+		}
+	} else if r.BeverageError != "" {
+		sb.WriteByte('\n')
+		sb.WriteString(sep("Getränke"))
+		sb.WriteByte('\n')
+		sb.WriteString(align("Prognose"))
+		sb.WriteString("nicht verfügbar: " + r.BeverageError)
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+func (v ReportViolation) describe() string {
+	s := v.Message
+	if v.Product != "" {
+		s = v.Product + ": " + s
+	}
+	if v.DetailMessage != nil && *v.DetailMessage != "" {
+		s += " (" + *v.DetailMessage + ")"
+	}
+	return s
+}
+
+// IsBlockingViolation reports whether a violation ID prevents checkout.
+// The API doesn't flag this itself, so it is looked up in the known blocking IDs.
+func IsBlockingViolation(id string) bool {
+	return blockingViolationIDs[strings.ToLower(strings.TrimSpace(id))]
+}
+
+// NewBasketReport interprets a basket. bulky is optional; without it the report
+// contains no beverage forecast.
+func NewBasketReport(b Basket, bulky *BulkyGoodsConfig) BasketReport {
+	r := BasketReport{
+		ArticlePrice: centsToEuros(b.Summary.ArticlePrice),
+		TotalPrice:   centsToEuros(b.Summary.TotalPrice),
+	}
+
+	addViolation := func(v Violation, product string) {
+		rv := ReportViolation{Violation: v, Blocking: IsBlockingViolation(v.ID), Product: product}
+		if rv.Blocking {
+			r.Blocking = append(r.Blocking, rv)
+		} else {
+			r.Informational = append(r.Informational, rv)
+		}
+	}
+	for _, v := range b.Violations {
+		addViolation(v, "")
+	}
+	for _, li := range b.LineItems {
+		for _, v := range li.Violations {
+			addViolation(v, li.Product.Title)
+		}
+	}
+
+	if missing := b.ServiceConfiguration.MinimumOrderAmount - b.Summary.ArticlePrice; missing > 0 {
+		r.MinimumOrderRemaining = centsToEuros(missing)
+	}
+
+	if next := b.Staggerings.NextStaggering; next != nil {
+		r.NextStaggering = next
+		remaining := next.RemainingArticlePrice
+		if remaining == 0 {
+			remaining = next.ArticlePriceThreshold - b.Summary.ArticlePrice
+		}
+		if remaining > 0 {
+			r.NextStaggeringRemaining = centsToEuros(remaining)
+		}
+	}
+
+	fees := b.Summary.Fees
+	for _, f := range []struct {
+		name  string
+		cents *int
+	}{
+		{"Getränkezuschlag", fees.BeverageSurcharge},
+		{"Tragetaschen", fees.ReusableBagSurcharge},
+		{"Transportboxen", fees.TransportBoxSurcharge},
+		{"Servicegebühr", fees.ServiceFee},
+		{"Pfand", fees.Refund},
+	} {
+		if f.cents != nil && *f.cents != 0 {
+			r.Fees = append(r.Fees, FeeLine{Name: f.name, Euros: centsToEuros(*f.cents)})
+		}
+	}
+	if ts := b.TimeSlotInformation.TimeSlotPrice; ts != nil && *ts != 0 {
+		r.Fees = append(r.Fees, FeeLine{Name: "Zeitfenster", Euros: centsToEuros(*ts)})
+	}
+
+	if bulky != nil && bulky.HasBeverageSurcharge && bulky.BeverageSurcharge != nil {
+		r.Beverage = forecastBeverageSurcharge(b, *bulky.BeverageSurcharge)
+	}
+
+	return r
+}
+
+func forecastBeverageSurcharge(b Basket, bs BeverageSurcharge) *BeverageForecast {
+	f := &BeverageForecast{
+		SoftLimit:    bs.SoftLimit,
+		HardLimit:    bs.HardLimit,
+		DisplayTexts: bs.DisplayTexts,
+	}
+	for _, li := range b.LineItems {
+		if li.Product.Attributes.IsBulkyGood {
+			f.BulkyItems += li.Quantity
+		}
+	}
+
+	f.SurchargeApplies = f.BulkyItems > bs.SoftLimit
+	if f.SurchargeApplies {
+		f.Surcharge = centsToEuros(bs.Surcharge)
+	} else {
+		f.RemainingBeforeSurcharge = bs.SoftLimit - f.BulkyItems
+	}
+	f.OverHardLimit = bs.HardLimit > 0 && f.BulkyItems > bs.HardLimit
+
+	return f
+}
+
+// Report fetches the current basket and the market's bulky goods configuration and
+// interprets both. If the bulky goods configuration can't be loaded (not every market
+// has one), the report is returned without beverage forecast and with BeverageError set.
+func (s *BasketSession) Report() (BasketReport, error) {
+	basket, err := s.GetBasket()
+	if err != nil {
+		return BasketReport{}, err
+	}
+
+	var bulky *BulkyGoodsConfig
+	cfg, cfgErr := GetBulkyGoodsConfig(s.MarketID, s.ServiceType)
+	if cfgErr == nil {
+		bulky = &cfg
+	}
+
+	r := NewBasketReport(basket, bulky)
+	if cfgErr != nil {
+		r.BeverageError = cfgErr.Error()
+	}
+	return r, nil
+}
+
+func centsToEuros(cents int) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,119 @@
+package rewerse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrNoSession is returned by a SessionStore when no session has been saved yet.
+var ErrNoSession = errors.New("no stored basket session")
+
+// SessionStore persists a single BasketSession so a basket can be resumed
+// across processes (or shared between the Go and Python clients).
+type SessionStore interface {
+	// Load returns the stored session or ErrNoSession if there is none
+	Load() (*BasketSession, error)
+	// Save stores the session, replacing any previously stored one
+	Save(s *BasketSession) error
+	// Delete removes the stored session. Deleting a missing session is not an error.
+	Delete() error
+}
+
+// ResumeBasket loads a session from the store.
+// The returned session still carries the version it was saved with; call GetBasket to refresh it.
+func ResumeBasket(store SessionStore) (*BasketSession, error) {
+	s, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid stored session: %w", err)
+	}
+	return s, nil
+}
+
+// FileSessionStore stores the session as JSON in a file.
+type FileSessionStore struct {
+	Path string
+}
+
+// NewFileSessionStore returns a store backed by the file at path.
+// The file and its parent directories are created on the first Save.
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{Path: path}
+}
+
+func (fs *FileSessionStore) Load() (*BasketSession, error) {
+	data, err := os.ReadFile(fs.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading session file: %w", err)
+	}
+
+	var s BasketSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("error unmarshalling session: %w", err)
+	}
+	return &s, nil
+}
+
+func (fs *FileSessionStore) Save(s *BasketSession) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling session: %w", err)
+	}
+
+	if err := writeFileAtomic(fs.Path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing session file: %w", err)
+	}
+	return nil
+}
+
+func (fs *FileSessionStore) Delete() error {
+	err := os.Remove(fs.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error deleting session file: %w", err)
+	}
+	return nil
+}
+
+// MemorySessionStore keeps the session in memory. Safe for concurrent use.
+type MemorySessionStore struct {
+	mu      sync.Mutex
+	session *BasketSession
+}
+
+// NewMemorySessionStore returns an empty in-memory store.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{}
+}
+
+func (ms *MemorySessionStore) Load() (*BasketSession, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.session == nil {
+		return nil, ErrNoSession
+	}
+	s := *ms.session
+	return &s, nil
+}
+
+func (ms *MemorySessionStore) Save(s *BasketSession) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	cp := *s
+	ms.session = &cp
+	return nil
+}
+
+func (ms *MemorySessionStore) Delete() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.session = nil
+	return nil
+}
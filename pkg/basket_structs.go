@@ -1,5 +1,10 @@
 package rewerse
 
+import (
+	"fmt"
+	"strings"
+)
+
 // basketResponse wraps the API response for basket operations
 type basketResponse struct {
 	Data struct {
@@ -27,6 +32,48 @@ type Basket struct {
 	TimeSlotInformation   TimeSlotInformation   `json:"timeSlotInformation"`
 }
 
+func (b Basket) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep("Warenkorb"))
+	sb.WriteByte('\n')
+	sb.WriteString(align("ID"))
+	sb.WriteString(fmt.Sprintf("%s (v%d)", b.ID, b.Version))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Markt"))
+	sb.WriteString(fmt.Sprintf("%s (%s, %s)", b.ServiceSelection.WWIdent, b.ServiceSelection.ServiceType, b.ServiceSelection.ZipCode))
+	sb.WriteByte('\n')
+
+	sb.WriteByte('\n')
+	sb.WriteString(sep(fmt.Sprintf("Artikel (%d)", len(b.LineItems))))
+	sb.WriteByte('\n')
+	if len(b.LineItems) == 0 {
+		sb.WriteString("   (leer)\n")
+	}
+	for _, li := range b.LineItems {
+		sb.WriteString(fmt.Sprintf("   %2dx %s (%.2f €)\n", li.Quantity, li.Product.Title, float64(li.TotalPrice)/100))
+		sb.WriteString(fmt.Sprintf("       %s\n", li.Product.Listing.ListingID))
+	}
+
+	sb.WriteByte('\n')
+	sb.WriteString(sep("Summe"))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Artikel"))
+	sb.WriteString(fmt.Sprintf("%.2f €", float64(b.Summary.ArticlePrice)/100))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Gesamt"))
+	sb.WriteString(fmt.Sprintf("%.2f €", float64(b.Summary.TotalPrice)/100))
+	sb.WriteByte('\n')
+
+	for _, v := range b.Violations {
+		sb.WriteString(align("Hinweis"))
+		sb.WriteString(v.Message)
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
 // ServiceSelection contains the selected market and service type
 type ServiceSelection struct {
 	// WWIdent is the market ID: "831002"
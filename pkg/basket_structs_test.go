@@ -2,6 +2,9 @@ package rewerse
 
 import (
 	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -37,3 +40,123 @@ func TestBasketResponseUnmarshal(t *testing.T) {
 		t.Error("nextStaggering: expected non-nil")
 	}
 }
+
+func TestBasketSessionFileStore(t *testing.T) {
+	store := NewFileSessionStore(filepath.Join(t.TempDir(), "basket.json"))
+
+	if _, err := store.Load(); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("load empty store: expected ErrNoSession, got %v", err)
+	}
+
+	in := &BasketSession{
+		ID:          "b4a5c6d7-e8f9-4a0b-1c2d-3e4f5a6b7c8d",
+		DeviceID:    "d1e2v3c4-a5b6-4c7d-8e9f-0a1b2c3d4e5f",
+		Version:     3,
+		MarketID:    "831002",
+		ZipCode:     "67065",
+		ServiceType: ServicePickup,
+	}
+	if err := store.Save(in); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	out, err := ResumeBasket(store)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if *out != *in {
+		t.Errorf("session mismatch: expected %+v, got %+v", *in, *out)
+	}
+
+	if err := store.Delete(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := store.Load(); !errors.Is(err, ErrNoSession) {
+		t.Errorf("load after delete: expected ErrNoSession, got %v", err)
+	}
+}
+
+func TestIsVersionConflict(t *testing.T) {
+	tests := []struct {
+		err      error
+		conflict bool
+	}{
+		{&HTTPError{StatusCode: 409, Body: `{"message":"conflict"}`}, true},
+		{&HTTPError{StatusCode: 412}, true},
+		{&HTTPError{StatusCode: 400, Body: `{"message":"Invalid basketVersion"}`}, true},
+		{&HTTPError{StatusCode: 400, Body: `{"message":"Invalid market selection"}`}, false},
+		{&HTTPError{StatusCode: 500}, false},
+		{errors.New("HTTP 409"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isVersionConflict(tt.err); got != tt.conflict {
+			t.Errorf("isVersionConflict(%v): expected %v, got %v", tt.err, tt.conflict, got)
+		}
+	}
+
+	err := error(&conflictError{version: 4, err: &HTTPError{StatusCode: 409}})
+	if !errors.Is(err, ErrBasketConflict) {
+		t.Error("conflictError does not match ErrBasketConflict")
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 409 {
+		t.Error("conflictError does not unwrap to the HTTP error")
+	}
+}
+
+func TestNewBasketReport(t *testing.T) {
+	surcharge, serviceFee := 190, 290
+	b := Basket{
+		ServiceConfiguration: ServiceConfiguration{MinimumOrderAmount: 5000},
+		Staggerings: Staggerings{
+			NextStaggering: &Staggering{ArticlePriceThreshold: 7000, RemainingArticlePrice: 4000},
+		},
+		LineItems: []LineItem{
+			{Quantity: 3, Product: LineItemProduct{Title: "Wasser 12x1l", Attributes: ProductAttributes{IsBulkyGood: true}}},
+			{Quantity: 1, Product: LineItemProduct{Title: "Milch"}, Violations: []Violation{{ID: "listing.price.changed", Message: "Preis geändert"}}},
+			{Quantity: 1, Product: LineItemProduct{Title: "Butter"}, Violations: []Violation{{ID: "listing.quantity.limit.exceeded", Message: "Menge angepasst"}}},
+		},
+		Violations: []Violation{{ID: "minimum.delivery.not.reached", Message: "Mindestbestellwert 50 EUR nicht erreicht!"}},
+		Summary: BasketSummary{
+			ArticlePrice: 3000,
+			TotalPrice:   3480,
+			Fees:         BasketFees{BeverageSurcharge: &surcharge, ServiceFee: &serviceFee},
+		},
+	}
+	cfg := &BulkyGoodsConfig{
+		HasBeverageSurcharge: true,
+		BeverageSurcharge:    &BeverageSurcharge{SoftLimit: 2, HardLimit: 6, Surcharge: 190},
+	}
+
+	r := NewBasketReport(b, cfg)
+	if r.CanCheckout() || len(r.Blocking) != 1 {
+		t.Errorf("expected 1 blocking violation, got %d", len(r.Blocking))
+	}
+	// unknown IDs are informational, even if they sound severe
+	if len(r.Informational) != 2 || r.Informational[0].Product != "Milch" || r.Informational[1].Product != "Butter" {
+		t.Errorf("expected informational line item violations for Milch and Butter, got %+v", r.Informational)
+	}
+	if r.MinimumOrderRemaining != 20 {
+		t.Errorf("minimum order remaining: expected 20, got %.2f", r.MinimumOrderRemaining)
+	}
+	if r.NextStaggeringRemaining != 40 {
+		t.Errorf("next staggering remaining: expected 40, got %.2f", r.NextStaggeringRemaining)
+	}
+	if len(r.Fees) != 2 || r.Fees[0].Euros != 1.90 || r.Fees[1].Euros != 2.90 {
+		t.Errorf("unexpected fees: %+v", r.Fees)
+	}
+	if r.Beverage == nil {
+		t.Fatal("expected beverage forecast")
+	}
+	if r.Beverage.BulkyItems != 3 || !r.Beverage.SurchargeApplies || r.Beverage.OverHardLimit {
+		t.Errorf("unexpected beverage forecast: %+v", *r.Beverage)
+	}
+
+	r = NewBasketReport(b, nil)
+	r.BeverageError = "HTTP 404"
+	if !strings.Contains(r.String(), "nicht verfügbar: HTTP 404") {
+		t.Errorf("config error missing in String():\n%s", r)
+	}
+}
@@ -0,0 +1,278 @@
+package rewerse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// categoryPathSep separates the names of a category path: "Obst & Gemüse > Obst > Äpfel"
+const categoryPathSep = " > "
+
+// uncategorized is the group name of products without a known category
+const uncategorized = "Ohne Kategorie"
+
+// CategoryResolver maps the category IDs of products (Product.Categories,
+// ProductSuggestion.RawValues.CategoryID) to their paths in a market's category tree.
+// The tree is loaded on first use and cached; a failed load is retried on the next call.
+// It's safe for concurrent use.
+type CategoryResolver struct {
+	load func() (ShopOverview, error)
+
+	mu    sync.Mutex
+	paths map[string]CategoryPath
+}
+
+// NewCategoryResolver returns a resolver for the categories of the market
+func NewCategoryResolver(marketID string, opts *ShopOverviewOpts) *CategoryResolver {
+	return &CategoryResolver{load: func() (ShopOverview, error) {
+		return GetShopOverviewWithOpts(marketID, opts)
+	}}
+}
+
+// NewCategoryResolverFromOverview returns a resolver for an already loaded overview
+func NewCategoryResolverFromOverview(so ShopOverview) *CategoryResolver {
+	return &CategoryResolver{load: func() (ShopOverview, error) { return so, nil }}
+}
+
+func (cr *CategoryResolver) index() (map[string]CategoryPath, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.paths != nil {
+		return cr.paths, nil
+	}
+	so, err := cr.load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading categories: %w", err)
+	}
+	paths := make(map[string]CategoryPath)
+	walkCategories(so.ProductCategories, nil, func(p CategoryPath) {
+		if _, ok := paths[p.Category().ID]; !ok {
+			paths[p.Category().ID] = p
+		}
+	})
+	cr.paths = paths
+	return paths, nil
+}
+
+// Path returns the path of a category ID, or nil if the market doesn't list it
+func (cr *CategoryResolver) Path(categoryID string) (CategoryPath, error) {
+	paths, err := cr.index()
+	if err != nil {
+		return nil, err
+	}
+	return paths[categoryID], nil
+}
+
+// ProductPaths returns the most specific category paths of the product. Products list
+// their parent categories too; those are dropped, as are IDs the market doesn't list.
+func (cr *CategoryResolver) ProductPaths(p Product) ([]CategoryPath, error) {
+	paths, err := cr.index()
+	if err != nil {
+		return nil, err
+	}
+	return specificPaths(paths, p.Categories), nil
+}
+
+// SuggestionPath returns the category path of a search suggestion, or nil if unknown
+func (cr *CategoryResolver) SuggestionPath(s ProductSuggestion) (CategoryPath, error) {
+	return cr.Path(s.RawValues.CategoryID)
+}
+
+func specificPaths(paths map[string]CategoryPath, ids []string) []CategoryPath {
+	var found []CategoryPath
+	ancestors := make(map[string]bool)
+	for _, id := range ids {
+		p, ok := paths[id]
+		if !ok {
+			continue
+		}
+		found = append(found, p)
+		for _, c := range p[:len(p)-1] {
+			ancestors[c.ID] = true
+		}
+	}
+
+	var specific []CategoryPath
+	seen := make(map[string]bool)
+	for _, p := range found {
+		id := p.Category().ID
+		if ancestors[id] || seen[id] {
+			continue
+		}
+		seen[id] = true
+		specific = append(specific, p)
+	}
+	// deepest first, so the first path is the most precise
+	sort.SliceStable(specific, func(i, j int) bool { return len(specific[i]) > len(specific[j]) })
+	return specific
+}
+
+// CategorizedProduct is a product with its resolved category paths
+type CategorizedProduct struct {
+	Product
+	// CategoryPaths are the most specific paths, the deepest first:
+	// "Obst & Gemüse > Obst > Äpfel"
+	CategoryPaths []string `json:"categoryPaths"`
+}
+
+// Category returns the first (most specific) category path, or "" if none is known
+func (cp CategorizedProduct) Category() string {
+	if len(cp.CategoryPaths) == 0 {
+		return ""
+	}
+	return cp.CategoryPaths[0]
+}
+
+// CategorizedProducts is a list of products with their categories
+type CategorizedProducts []CategorizedProduct
+
+func (cps CategorizedProducts) String() string {
+	var sb strings.Builder
+	for _, cp := range cps {
+		category := cp.Category()
+		if category == "" {
+			category = uncategorized
+		}
+		sb.WriteString(fmt.Sprintf("%s\n   %s\n", cp.Title, category))
+	}
+	return sb.String()
+}
+
+// Decorate resolves the category paths of the products
+func (cr *CategoryResolver) Decorate(products []Product) (CategorizedProducts, error) {
+	paths, err := cr.index()
+	if err != nil {
+		return nil, err
+	}
+	decorated := make(CategorizedProducts, len(products))
+	for i, p := range products {
+		decorated[i] = CategorizedProduct{Product: p}
+		for _, path := range specificPaths(paths, p.Categories) {
+			decorated[i].CategoryPaths = append(decorated[i].CategoryPaths, path.String())
+		}
+	}
+	return decorated, nil
+}
+
+// truncateCategoryPath cuts a path after depth names; depth <= 0 keeps the full path
+func truncateCategoryPath(path string, depth int) string {
+	if depth <= 0 || path == "" {
+		return path
+	}
+	names := strings.Split(path, categoryPathSep)
+	if len(names) > depth {
+		names = names[:depth]
+	}
+	return strings.Join(names, categoryPathSep)
+}
+
+// CategoryGroup is a category with its products
+type CategoryGroup struct {
+	Category string              `json:"category"`
+	Products CategorizedProducts `json:"products"`
+}
+
+// CategoryGroups is the result of GroupByCategory
+type CategoryGroups []CategoryGroup
+
+func (cg CategoryGroups) String() string {
+	var sb strings.Builder
+	for _, g := range cg {
+		sb.WriteString(sep(fmt.Sprintf("%s (%d)", g.Category, len(g.Products))))
+		sb.WriteByte('\n')
+		for _, p := range g.Products {
+			sb.WriteString("   " + p.Title + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// GroupByCategory groups the products by their most specific category, cut to depth
+// levels (1 = root categories, 0 = full path). Groups are sorted by path; products
+// without a known category come last.
+func (cps CategorizedProducts) GroupByCategory(depth int) CategoryGroups {
+	index := make(map[string]int)
+	var groups CategoryGroups
+	for _, cp := range cps {
+		category := truncateCategoryPath(cp.Category(), depth)
+		if category == "" {
+			category = uncategorized
+		}
+		i, ok := index[category]
+		if !ok {
+			i = len(groups)
+			index[category] = i
+			groups = append(groups, CategoryGroup{Category: category})
+		}
+		groups[i].Products = append(groups[i].Products, cp)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Category == uncategorized) != (groups[j].Category == uncategorized) {
+			return groups[j].Category == uncategorized
+		}
+		return groups[i].Category < groups[j].Category
+	})
+	return groups
+}
+
+// CategoryFacet is the number of products in a category
+type CategoryFacet struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// CategoryFacets is the result of Facets
+type CategoryFacets []CategoryFacet
+
+func (cf CategoryFacets) String() string {
+	var sb strings.Builder
+	for _, f := range cf {
+		sb.WriteString(fmt.Sprintf("%5d  %s\n", f.Count, f.Category))
+	}
+	return sb.String()
+}
+
+// Facets counts the products per category, cut to depth levels (0 = full path). Unlike
+// GroupByCategory, a product counts for each of its categories, once per category.
+// Facets are sorted by count, the largest first.
+func (cps CategorizedProducts) Facets(depth int) CategoryFacets {
+	counts := make(map[string]int)
+	for _, cp := range cps {
+		seen := make(map[string]bool)
+		for _, path := range cp.CategoryPaths {
+			category := truncateCategoryPath(path, depth)
+			if !seen[category] {
+				seen[category] = true
+				counts[category]++
+			}
+		}
+	}
+	facets := make(CategoryFacets, 0, len(counts))
+	for category, n := range counts {
+		facets = append(facets, CategoryFacet{Category: category, Count: n})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Category < facets[j].Category
+	})
+	return facets
+}
+
+// InCategory returns the products in the category or one of its subcategories, given
+// as path ("Obst & Gemüse > Obst") as returned by Facets
+func (cps CategorizedProducts) InCategory(category string) CategorizedProducts {
+	var filtered CategorizedProducts
+	for _, cp := range cps {
+		for _, path := range cp.CategoryPaths {
+			if path == category || strings.HasPrefix(path, category+categoryPathSep) {
+				filtered = append(filtered, cp)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,96 @@
+package rewerse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCategoryResolver(t *testing.T) {
+	var so ShopOverview
+	loadJSONFixture(t, "shop_overview.json", &so)
+	loads := 0
+	cr := &CategoryResolver{load: func() (ShopOverview, error) {
+		loads++
+		if loads == 1 {
+			return ShopOverview{}, errors.New("timeout")
+		}
+		return so, nil
+	}}
+
+	if _, err := cr.Path("3915"); err == nil {
+		t.Fatal("expected load error")
+	}
+	path, err := cr.Path("3915")
+	if err != nil || path.String() != "Bewusste Ernährung > Biologisch > Alles für das Bio-Müsli" {
+		t.Fatalf("unexpected path: %v (%v)", path, err)
+	}
+	if unknown, err := cr.Path("999"); err != nil || unknown != nil {
+		t.Errorf("expected nil path for unknown ID, got %v (%v)", unknown, err)
+	}
+
+	var suggestions []ProductSuggestion
+	loadJSONFixture(t, "product_suggestions.json", &suggestions)
+	if p, err := cr.SuggestionPath(suggestions[0]); err != nil || p != nil {
+		t.Errorf("category 3523 is not in the fixture, got %v (%v)", p, err)
+	}
+	if loads != 2 {
+		t.Errorf("expected the tree to be cached after the retry, got %d loads", loads)
+	}
+}
+
+func TestCategoryResolverDecorate(t *testing.T) {
+	var res productSearchResponse
+	loadJSONFixture(t, "product_search.json", &res)
+	products := append(res.Data.Products.Products,
+		Product{ProductID: "1", Title: "Bio Müsli", Categories: []string{"3862", "3914", "3915", "3666", "3679"}},
+		Product{ProductID: "2", Title: "Tofu", Categories: []string{"3679"}},
+		Product{ProductID: "3", Title: "Unbekannt", Categories: []string{"12"}},
+	)
+
+	var so ShopOverview
+	loadJSONFixture(t, "shop_overview.json", &so)
+	cr := NewCategoryResolverFromOverview(so)
+	decorated, err := cr.Decorate(products)
+	if err != nil {
+		t.Fatalf("Decorate failed: %v", err)
+	}
+	// the search results list their parent category 3844 as well
+	if got := decorated[0].CategoryPaths; !reflect.DeepEqual(got, []string{"Monats-Highlights > Neu im Sortiment"}) {
+		t.Errorf("unexpected paths: %v", got)
+	}
+	want := []string{
+		"Bewusste Ernährung > Biologisch > Alles für das Bio-Müsli",
+		"Bewusste Ernährung > Vegane Vielfalt > Vegane Basics",
+	}
+	if got := decorated[2].CategoryPaths; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected paths: %v", got)
+	}
+	if decorated[4].Category() != "" {
+		t.Errorf("expected no category, got %q", decorated[4].Category())
+	}
+
+	groups := decorated.GroupByCategory(1)
+	if len(groups) != 3 || groups[0].Category != "Bewusste Ernährung" || len(groups[0].Products) != 2 ||
+		groups[1].Category != "Monats-Highlights" || groups[2].Category != uncategorized {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+
+	facets := decorated.Facets(2)
+	wantFacets := CategoryFacets{
+		{"Bewusste Ernährung > Vegane Vielfalt", 2},
+		{"Monats-Highlights > Neu im Sortiment", 2},
+		{"Bewusste Ernährung > Biologisch", 1},
+	}
+	if !reflect.DeepEqual(facets, wantFacets) {
+		t.Errorf("Facets = %+v, want %+v", facets, wantFacets)
+	}
+
+	vegan := decorated.InCategory("Bewusste Ernährung > Vegane Vielfalt")
+	if len(vegan) != 2 || vegan[0].ProductID != "1" || vegan[1].ProductID != "2" {
+		t.Errorf("unexpected products: %+v", vegan)
+	}
+	if len(decorated.InCategory("Bewusste")) != 0 {
+		t.Error("partial names must not match")
+	}
+}
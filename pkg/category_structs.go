@@ -107,6 +107,9 @@ type ProductDetail struct {
 	Brand string `json:"brand"`
 	// NutritionFacts contains nutritional information
 	NutritionFacts []NutritionFact `json:"nutritionFacts"`
+	// NutriScore is the Nutri-Score supplied by REWE, usually null.
+	// Structure unknown when set, see NutriScoreGrade.
+	NutriScore any `json:"nutriScore"`
 }
 
 func (pd ProductDetail) String() string {
@@ -230,6 +233,11 @@ type NutritionFact struct {
 	PreparationState string `json:"preparationState"`
 	// NutrientInformation contains the individual nutrient values
 	NutrientInformation []NutrientInfo `json:"nutrientInformation"`
+	// ServingSize is the reference amount of the values: 100 g
+	ServingSize *struct {
+		Value        float64 `json:"value"`
+		UomShortText string  `json:"uomShortText"`
+	} `json:"servingSize"`
 }
 
 // NutrientInfo is a single nutrient value
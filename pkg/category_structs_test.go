@@ -15,6 +15,13 @@ func loadFixture(t *testing.T, name string) []byte {
 	return data
 }
 
+func loadJSONFixture(t *testing.T, name string, v any) {
+	t.Helper()
+	if err := json.Unmarshal(loadFixture(t, name), v); err != nil {
+		t.Fatalf("failed to unmarshal fixture %s: %v", name, err)
+	}
+}
+
 func TestProductSearchResponseUnmarshal(t *testing.T) {
 	var res productSearchResponse
 	if err := json.Unmarshal(loadFixture(t, "product_search.json"), &res); err != nil {
@@ -0,0 +1,370 @@
+package rewerse
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CategoryPath is the path from a root category to a category, i.e. its breadcrumbs
+type CategoryPath []ShopCategory
+
+func (p CategoryPath) String() string {
+	names := make([]string, len(p))
+	for i, c := range p {
+		names[i] = c.Name
+	}
+	return strings.Join(names, categoryPathSep)
+}
+
+// Category returns the last category of the path
+func (p CategoryPath) Category() ShopCategory {
+	if len(p) == 0 {
+		return ShopCategory{}
+	}
+	return p[len(p)-1]
+}
+
+// walkCategories calls fn for every category depth-first, down to maxCategoryDepth.
+// The path passed to fn may be kept; it's not modified afterwards.
+func walkCategories(categories []ShopCategory, path CategoryPath, fn func(CategoryPath)) {
+	if len(path) > maxCategoryDepth {
+		return
+	}
+	for _, c := range categories {
+		p := append(path[:len(path):len(path)], c)
+		fn(p)
+		walkCategories(c.ChildCategories, p, fn)
+	}
+}
+
+// Find returns the path to the category with the given ID or slug (case-insensitive)
+func (so ShopOverview) Find(idOrSlug string) (CategoryPath, bool) {
+	var found CategoryPath
+	walkCategories(so.ProductCategories, nil, func(p CategoryPath) {
+		c := p.Category()
+		if found == nil && (c.ID == idOrSlug || strings.EqualFold(c.Slug, idOrSlug)) {
+			found = p
+		}
+	})
+	return found, found != nil
+}
+
+// Search returns all categories whose name or slug contains the query, case-insensitive
+func (so ShopOverview) Search(query string) CategoryEntries {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var entries CategoryEntries
+	for _, e := range so.Flatten() {
+		if strings.Contains(strings.ToLower(e.Name), query) || strings.Contains(e.Slug, query) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Leaves returns the categories without children
+func (sc ShopCategory) Leaves() []ShopCategory {
+	if len(sc.ChildCategories) == 0 {
+		return []ShopCategory{sc}
+	}
+	var leaves []ShopCategory
+	walkCategories(sc.ChildCategories, nil, func(p CategoryPath) {
+		if c := p.Category(); len(c.ChildCategories) == 0 {
+			leaves = append(leaves, c)
+		}
+	})
+	return leaves
+}
+
+// LeafListingCount sums the ProductCount of the leaves, i.e. the listings in leaf
+// categories. It's not the product count of the category: a product listed in several
+// leaves is counted for each, and many products aren't assigned to a leaf at all, so the
+// API's ProductCount of a parent is usually much higher.
+func (sc ShopCategory) LeafListingCount() int {
+	total := 0
+	for _, c := range sc.Leaves() {
+		total += c.ProductCount
+	}
+	return total
+}
+
+// LeafListingCount sums the LeafListingCount of the root categories: the listings in
+// all leaf categories
+func (so ShopOverview) LeafListingCount() int {
+	total := 0
+	for _, c := range so.ProductCategories {
+		total += c.LeafListingCount()
+	}
+	return total
+}
+
+// CategoryEntry is a category without its children, as listed by Flatten
+type CategoryEntry struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parentId,omitempty"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	// Path are the names from the root: "Obst & Gemüse > Obst"
+	Path         string `json:"path"`
+	Depth        int    `json:"depth"`
+	ProductCount int    `json:"productCount"`
+	// LeafListingCount are the listings in the leaf categories below, see ShopCategory.LeafListingCount
+	LeafListingCount int  `json:"leafListingCount"`
+	Leaf             bool `json:"leaf"`
+}
+
+func (e CategoryEntry) String() string {
+	return fmt.Sprintf("%s (%s, %s) - %d products", e.Path, e.Slug, e.ID, e.ProductCount)
+}
+
+// CategoryEntries is a list of flattened categories
+type CategoryEntries []CategoryEntry
+
+func (ce CategoryEntries) String() string {
+	if len(ce) == 0 {
+		return "No categories found."
+	}
+	var sb strings.Builder
+	for _, e := range ce {
+		sb.WriteString(e.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func newCategoryEntry(p CategoryPath) CategoryEntry {
+	c := p.Category()
+	e := CategoryEntry{
+		ID:               c.ID,
+		Name:             c.Name,
+		Slug:             c.Slug,
+		Path:             p.String(),
+		Depth:            len(p) - 1,
+		ProductCount:     c.ProductCount,
+		LeafListingCount: c.LeafListingCount(),
+		Leaf:             len(c.ChildCategories) == 0,
+	}
+	if len(p) > 1 {
+		e.ParentID = p[len(p)-2].ID
+	}
+	return e
+}
+
+// Flatten returns all categories depth-first
+func (so ShopOverview) Flatten() CategoryEntries {
+	var entries CategoryEntries
+	walkCategories(so.ProductCategories, nil, func(p CategoryPath) {
+		entries = append(entries, newCategoryEntry(p))
+	})
+	return entries
+}
+
+// Leaves returns all categories without children, depth-first
+func (so ShopOverview) Leaves() CategoryEntries {
+	var leaves CategoryEntries
+	for _, e := range so.Flatten() {
+		if e.Leaf {
+			leaves = append(leaves, e)
+		}
+	}
+	return leaves
+}
+
+// CategoryChange is a category present in both trees of a diff
+type CategoryChange struct {
+	Old CategoryEntry `json:"old"`
+	New CategoryEntry `json:"new"`
+}
+
+// Renamed reports whether the name or slug changed
+func (cc CategoryChange) Renamed() bool {
+	return cc.Old.Name != cc.New.Name || cc.Old.Slug != cc.New.Slug
+}
+
+// Moved reports whether the category has a new parent
+func (cc CategoryChange) Moved() bool {
+	return cc.Old.ParentID != cc.New.ParentID
+}
+
+// CategoryDiff lists the changes between two category trees, matched by ID
+type CategoryDiff struct {
+	Added   CategoryEntries `json:"added"`
+	Removed CategoryEntries `json:"removed"`
+	// Changed are the renamed and moved categories
+	Changed []CategoryChange `json:"changed"`
+	// Counts are the categories whose ProductCount changed
+	Counts []CategoryChange `json:"counts"`
+}
+
+func (cd CategoryDiff) String() string {
+	var sb strings.Builder
+	sb.WriteString(sep(fmt.Sprintf("%d added, %d removed, %d changed", len(cd.Added), len(cd.Removed), len(cd.Changed))))
+	sb.WriteByte('\n')
+	for _, e := range cd.Added {
+		sb.WriteString("   + " + e.String() + "\n")
+	}
+	for _, e := range cd.Removed {
+		sb.WriteString("   - " + e.String() + "\n")
+	}
+	for _, c := range cd.Changed {
+		sb.WriteString(fmt.Sprintf("   ~ %s -> %s (%s)\n", c.Old.Path, c.New.Path, c.New.Slug))
+	}
+	if len(cd.Counts) > 0 {
+		sb.WriteString("\nProduct counts:\n")
+		for _, c := range cd.Counts {
+			sb.WriteString(fmt.Sprintf("   %s: %d -> %d (%+d)\n", c.New.Path, c.Old.ProductCount, c.New.ProductCount, c.New.ProductCount-c.Old.ProductCount))
+		}
+	}
+	return sb.String()
+}
+
+// DiffCategories compares two category trees, e.g. of the same market at different dates
+func DiffCategories(old, current ShopOverview) CategoryDiff {
+	var diff CategoryDiff
+	oldList := old.Flatten()
+	oldEntries := make(map[string]CategoryEntry, len(oldList))
+	for _, e := range oldList {
+		oldEntries[e.ID] = e
+	}
+	seen := make(map[string]bool)
+	for _, e := range current.Flatten() {
+		seen[e.ID] = true
+		prev, ok := oldEntries[e.ID]
+		if !ok {
+			diff.Added = append(diff.Added, e)
+			continue
+		}
+		change := CategoryChange{Old: prev, New: e}
+		if change.Renamed() || change.Moved() {
+			diff.Changed = append(diff.Changed, change)
+		}
+		if prev.ProductCount != e.ProductCount {
+			diff.Counts = append(diff.Counts, change)
+		}
+	}
+	for _, e := range oldList {
+		if !seen[e.ID] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+	sort.SliceStable(diff.Counts, func(i, j int) bool {
+		return abs(diff.Counts[i].New.ProductCount-diff.Counts[i].Old.ProductCount) >
+			abs(diff.Counts[j].New.ProductCount-diff.Counts[j].Old.ProductCount)
+	})
+	return diff
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CategoryFormat is an export format for category trees
+type CategoryFormat string
+
+const (
+	// CategoryJSON is the tree as returned by the API; ReadCategories reads it back
+	CategoryJSON CategoryFormat = "json"
+	// CategoryCSV has one row per category with its path and counts
+	CategoryCSV CategoryFormat = "csv"
+	// CategoryDOT is a Graphviz digraph
+	CategoryDOT CategoryFormat = "dot"
+)
+
+// ParseCategoryFormat validates a format name. "graphviz" is accepted as alias for dot.
+func ParseCategoryFormat(s string) (CategoryFormat, error) {
+	switch strings.ToLower(s) {
+	case "json":
+		return CategoryJSON, nil
+	case "csv":
+		return CategoryCSV, nil
+	case "dot", "graphviz", "gv":
+		return CategoryDOT, nil
+	}
+	return "", fmt.Errorf("invalid category format: %q (must be json, csv or dot)", s)
+}
+
+// ExportCategories writes the category tree as JSON, CSV or Graphviz DOT
+func ExportCategories(w io.Writer, so ShopOverview, format CategoryFormat) error {
+	switch format {
+	case CategoryJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		categories := so.ProductCategories
+		if categories == nil {
+			categories = []ShopCategory{}
+		}
+		return enc.Encode(categories)
+	case CategoryCSV:
+		return writeCategoriesCSV(w, so.Flatten())
+	case CategoryDOT:
+		return writeCategoriesDOT(w, so.Flatten())
+	}
+	return fmt.Errorf("invalid category format: %q", format)
+}
+
+func writeCategoriesCSV(w io.Writer, entries CategoryEntries) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "parentId", "slug", "name", "path", "depth", "productCount", "leafListingCount", "leaf"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		row := []string{e.ID, e.ParentID, e.Slug, e.Name, e.Path, strconv.Itoa(e.Depth),
+			strconv.Itoa(e.ProductCount), strconv.Itoa(e.LeafListingCount), strconv.FormatBool(e.Leaf)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ")
+
+// dotQuote quotes the lines as a DOT string, joined by DOT's \n line break
+func dotQuote(lines ...string) string {
+	for i, l := range lines {
+		lines[i] = dotEscaper.Replace(l)
+	}
+	return `"` + strings.Join(lines, `\n`) + `"`
+}
+
+func writeCategoriesDOT(w io.Writer, entries CategoryEntries) error {
+	var sb strings.Builder
+	sb.WriteString("digraph categories {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, e := range entries {
+		sb.WriteString(fmt.Sprintf("  %s [label=%s];\n", dotQuote(e.ID), dotQuote(e.Name, strconv.Itoa(e.ProductCount))))
+	}
+	for _, e := range entries {
+		if e.ParentID != "" {
+			sb.WriteString(fmt.Sprintf("  %s -> %s;\n", dotQuote(e.ParentID), dotQuote(e.ID)))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ReadCategories reads a tree written by ExportCategories as JSON, or a shop overview
+func ReadCategories(r io.Reader) (ShopOverview, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ShopOverview{}, err
+	}
+	var so ShopOverview
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &so.ProductCategories)
+	} else {
+		err = json.Unmarshal(data, &so)
+	}
+	if err != nil {
+		return ShopOverview{}, fmt.Errorf("invalid category tree: %w", err)
+	}
+	return so, nil
+}
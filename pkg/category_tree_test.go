@@ -0,0 +1,140 @@
+package rewerse
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestCategoryFind(t *testing.T) {
+	var so ShopOverview
+	loadJSONFixture(t, "shop_overview.json", &so)
+
+	path, ok := so.Find("alles-fuer-das-bio-muesli")
+	if !ok || path.String() != "Bewusste Ernährung > Biologisch > Alles für das Bio-Müsli" {
+		t.Fatalf("unexpected path: %v (%v)", path, ok)
+	}
+	if byID, ok := so.Find("3915"); !ok || byID.Category().Slug != "alles-fuer-das-bio-muesli" {
+		t.Errorf("find by ID failed: %v", byID)
+	}
+	if _, ok := so.Find("obst-gemuese"); ok {
+		t.Error("unexpected match for missing slug")
+	}
+
+	found := so.Search("vegan")
+	if len(found) != 2 || found[0].Slug != "vegane-vielfalt" || found[1].ParentID != "3666" || found[1].Depth != 2 {
+		t.Errorf("unexpected search result: %+v", found)
+	}
+}
+
+func TestCategoryLeavesAndCounts(t *testing.T) {
+	var so ShopOverview
+	loadJSONFixture(t, "shop_overview.json", &so)
+
+	leaves := so.Leaves()
+	var slugs []string
+	for _, l := range leaves {
+		slugs = append(slugs, l.Slug)
+	}
+	if got := strings.Join(slugs, ","); got != "neu-im-sortiment,fit-ins-neue-jahr,alles-fuer-das-bio-muesli,vegane-basics" {
+		t.Errorf("unexpected leaves: %s", got)
+	}
+
+	if got := so.ProductCategories[0].LeafListingCount(); got != 97+231 {
+		t.Errorf("LeafListingCount = %d, want %d", got, 97+231)
+	}
+	if got := so.LeafListingCount(); got != 97+231+81+53 {
+		t.Errorf("overview LeafListingCount = %d", got)
+	}
+	// the leaves only cover a fraction of the parent's products
+	if c := so.ProductCategories[1]; c.ProductCount != 1724 || c.LeafListingCount() != 81+53 {
+		t.Errorf("expected %s with 1724 products and %d in leaves, got %d and %d", c.Name, 81+53, c.ProductCount, c.LeafListingCount())
+	}
+	if len(so.Flatten()) != 8 {
+		t.Errorf("expected 8 categories, got %d", len(so.Flatten()))
+	}
+}
+
+func TestDiffCategories(t *testing.T) {
+	var old, current ShopOverview
+	loadJSONFixture(t, "shop_overview.json", &old)
+	loadJSONFixture(t, "shop_overview.json", &current)
+
+	// count changed, renamed and added below the highlights
+	highlights := &current.ProductCategories[0]
+	highlights.ChildCategories[0].ProductCount = 120
+	highlights.ChildCategories[1].Name = "Fit in den Frühling"
+	highlights.ChildCategories = append(highlights.ChildCategories,
+		ShopCategory{ID: "4001", Name: "Ostern", Slug: "ostern", ProductCount: 80})
+
+	// vegane-vielfalt moved to the highlights, without its child
+	vegan := current.ProductCategories[1].ChildCategories[1]
+	current.ProductCategories[1].ChildCategories = current.ProductCategories[1].ChildCategories[:1]
+	vegan.ChildCategories = nil
+	highlights.ChildCategories = append(highlights.ChildCategories, vegan)
+
+	diff := DiffCategories(old, current)
+	if len(diff.Added) != 1 || diff.Added[0].Slug != "ostern" {
+		t.Errorf("unexpected added: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Slug != "vegane-basics" {
+		t.Errorf("unexpected removed: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 2 || !diff.Changed[0].Renamed() || !diff.Changed[1].Moved() {
+		t.Errorf("unexpected changes: %+v", diff.Changed)
+	}
+	if len(diff.Counts) != 1 || diff.Counts[0].New.ProductCount != 120 {
+		t.Errorf("unexpected count changes: %+v", diff.Counts)
+	}
+	if !strings.Contains(diff.String(), "1 added, 1 removed, 2 changed") {
+		t.Errorf("unexpected String():\n%s", diff)
+	}
+}
+
+func TestExportCategories(t *testing.T) {
+	var so ShopOverview
+	loadJSONFixture(t, "shop_overview.json", &so)
+
+	var buf bytes.Buffer
+	if err := ExportCategories(&buf, so, CategoryJSON); err != nil {
+		t.Fatalf("JSON export failed: %v", err)
+	}
+	read, err := ReadCategories(&buf)
+	if err != nil || len(read.Flatten()) != 8 {
+		t.Errorf("JSON roundtrip failed: %v", err)
+	}
+	if wrapped, err := ReadCategories(bytes.NewReader(loadFixture(t, "shop_overview.json"))); err != nil || len(wrapped.ProductCategories) != 2 {
+		t.Errorf("reading shop overview failed: %v", err)
+	}
+
+	buf.Reset()
+	if err := ExportCategories(&buf, so, CategoryCSV); err != nil {
+		t.Fatalf("CSV export failed: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 9 {
+		t.Fatalf("unexpected CSV: %v (%v)", rows, err)
+	}
+	if strings.Join(rows[4], "|") != "3862||bewusste-ernaehrung|Bewusste Ernährung|Bewusste Ernährung|0|1724|134|false" {
+		t.Errorf("unexpected CSV row: %v", rows[4])
+	}
+
+	buf.Reset()
+	if err := ExportCategories(&buf, so, CategoryDOT); err != nil {
+		t.Fatalf("DOT export failed: %v", err)
+	}
+	dot := buf.String()
+	for _, want := range []string{"digraph categories {", `"3914" [label="Biologisch\n700"];`, `"3914" -> "3915";`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output misses %q:\n%s", want, dot)
+		}
+	}
+
+	if _, err := ParseCategoryFormat("graphviz"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseCategoryFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,337 @@
+package rewerse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CoverageEntry is the service availability of one zip code
+type CoverageEntry struct {
+	ZipCode string `json:"zipCode"`
+	// DeliveryMarket is the ID of the market delivering to the zip code ("" = no delivery)
+	DeliveryMarket string `json:"deliveryMarket,omitempty"`
+	// PickupMarkets are the IDs of the markets offering pickup for the zip code
+	PickupMarkets []string `json:"pickupMarkets,omitempty"`
+	// Error is set if the zip code could not be queried
+	Error string `json:"error,omitempty"`
+}
+
+// NewCoverageEntry extracts the coverage of a service portfolio
+func NewCoverageEntry(sp ServicePortfolio) CoverageEntry {
+	e := CoverageEntry{ZipCode: sp.CustomerZipCode}
+	if sp.DeliveryMarket != nil {
+		e.DeliveryMarket = sp.DeliveryMarket.WWIdent
+	}
+	for _, pm := range sp.PickupMarkets {
+		e.PickupMarkets = append(e.PickupMarkets, pm.WWIdent)
+	}
+	return e
+}
+
+// Coverage is a zip code -> delivery/pickup dataset
+type Coverage []CoverageEntry
+
+func (c Coverage) String() string {
+	var delivery, pickupOnly, none, failed int
+	for _, e := range c {
+		switch {
+		case e.Error != "":
+			failed++
+		case e.DeliveryMarket != "":
+			delivery++
+		case len(e.PickupMarkets) > 0:
+			pickupOnly++
+		default:
+			none++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sep(fmt.Sprintf("Abdeckung (%d PLZ)", len(c))))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Lieferung"))
+	sb.WriteString(strconv.Itoa(delivery))
+	sb.WriteString("\n")
+	sb.WriteString(align("Nur Abholung"))
+	sb.WriteString(strconv.Itoa(pickupOnly))
+	sb.WriteString("\n")
+	sb.WriteString(align("Kein Service"))
+	sb.WriteString(strconv.Itoa(none))
+	sb.WriteString("\n")
+	if failed > 0 {
+		sb.WriteString(align("Fehler"))
+		sb.WriteString(strconv.Itoa(failed))
+		sb.WriteString("\n")
+	}
+
+	markets := c.DeliveryMarkets()
+	if len(markets) > 0 {
+		ids := make([]string, 0, len(markets))
+		for id := range markets {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if len(markets[ids[i]]) != len(markets[ids[j]]) {
+				return len(markets[ids[i]]) > len(markets[ids[j]])
+			}
+			return ids[i] < ids[j]
+		})
+		sb.WriteString("\nLiefermärkte:\n")
+		for _, id := range ids {
+			sb.WriteString(fmt.Sprintf("   %s: %d PLZ\n", id, len(markets[id])))
+		}
+	}
+	return sb.String()
+}
+
+// DeliveryMarkets groups the zip codes by the market delivering to them
+func (c Coverage) DeliveryMarkets() map[string][]string {
+	markets := make(map[string][]string)
+	for _, e := range c {
+		if e.DeliveryMarket != "" {
+			markets[e.DeliveryMarket] = append(markets[e.DeliveryMarket], e.ZipCode)
+		}
+	}
+	return markets
+}
+
+// Sort orders the entries by zip code
+func (c Coverage) Sort() {
+	sort.SliceStable(c, func(i, j int) bool { return c[i].ZipCode < c[j].ZipCode })
+}
+
+// WriteCSV writes the dataset with the columns zipCode, deliveryMarket, pickupMarkets
+// (space-separated) and error
+func (c Coverage) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"zipCode", "deliveryMarket", "pickupMarkets", "error"}); err != nil {
+		return err
+	}
+	for _, e := range c {
+		if err := cw.Write([]string{e.ZipCode, e.DeliveryMarket, strings.Join(e.PickupMarkets, " "), e.Error}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the dataset as a JSON array
+func (c Coverage) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if c == nil {
+		c = Coverage{}
+	}
+	return enc.Encode(c)
+}
+
+// ZipRange returns the zip codes from..to (inclusive): "50000", "51999"
+func ZipRange(from, to string) ([]string, error) {
+	start, err := parseZip(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseZip(to)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		return nil, fmt.Errorf("invalid zip range: %s > %s", from, to)
+	}
+	zips := make([]string, 0, end-start+1)
+	for z := start; z <= end; z++ {
+		zips = append(zips, fmt.Sprintf("%05d", z))
+	}
+	return zips, nil
+}
+
+func parseZip(s string) (int, error) {
+	if len(s) != 5 {
+		return 0, fmt.Errorf("invalid zip code: %q (must be 5 digits)", s)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid zip code: %q (must be 5 digits)", s)
+	}
+	return n, nil
+}
+
+// ScanOpts configures ScanCoverage
+type ScanOpts struct {
+	// RateLimit bounds the concurrent requests (default: DefaultRateLimit)
+	RateLimit *RateLimit
+	// Done contains the results of an earlier scan. Their zip codes are not queried again,
+	// except for failed ones.
+	Done Coverage
+	// OnResult is called after each queried zip code, one call at a time
+	OnResult func(CoverageEntry)
+}
+
+// ScanCoverage queries the service portfolio of every zip code concurrently. Failed zip
+// codes are recorded with their error instead of aborting the scan. The result includes
+// the reused entries of opts.Done and is sorted by zip code.
+func ScanCoverage(zips []string, opts *ScanOpts) (Coverage, error) {
+	return scanCoverage(zips, opts, GetServicePortfolio)
+}
+
+func scanCoverage(zips []string, opts *ScanOpts, fetch func(zip string) (ServicePortfolio, error)) (Coverage, error) {
+	if opts == nil {
+		opts = &ScanOpts{}
+	}
+	for _, zip := range zips {
+		if _, err := parseZip(zip); err != nil {
+			return nil, err
+		}
+	}
+
+	todo, coverage := splitPending(zips, opts.Done)
+	results := make(Coverage, len(todo))
+	var mu sync.Mutex
+	opts.RateLimit.run(len(todo), func(i int) {
+		e := CoverageEntry{ZipCode: todo[i]}
+		sp, err := fetch(todo[i])
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e = NewCoverageEntry(sp)
+			e.ZipCode = todo[i]
+		}
+		results[i] = e
+
+		if opts.OnResult != nil {
+			mu.Lock()
+			opts.OnResult(e)
+			mu.Unlock()
+		}
+	})
+
+	coverage = append(coverage, results...)
+	coverage.Sort()
+	return coverage, nil
+}
+
+// PendingZips returns the zip codes ScanCoverage will query: zips without duplicates and
+// without those successfully scanned in done
+func PendingZips(zips []string, done Coverage) []string {
+	todo, _ := splitPending(zips, done)
+	return todo
+}
+
+// splitPending splits zips into the ones to query and the reused entries of done
+func splitPending(zips []string, done Coverage) (todo []string, reused Coverage) {
+	ok := make(map[string]CoverageEntry, len(done))
+	for _, e := range done {
+		if e.Error == "" {
+			ok[e.ZipCode] = e
+		}
+	}
+	seen := make(map[string]bool, len(zips))
+	for _, zip := range zips {
+		if seen[zip] {
+			continue
+		}
+		seen[zip] = true
+		if e, found := ok[zip]; found {
+			reused = append(reused, e)
+		} else {
+			todo = append(todo, zip)
+		}
+	}
+	return todo, reused
+}
+
+// CoverageLog is a progress file of a scan with one JSON entry per line. Entries are
+// appended as they arrive, so an interrupted scan can be resumed from it.
+type CoverageLog struct {
+	f  *os.File
+	mu sync.Mutex
+}
+
+// OpenCoverageLog opens or creates the progress file at path and returns the entries
+// it already contains. Pass them as ScanOpts.Done to resume.
+func OpenCoverageLog(path string) (*CoverageLog, Coverage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	existing, err := ReadCoverage(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	// drop a line cut off by an interrupted write, so new entries start on their own line
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if err := os.Truncate(path, int64(bytes.LastIndexByte(data, '\n')+1)); err != nil {
+			return nil, nil, fmt.Errorf("error repairing %s: %w", path, err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	return &CoverageLog{f: f}, existing, nil
+}
+
+// Append writes an entry to the progress file
+func (l *CoverageLog) Append(e CoverageEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the progress file
+func (l *CoverageLog) Close() error {
+	return l.f.Close()
+}
+
+// ReadCoverage reads entries written by CoverageLog. Later entries for a zip code replace
+// earlier ones, so retried zip codes keep their latest result. A truncated last line from
+// an interrupted write is ignored.
+func ReadCoverage(r io.Reader) (Coverage, error) {
+	index := make(map[string]int)
+	var c Coverage
+	var pending error
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if pending != nil {
+			return nil, pending
+		}
+		var e CoverageEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			pending = fmt.Errorf("invalid coverage entry: %w", err)
+			continue
+		}
+		if i, ok := index[e.ZipCode]; ok {
+			c[i] = e
+			continue
+		}
+		index[e.ZipCode] = len(c)
+		c = append(c, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,133 @@
+package rewerse
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestZipRange(t *testing.T) {
+	zips, err := ZipRange("01998", "02001")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(zips, ",") != "01998,01999,02000,02001" {
+		t.Errorf("unexpected range: %v", zips)
+	}
+	for _, r := range [][2]string{{"5000", "51999"}, {"51999", "50000"}, {"5000x", "50001"}} {
+		if _, err := ZipRange(r[0], r[1]); err == nil {
+			t.Errorf("ZipRange(%s, %s): expected error", r[0], r[1])
+		}
+	}
+}
+
+func fakePortfolio(zip string) (ServicePortfolio, error) {
+	sp := ServicePortfolio{CustomerZipCode: zip}
+	switch zip {
+	case "50667", "50668":
+		sp.DeliveryMarket = &struct {
+			WWIdent string `json:"wwIdent"`
+		}{WWIdent: "831002"}
+		sp.PickupMarkets = []PickupMarket{{WWIdent: "831002"}, {WWIdent: "540528"}}
+	case "50669":
+		sp.PickupMarkets = []PickupMarket{{WWIdent: "540528"}}
+	case "50670":
+		return sp, errors.New("boom")
+	}
+	return sp, nil
+}
+
+func TestScanCoverage(t *testing.T) {
+	var calls int32
+	fetch := func(zip string) (ServicePortfolio, error) {
+		atomic.AddInt32(&calls, 1)
+		return fakePortfolio(zip)
+	}
+	var results int
+	opts := &ScanOpts{
+		RateLimit: &RateLimit{Workers: 3},
+		Done: Coverage{
+			{ZipCode: "50668", DeliveryMarket: "831002"},
+			{ZipCode: "50670", Error: "timeout"},
+			{ZipCode: "99999"},
+		},
+		OnResult: func(CoverageEntry) { results++ },
+	}
+	zips := []string{"50671", "50667", "50668", "50669", "50670", "50667"}
+	coverage, err := scanCoverage(zips, opts, fetch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 50668 is reused, 50670 is retried, the duplicate 50667 is queried once
+	if calls != 4 || results != 4 {
+		t.Errorf("expected 4 requests and results, got %d and %d", calls, results)
+	}
+	if pending := PendingZips(zips, opts.Done); len(pending) != int(calls) {
+		t.Errorf("PendingZips: expected %d zip codes, got %v", calls, pending)
+	}
+	if len(coverage) != 5 || coverage[0].ZipCode != "50667" || coverage[4].ZipCode != "50671" {
+		t.Fatalf("unexpected coverage: %+v", coverage)
+	}
+	if e := coverage[0]; e.DeliveryMarket != "831002" || len(e.PickupMarkets) != 2 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e := coverage[3]; e.ZipCode != "50670" || e.Error != "boom" {
+		t.Errorf("expected failed entry, got %+v", e)
+	}
+	if got := coverage.DeliveryMarkets()["831002"]; len(got) != 2 {
+		t.Errorf("expected 831002 to deliver to 2 zips, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := coverage.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || lines[1] != "50667,831002,831002 540528," {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+
+	if _, err := scanCoverage([]string{"5066"}, nil, fetch); err == nil {
+		t.Error("expected error for invalid zip code")
+	}
+}
+
+func TestCoverageLogResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.jsonl")
+
+	l, done, err := OpenCoverageLog(path)
+	if err != nil || len(done) != 0 {
+		t.Fatalf("OpenCoverageLog: %v, %v", done, err)
+	}
+	_ = l.Append(CoverageEntry{ZipCode: "50667", Error: "timeout"})
+	_ = l.Append(CoverageEntry{ZipCode: "50669", PickupMarkets: []string{"540528"}})
+	_ = l.Append(CoverageEntry{ZipCode: "50667", DeliveryMarket: "831002"})
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// simulate a write interrupted mid-line
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`{"zipCode":"506`)
+	_ = f.Close()
+
+	l, done, err = OpenCoverageLog(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if len(done) != 2 || done[0].DeliveryMarket != "831002" || done[1].ZipCode != "50669" {
+		t.Errorf("unexpected entries: %+v", done)
+	}
+	_ = l.Append(CoverageEntry{ZipCode: "50670"})
+	_ = l.Close()
+
+	_, done, err = OpenCoverageLog(path)
+	if err != nil || len(done) != 3 {
+		t.Errorf("expected 3 entries after resuming, got %+v (%v)", done, err)
+	}
+}
@@ -46,4 +46,15 @@
 //	session, _ := rewerse.CreateBasket("840174", "67065", rewerse.ServicePickup)
 //	session.SetItemQuantity("listing-id", 2)
 //	basket, _ := session.GetBasket()
+//
+// Several items can be set at once, or imported from a shopping list:
+//
+//	session.ApplyItems(map[string]int{"listing-a": 2, "listing-b": 1})
+//	result, _ := session.ImportShoppingList("2x Milch, Bananen 1kg")
+//
+// Sessions can be persisted with a SessionStore and resumed in another process:
+//
+//	store := rewerse.NewFileSessionStore("basket.json")
+//	store.Save(session)
+//	session, _ = rewerse.ResumeBasket(store)
 package rewerse
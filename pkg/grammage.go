@@ -0,0 +1,195 @@
+package rewerse
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unitDim is the physical dimension of an amount, used to compare recipe quantities
+// with product pack sizes
+type unitDim int
+
+const (
+	dimUnknown unitDim = iota
+	dimMass            // base unit: g
+	dimVolume          // base unit: ml
+	dimCount           // base unit: pieces
+)
+
+// packSizeRegex matches pack sizes in grammage strings: "150g", "1,5 l", "4 x 125 g", "6 Stück"
+var packSizeRegex = regexp.MustCompile(`(?i)(?:(\d+)\s*x\s*)?(\d+(?:[.,]\d+)?)\s*(kg|g|ml|cl|l|stück|stk\.?|st\.)(?:\s|$|\(|,)`)
+
+// basePriceRegex matches the reference price in grammage strings: "(1 kg = 30,60 EUR)", "(1 l = 1,98 €)"
+var basePriceRegex = regexp.MustCompile(`(?i)\(\s*(\d+(?:[.,]\d+)?)?\s*(kg|g|ml|l|stück|stk\.?|st\.)\s*=\s*(\d+(?:[.,]\d+)?)\s*(?:€|eur)`)
+
+// baseUnits maps lowercase unit spellings to their dimension and factor to the base unit
+var baseUnits = map[string]struct {
+	dim    unitDim
+	factor float64
+}{
+	"g":     {dimMass, 1},
+	"kg":    {dimMass, 1000},
+	"ml":    {dimVolume, 1},
+	"cl":    {dimVolume, 10},
+	"l":     {dimVolume, 1000},
+	"stück": {dimCount, 1},
+	"stk":   {dimCount, 1},
+	"stk.":  {dimCount, 1},
+	"st.":   {dimCount, 1},
+}
+
+// priceUnits are the units unit prices are expressed in, per dimension
+var priceUnits = map[unitDim]struct {
+	unit   string
+	factor float64
+}{
+	dimMass:   {"kg", 1000},
+	dimVolume: {"l", 1000},
+	dimCount:  {"Stück", 1},
+}
+
+// Grammage is a parsed Listing.Grammage string like "4 x 125 g (1 kg = 5,98 EUR)"
+type Grammage struct {
+	// Raw is the original string
+	Raw string
+	// Quantity is the pack size in Unit, multipacks multiplied out: 500
+	Quantity float64
+	// Unit is the unit of Quantity as written, lowercase: "g", "l", "stück"
+	Unit string
+	// BasePrice is the declared price per BaseUnit in cents (0 if not declared): 598
+	BasePrice int
+	// BaseUnit is the unit of BasePrice: "kg", "l", "Stück"
+	BaseUnit string
+
+	dim    unitDim
+	amount float64 // Quantity in the base unit (g, ml, pieces)
+}
+
+// ParseGrammage parses a grammage string. The reference price in parentheses is optional.
+func ParseGrammage(s string) (Grammage, error) {
+	g := Grammage{Raw: s}
+
+	m := packSizeRegex.FindStringSubmatch(s + " ")
+	if m == nil {
+		return g, fmt.Errorf("invalid grammage: %q", s)
+	}
+	value, err := parseDecimal(m[2])
+	if err != nil || value <= 0 {
+		return g, fmt.Errorf("invalid grammage: %q", s)
+	}
+	if m[1] != "" {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			value *= float64(n)
+		}
+	}
+	unit := strings.ToLower(m[3])
+	u, known := baseUnits[unit]
+	if !known {
+		return g, fmt.Errorf("invalid grammage unit: %q", m[3])
+	}
+	g.Quantity = value
+	g.Unit = unit
+	g.dim = u.dim
+	g.amount = value * u.factor
+
+	if bm := basePriceRegex.FindStringSubmatch(s); bm != nil {
+		ref := 1.0
+		if bm[1] != "" {
+			ref, _ = parseDecimal(bm[1])
+		}
+		price, err := parseDecimal(bm[3])
+		bu, known := baseUnits[strings.ToLower(bm[2])]
+		if err == nil && known && ref > 0 && bu.dim == g.dim {
+			// normalize "100 g = 1,20 €" to the price unit of the dimension (kg)
+			pu := priceUnits[bu.dim]
+			g.BasePrice = int(math.Round(price * 100 * pu.factor / (ref * bu.factor)))
+			g.BaseUnit = pu.unit
+		}
+	}
+	return g, nil
+}
+
+// parseDecimal parses German decimals: "30,60"
+func parseDecimal(s string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+}
+
+// UnitPrice returns the price per kg, l or Stück in cents. The declared base price is used
+// if present, otherwise it is computed from the pack price and size.
+func (g Grammage) UnitPrice(priceCents int) (cents float64, unit string, ok bool) {
+	if g.BasePrice > 0 {
+		return float64(g.BasePrice), g.BaseUnit, true
+	}
+	pu, known := priceUnits[g.dim]
+	if !known || g.amount <= 0 || priceCents <= 0 {
+		return 0, "", false
+	}
+	return float64(priceCents) * pu.factor / g.amount, pu.unit, true
+}
+
+// parsePackSize extracts the pack size from a grammage string like "150g (1 kg = 30,60 EUR)".
+// Multipacks ("4 x 125 g") are multiplied out. Returns the amount in the base unit.
+func parsePackSize(grammage string) (amount float64, dim unitDim, ok bool) {
+	g, err := ParseGrammage(grammage)
+	if err != nil {
+		return 0, dimUnknown, false
+	}
+	return g.amount, g.dim, true
+}
+
+// UnitPriceEntry is a product with its price per kg, l or Stück
+type UnitPriceEntry struct {
+	Product Product `json:"product"`
+	// Cents is the price per Unit in cents (0 if unknown)
+	Cents float64 `json:"cents"`
+	// Unit is "kg", "l" or "Stück" (empty if unknown)
+	Unit string `json:"unit"`
+}
+
+// UnitPriceRanking is a list of products ordered by unit price
+type UnitPriceRanking []UnitPriceEntry
+
+func (r UnitPriceRanking) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep(fmt.Sprintf("Grundpreis (%d)", len(r))))
+	sb.WriteByte('\n')
+	for _, e := range r {
+		unitPrice := "-"
+		if e.Unit != "" {
+			unitPrice = fmt.Sprintf("%.2f €/%s", e.Cents/100, e.Unit)
+		}
+		sb.WriteString(fmt.Sprintf("   %12s  %6.2f €  %s (%s)\n", unitPrice,
+			centsToEuros(e.Product.Listing.CurrentRetailPrice), e.Product.Title, e.Product.Listing.Grammage))
+	}
+
+	return sb.String()
+}
+
+// RankByUnitPrice orders products by their price per kg, l or Stück, cheapest first.
+// Products are grouped by unit (kg before l before Stück) so prices stay comparable;
+// products without a parseable grammage come last in their original order.
+func RankByUnitPrice(products []Product) UnitPriceRanking {
+	ranking := make(UnitPriceRanking, 0, len(products))
+	for _, p := range products {
+		e := UnitPriceEntry{Product: p}
+		if g, err := ParseGrammage(p.Listing.Grammage); err == nil {
+			e.Cents, e.Unit, _ = g.UnitPrice(p.Listing.CurrentRetailPrice)
+		}
+		ranking = append(ranking, e)
+	}
+
+	unitOrder := map[string]int{"kg": 0, "l": 1, "Stück": 2, "": 3}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if a.Unit != b.Unit {
+			return unitOrder[a.Unit] < unitOrder[b.Unit]
+		}
+		return a.Cents < b.Cents
+	})
+	return ranking
+}
@@ -0,0 +1,74 @@
+package rewerse
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseGrammage(t *testing.T) {
+	tests := []struct {
+		in        string
+		quantity  float64
+		unit      string
+		basePrice int
+		baseUnit  string
+	}{
+		{"150g (1 kg = 30,60 EUR)", 150, "g", 3060, "kg"},
+		{"250g (1 kg = 19,96 €)", 250, "g", 1996, "kg"},
+		{"1,5 l (1 l = 0,66 EUR)", 1.5, "l", 66, "l"},
+		{"4 x 125 g (1 kg = 5,98 EUR)", 500, "g", 598, "kg"},
+		{"6 Stück (1 Stück = 0,33 €)", 6, "stück", 33, "Stück"},
+		{"100g (100 g = 1,29 €)", 100, "g", 1290, "kg"},
+		{"1kg", 1, "kg", 0, ""},
+	}
+	for _, tt := range tests {
+		g, err := ParseGrammage(tt.in)
+		if err != nil {
+			t.Errorf("ParseGrammage(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if g.Quantity != tt.quantity || g.Unit != tt.unit || g.BasePrice != tt.basePrice || g.BaseUnit != tt.baseUnit {
+			t.Errorf("ParseGrammage(%q) = %v %s, %d/%s; want %v %s, %d/%s", tt.in,
+				g.Quantity, g.Unit, g.BasePrice, g.BaseUnit, tt.quantity, tt.unit, tt.basePrice, tt.baseUnit)
+		}
+	}
+
+	if _, err := ParseGrammage("lose Ware"); err == nil {
+		t.Error("expected error for grammage without size")
+	}
+}
+
+func TestGrammageUnitPrice(t *testing.T) {
+	// no declared base price: computed from pack price
+	g, err := ParseGrammage("500 ml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cents, unit, ok := g.UnitPrice(129)
+	if !ok || unit != "l" || math.Abs(cents-258) > 1e-9 {
+		t.Errorf("expected 258 cents/l, got %v/%s (%v)", cents, unit, ok)
+	}
+
+	// declared base price wins
+	g, _ = ParseGrammage("150g (1 kg = 30,60 EUR)")
+	if cents, unit, _ := g.UnitPrice(1); cents != 3060 || unit != "kg" {
+		t.Errorf("expected declared 3060 cents/kg, got %v/%s", cents, unit)
+	}
+}
+
+func TestRankByUnitPrice(t *testing.T) {
+	ranking := RankByUnitPrice([]Product{
+		testProduct("Eier", "10 Stück", 299),
+		testProduct("Milch groß", "1,5 l", 189),
+		testProduct("unbekannt", "", 100),
+		testProduct("Milch klein", "0,5 l (1 l = 2,38 €)", 119),
+		testProduct("Käse", "400g (1 kg = 8,98 €)", 359),
+	})
+
+	want := []string{"Käse", "Milch groß", "Milch klein", "Eier", "unbekannt"}
+	for i, e := range ranking {
+		if e.Product.Title != want[i] {
+			t.Errorf("position %d: expected %s, got %s", i, want[i], e.Product.Title)
+		}
+	}
+}
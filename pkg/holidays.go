@@ -0,0 +1,181 @@
+package rewerse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FederalState is a German federal state, identified by its ISO 3166-2 suffix: "BW", "NW"
+type FederalState string
+
+const (
+	StateBadenWuerttemberg     FederalState = "BW"
+	StateBayern                FederalState = "BY"
+	StateBerlin                FederalState = "BE"
+	StateBrandenburg           FederalState = "BB"
+	StateBremen                FederalState = "HB"
+	StateHamburg               FederalState = "HH"
+	StateHessen                FederalState = "HE"
+	StateMecklenburgVorpommern FederalState = "MV"
+	StateNiedersachsen         FederalState = "NI"
+	StateNordrheinWestfalen    FederalState = "NW"
+	StateRheinlandPfalz        FederalState = "RP"
+	StateSaarland              FederalState = "SL"
+	StateSachsen               FederalState = "SN"
+	StateSachsenAnhalt         FederalState = "ST"
+	StateSchleswigHolstein     FederalState = "SH"
+	StateThueringen            FederalState = "TH"
+)
+
+// FederalStates lists all federal states
+var FederalStates = []FederalState{
+	StateBadenWuerttemberg, StateBayern, StateBerlin, StateBrandenburg, StateBremen, StateHamburg,
+	StateHessen, StateMecklenburgVorpommern, StateNiedersachsen, StateNordrheinWestfalen,
+	StateRheinlandPfalz, StateSaarland, StateSachsen, StateSachsenAnhalt, StateSchleswigHolstein,
+	StateThueringen,
+}
+
+// ParseFederalState parses a state code, case-insensitive: "nw"
+func ParseFederalState(s string) (FederalState, error) {
+	for _, st := range FederalStates {
+		if strings.EqualFold(s, string(st)) {
+			return st, nil
+		}
+	}
+	return "", fmt.Errorf("invalid federal state: %q", s)
+}
+
+// holiday is a public holiday; states == nil means nationwide
+type holiday struct {
+	name string
+	// date returns month and day in the given year
+	date   func(year int) (time.Month, int)
+	states []FederalState
+}
+
+func fixedDate(month time.Month, day int) func(int) (time.Month, int) {
+	return func(int) (time.Month, int) { return month, day }
+}
+
+// easterOffset returns the date the given number of days after Easter Sunday
+func easterOffset(days int) func(int) (time.Month, int) {
+	return func(year int) (time.Month, int) {
+		d := easterSunday(year).AddDate(0, 0, days)
+		return d.Month(), d.Day()
+	}
+}
+
+// holidays are the public holidays on which shops are closed. Holidays that only apply
+// to some municipalities of a state (e.g. Fronleichnam in parts of Sachsen) are left out.
+// Holidays on Sundays (Ostersonntag, Pfingstsonntag) don't matter for opening hours.
+var holidays = []holiday{
+	{"Neujahr", fixedDate(time.January, 1), nil},
+	{"Heilige Drei Könige", fixedDate(time.January, 6), []FederalState{StateBadenWuerttemberg, StateBayern, StateSachsenAnhalt}},
+	{"Internationaler Frauentag", fixedDate(time.March, 8), []FederalState{StateBerlin, StateMecklenburgVorpommern}},
+	{"Karfreitag", easterOffset(-2), nil},
+	{"Ostermontag", easterOffset(1), nil},
+	{"Tag der Arbeit", fixedDate(time.May, 1), nil},
+	{"Christi Himmelfahrt", easterOffset(39), nil},
+	{"Pfingstmontag", easterOffset(50), nil},
+	{"Fronleichnam", easterOffset(60), []FederalState{StateBadenWuerttemberg, StateBayern, StateHessen, StateNordrheinWestfalen, StateRheinlandPfalz, StateSaarland}},
+	{"Mariä Himmelfahrt", fixedDate(time.August, 15), []FederalState{StateSaarland}},
+	{"Weltkindertag", fixedDate(time.September, 20), []FederalState{StateThueringen}},
+	{"Tag der Deutschen Einheit", fixedDate(time.October, 3), nil},
+	{"Reformationstag", fixedDate(time.October, 31), []FederalState{StateBrandenburg, StateBremen, StateHamburg, StateMecklenburgVorpommern, StateNiedersachsen, StateSachsen, StateSachsenAnhalt, StateSchleswigHolstein, StateThueringen}},
+	{"Allerheiligen", fixedDate(time.November, 1), []FederalState{StateBadenWuerttemberg, StateBayern, StateNordrheinWestfalen, StateRheinlandPfalz, StateSaarland}},
+	{"Buß- und Bettag", repentanceDay, []FederalState{StateSachsen}},
+	{"1. Weihnachtstag", fixedDate(time.December, 25), nil},
+	{"2. Weihnachtstag", fixedDate(time.December, 26), nil},
+}
+
+// easterSunday computes Easter Sunday in the Gregorian calendar (anonymous Gregorian algorithm)
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := (19*a + b - b/4 - (b-(b+8)/25+1)/3 + 15) % 30
+	e := (32 + 2*(b%4) + 2*(c/4) - d - c%4) % 7
+	f := d + e - 7*((a+11*d+22*e)/451) + 114
+	return time.Date(year, time.Month(f/31), f%31+1, 0, 0, 0, 0, time.UTC)
+}
+
+// repentanceDay is the Wednesday before November 23
+func repentanceDay(year int) (time.Month, int) {
+	d := time.Date(year, time.November, 22, 0, 0, 0, 0, time.UTC)
+	d = d.AddDate(0, 0, -((int(d.Weekday()) - int(time.Wednesday) + 7) % 7))
+	return d.Month(), d.Day()
+}
+
+// PublicHoliday returns the name of the public holiday on the date of t (in Europe/Berlin)
+// in the given state
+func PublicHoliday(t time.Time, state FederalState) (string, bool) {
+	t = t.In(berlin)
+	for _, h := range holidays {
+		month, day := h.date(t.Year())
+		if month != t.Month() || day != t.Day() {
+			continue
+		}
+		if h.states == nil {
+			return h.name, true
+		}
+		for _, s := range h.states {
+			if s == state {
+				return h.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// zipStates maps postal code prefixes to federal states. Three-digit prefixes override
+// two-digit ones for regions crossing state borders.
+var zipStates = map[string]FederalState{
+	"01": StateSachsen, "02": StateSachsen, "03": StateBrandenburg, "04": StateSachsen,
+	"06": StateSachsenAnhalt, "07": StateThueringen, "08": StateSachsen, "09": StateSachsen,
+	"10": StateBerlin, "12": StateBerlin, "13": StateBerlin, "14": StateBrandenburg,
+	"15": StateBrandenburg, "16": StateBrandenburg, "17": StateMecklenburgVorpommern,
+	"18": StateMecklenburgVorpommern, "19": StateMecklenburgVorpommern,
+	"20": StateHamburg, "21": StateNiedersachsen, "22": StateHamburg, "23": StateSchleswigHolstein,
+	"24": StateSchleswigHolstein, "25": StateSchleswigHolstein, "26": StateNiedersachsen,
+	"27": StateNiedersachsen, "28": StateBremen, "29": StateNiedersachsen,
+	"30": StateNiedersachsen, "31": StateNiedersachsen, "32": StateNordrheinWestfalen,
+	"33": StateNordrheinWestfalen, "34": StateHessen, "35": StateHessen, "36": StateHessen,
+	"37": StateNiedersachsen, "38": StateNiedersachsen, "39": StateSachsenAnhalt,
+	"40": StateNordrheinWestfalen, "41": StateNordrheinWestfalen, "42": StateNordrheinWestfalen,
+	"44": StateNordrheinWestfalen, "45": StateNordrheinWestfalen, "46": StateNordrheinWestfalen,
+	"47": StateNordrheinWestfalen, "48": StateNordrheinWestfalen, "49": StateNiedersachsen,
+	"50": StateNordrheinWestfalen, "51": StateNordrheinWestfalen, "52": StateNordrheinWestfalen,
+	"53": StateNordrheinWestfalen, "54": StateRheinlandPfalz, "55": StateRheinlandPfalz,
+	"56": StateRheinlandPfalz, "57": StateNordrheinWestfalen, "58": StateNordrheinWestfalen,
+	"59": StateNordrheinWestfalen, "60": StateHessen, "61": StateHessen, "63": StateHessen,
+	"64": StateHessen, "65": StateHessen, "66": StateSaarland, "67": StateRheinlandPfalz,
+	"68": StateBadenWuerttemberg, "69": StateBadenWuerttemberg,
+	"70": StateBadenWuerttemberg, "71": StateBadenWuerttemberg, "72": StateBadenWuerttemberg,
+	"73": StateBadenWuerttemberg, "74": StateBadenWuerttemberg, "75": StateBadenWuerttemberg,
+	"76": StateBadenWuerttemberg, "77": StateBadenWuerttemberg, "78": StateBadenWuerttemberg,
+	"79": StateBadenWuerttemberg, "80": StateBayern, "81": StateBayern, "82": StateBayern,
+	"83": StateBayern, "84": StateBayern, "85": StateBayern, "86": StateBayern, "87": StateBayern,
+	"88": StateBadenWuerttemberg, "89": StateBadenWuerttemberg, "90": StateBayern,
+	"91": StateBayern, "92": StateBayern, "93": StateBayern, "94": StateBayern, "95": StateBayern,
+	"96": StateBayern, "97": StateBayern, "98": StateThueringen, "99": StateThueringen,
+
+	"275": StateBremen,                                         // Bremerhaven
+	"637": StateBayern, "638": StateBayern, "639": StateBayern, // Aschaffenburg, Miltenberg
+	"686": StateHessen,                                           // Lampertheim, Bürstadt
+	"892": StateBayern,                                           // Neu-Ulm
+	"978": StateBadenWuerttemberg, "979": StateBadenWuerttemberg, // Wertheim, Tauberbischofsheim
+}
+
+// FederalStateForZip derives the federal state from a postal code. The mapping uses
+// prefixes and can be wrong for towns close to state borders.
+func FederalStateForZip(zip string) (FederalState, bool) {
+	if len(zip) != 5 {
+		return "", false
+	}
+	if s, ok := zipStates[zip[:3]]; ok {
+		return s, true
+	}
+	s, ok := zipStates[zip[:2]]
+	return s, ok
+}
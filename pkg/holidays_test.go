@@ -0,0 +1,55 @@
+package rewerse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEasterSunday(t *testing.T) {
+	for year, want := range map[int]string{2024: "2024-03-31", 2025: "2025-04-20", 2026: "2026-04-05", 2038: "2038-04-25"} {
+		if got := easterSunday(year).Format("2006-01-02"); got != want {
+			t.Errorf("easterSunday(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func TestPublicHoliday(t *testing.T) {
+	tests := []struct {
+		date  time.Time
+		state FederalState
+		name  string
+	}{
+		{berlinTime(2025, 4, 18, 10, 0), StateBayern, "Karfreitag"},
+		{berlinTime(2025, 6, 19, 10, 0), StateNordrheinWestfalen, "Fronleichnam"},
+		{berlinTime(2025, 6, 19, 10, 0), StateHamburg, ""},
+		{berlinTime(2025, 11, 19, 10, 0), StateSachsen, "Buß- und Bettag"},
+		{berlinTime(2024, 11, 20, 10, 0), StateSachsen, "Buß- und Bettag"},
+		{berlinTime(2025, 3, 8, 10, 0), StateBerlin, "Internationaler Frauentag"},
+		{berlinTime(2025, 12, 24, 10, 0), StateBerlin, ""},
+		// 2025-10-02 23:30 UTC is already October 3 in Berlin
+		{time.Date(2025, 10, 2, 23, 30, 0, 0, time.UTC), StateBremen, "Tag der Deutschen Einheit"},
+	}
+	for _, tt := range tests {
+		name, ok := PublicHoliday(tt.date, tt.state)
+		if name != tt.name || ok != (tt.name != "") {
+			t.Errorf("PublicHoliday(%s, %s) = %q, want %q", tt.date.Format("2006-01-02"), tt.state, name, tt.name)
+		}
+	}
+}
+
+func TestFederalStateForZip(t *testing.T) {
+	tests := map[string]FederalState{
+		"68199": StateBadenWuerttemberg,
+		"50667": StateNordrheinWestfalen,
+		"20095": StateHamburg,
+		"27568": StateBremen,
+		"63739": StateBayern,
+		"01067": StateSachsen,
+		"6819":  "",
+	}
+	for zip, want := range tests {
+		if got, _ := FederalStateForZip(zip); got != want {
+			t.Errorf("FederalStateForZip(%s) = %q, want %q", zip, got, want)
+		}
+	}
+}
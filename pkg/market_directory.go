@@ -0,0 +1,317 @@
+package rewerse
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crawlCities are the seed cities of DefaultCrawlSeeds: all cities with more than
+// 100.000 inhabitants
+var crawlCities = []string{
+	"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main", "Stuttgart", "Düsseldorf",
+	"Leipzig", "Dortmund", "Essen", "Bremen", "Dresden", "Hannover", "Nürnberg", "Duisburg",
+	"Bochum", "Wuppertal", "Bielefeld", "Bonn", "Münster", "Mannheim", "Karlsruhe", "Augsburg",
+	"Wiesbaden", "Mönchengladbach", "Gelsenkirchen", "Aachen", "Braunschweig", "Kiel",
+	"Chemnitz", "Halle (Saale)", "Magdeburg", "Freiburg im Breisgau", "Krefeld", "Mainz",
+	"Lübeck", "Erfurt", "Oberhausen", "Rostock", "Kassel", "Hagen", "Potsdam", "Saarbrücken",
+	"Hamm", "Ludwigshafen", "Mülheim an der Ruhr", "Oldenburg", "Osnabrück", "Leverkusen",
+	"Darmstadt", "Heidelberg", "Solingen", "Herne", "Neuss", "Regensburg", "Paderborn",
+	"Ingolstadt", "Offenbach am Main", "Fürth", "Würzburg", "Ulm", "Heilbronn", "Pforzheim",
+	"Wolfsburg", "Göttingen", "Bottrop", "Reutlingen", "Koblenz", "Bremerhaven", "Recklinghausen",
+	"Erlangen", "Bergisch Gladbach", "Remscheid", "Jena", "Trier", "Salzgitter", "Moers",
+	"Siegen", "Hildesheim", "Gütersloh", "Cottbus",
+}
+
+// ZipPrefixSeeds returns all zip code prefixes with the given number of digits (1-4):
+// 2 digits gives "01" to "99"
+func ZipPrefixSeeds(digits int) ([]string, error) {
+	if digits < 1 || digits > 4 {
+		return nil, fmt.Errorf("invalid zip prefix length: %d (must be 1-4)", digits)
+	}
+	n := 1
+	for i := 0; i < digits; i++ {
+		n *= 10
+	}
+	seeds := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		seeds = append(seeds, fmt.Sprintf("%0*d", digits, i))
+	}
+	return seeds, nil
+}
+
+// DefaultCrawlSeeds returns the large cities and all two-digit zip prefixes
+func DefaultCrawlSeeds() []string {
+	prefixes, _ := ZipPrefixSeeds(2)
+	return append(append([]string{}, crawlCities...), prefixes...)
+}
+
+// DirectoryEntry is a market of the directory
+type DirectoryEntry struct {
+	Market Market `json:"market"`
+	// Content are the details from GetMarketDetails (nil if not enriched)
+	Content *MarketContent `json:"content,omitempty"`
+	// FirstSeen is the crawl time the market first appeared
+	FirstSeen time.Time `json:"firstSeen"`
+}
+
+// MarketDirectory is the result of a crawl
+type MarketDirectory struct {
+	// Version counts the crawls: 1 for the first, previous version + 1 after that
+	Version int `json:"version"`
+	// CrawledAt is the start of the crawl
+	CrawledAt time.Time `json:"crawledAt"`
+	// Markets are sorted by WWIdent
+	Markets []DirectoryEntry `json:"markets"`
+	// Errors are the failed searches and detail lookups of the crawl
+	Errors []string `json:"errors,omitempty"`
+}
+
+func (d MarketDirectory) String() string {
+	var sb strings.Builder
+	sb.WriteString(sep(fmt.Sprintf("Marktverzeichnis v%d (%d Märkte)", d.Version, len(d.Markets))))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Stand"))
+	sb.WriteString(d.CrawledAt.In(berlin).Format("02.01.2006 15:04"))
+	sb.WriteByte('\n')
+	if len(d.Errors) > 0 {
+		sb.WriteString(align("Fehler"))
+		sb.WriteString(fmt.Sprint(len(d.Errors)))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// CrawlOpts configures CrawlMarkets
+type CrawlOpts struct {
+	// Seeds are the MarketSearch queries (default: DefaultCrawlSeeds)
+	Seeds []string
+	// Details enriches every market with GetMarketDetails
+	Details bool
+	// Previous is the last directory. The new one continues its version and FirstSeen times.
+	// If a search fails, its markets are kept, since their absence can't be confirmed.
+	Previous *MarketDirectory
+	// RateLimit bounds the concurrent requests (default: DefaultRateLimit)
+	RateLimit *RateLimit
+	// Progress is called after each request with the number of finished and total requests
+	Progress func(done, total int)
+}
+
+// CrawlMarkets searches markets for every seed, dedupes them by WWIdent and optionally
+// enriches them with their details. Failed requests are collected in Errors; the crawl
+// only fails if every search fails. After a failed search, the markets of opts.Previous
+// that weren't found again are carried over instead of being dropped.
+func CrawlMarkets(opts *CrawlOpts) (MarketDirectory, error) {
+	return crawlMarkets(opts, MarketSearch, GetMarketDetails)
+}
+
+func crawlMarkets(opts *CrawlOpts, search func(string) (Markets, error), details func(string) (MarketDetails, error)) (MarketDirectory, error) {
+	if opts == nil {
+		opts = &CrawlOpts{}
+	}
+	seeds := opts.Seeds
+	if len(seeds) == 0 {
+		seeds = DefaultCrawlSeeds()
+	}
+
+	dir := MarketDirectory{Version: 1, CrawledAt: time.Now().UTC()}
+	firstSeen := make(map[string]time.Time)
+	if opts.Previous != nil {
+		dir.Version = opts.Previous.Version + 1
+		for _, e := range opts.Previous.Markets {
+			firstSeen[e.Market.WWIdent] = e.FirstSeen
+		}
+	}
+
+	var mu sync.Mutex
+	var finished, total int
+	progress := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		finished++
+		if opts.Progress != nil {
+			opts.Progress(finished, total)
+		}
+	}
+
+	// search all seeds
+	total = len(seeds)
+	results := make([]Markets, len(seeds))
+	searchErrs := make([]error, len(seeds))
+	opts.RateLimit.run(len(seeds), func(i int) {
+		results[i], searchErrs[i] = search(seeds[i])
+		progress()
+	})
+
+	failed := 0
+	for i, err := range searchErrs {
+		switch {
+		case errors.Is(err, ErrNoMarketsFound):
+		case err != nil:
+			failed++
+			dir.Errors = append(dir.Errors, fmt.Sprintf("search %q: %v", seeds[i], err))
+		}
+	}
+	if failed == len(seeds) {
+		return MarketDirectory{}, fmt.Errorf("all searches failed: %s", dir.Errors[0])
+	}
+
+	seen := make(map[string]bool)
+	for _, markets := range results {
+		for _, m := range markets {
+			if seen[m.WWIdent] {
+				continue
+			}
+			seen[m.WWIdent] = true
+			m.Distance = nil
+			entry := DirectoryEntry{Market: m, FirstSeen: dir.CrawledAt}
+			if t, ok := firstSeen[m.WWIdent]; ok {
+				entry.FirstSeen = t
+			}
+			dir.Markets = append(dir.Markets, entry)
+		}
+	}
+	if failed > 0 && opts.Previous != nil {
+		for _, e := range opts.Previous.Markets {
+			if !seen[e.Market.WWIdent] {
+				seen[e.Market.WWIdent] = true
+				dir.Markets = append(dir.Markets, e)
+			}
+		}
+	}
+	sort.Slice(dir.Markets, func(i, j int) bool {
+		return dir.Markets[i].Market.WWIdent < dir.Markets[j].Market.WWIdent
+	})
+
+	if opts.Details {
+		mu.Lock()
+		finished, total = 0, len(dir.Markets)
+		mu.Unlock()
+		detailErrs := make([]error, len(dir.Markets))
+		opts.RateLimit.run(len(dir.Markets), func(i int) {
+			md, err := details(dir.Markets[i].Market.WWIdent)
+			if err == nil {
+				dir.Markets[i].Content = &md.Content
+			}
+			detailErrs[i] = err
+			progress()
+		})
+		for i, err := range detailErrs {
+			if err != nil {
+				dir.Errors = append(dir.Errors, fmt.Sprintf("details %s: %v", dir.Markets[i].Market.WWIdent, err))
+			}
+		}
+	}
+
+	return dir, nil
+}
+
+// DirectoryDiff lists the changes between two directories
+type DirectoryDiff struct {
+	FromVersion int      `json:"fromVersion"`
+	ToVersion   int      `json:"toVersion"`
+	Appeared    []Market `json:"appeared"`
+	Disappeared []Market `json:"disappeared"`
+}
+
+func (dd DirectoryDiff) String() string {
+	var sb strings.Builder
+	sb.WriteString(sep(fmt.Sprintf("Änderungen v%d -> v%d", dd.FromVersion, dd.ToVersion)))
+	sb.WriteByte('\n')
+	if len(dd.Appeared) == 0 && len(dd.Disappeared) == 0 {
+		sb.WriteString("   (keine)\n")
+		return sb.String()
+	}
+	for _, m := range dd.Appeared {
+		sb.WriteString("   + ")
+		sb.WriteString(m.String())
+		sb.WriteByte('\n')
+	}
+	for _, m := range dd.Disappeared {
+		sb.WriteString("   - ")
+		sb.WriteString(m.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Diff returns the markets that appeared in d or disappeared from prev. Markets of a
+// failed search are carried over by CrawlMarkets, so they don't show up as disappeared.
+func (d MarketDirectory) Diff(prev MarketDirectory) DirectoryDiff {
+	dd := DirectoryDiff{FromVersion: prev.Version, ToVersion: d.Version}
+	current := make(map[string]bool, len(d.Markets))
+	for _, e := range d.Markets {
+		current[e.Market.WWIdent] = true
+	}
+	previous := make(map[string]bool, len(prev.Markets))
+	for _, e := range prev.Markets {
+		previous[e.Market.WWIdent] = true
+		if !current[e.Market.WWIdent] {
+			dd.Disappeared = append(dd.Disappeared, e.Market)
+		}
+	}
+	for _, e := range d.Markets {
+		if !previous[e.Market.WWIdent] {
+			dd.Appeared = append(dd.Appeared, e.Market)
+		}
+	}
+	return dd
+}
+
+// directoryHeader is the first line of a directory file
+type directoryHeader struct {
+	Version   int       `json:"version"`
+	CrawledAt time.Time `json:"crawledAt"`
+	Count     int       `json:"count"`
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// WriteJSONLines writes the directory as JSON lines: a header with version and crawl time,
+// then one market per line
+func (d MarketDirectory) WriteJSONLines(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(directoryHeader{Version: d.Version, CrawledAt: d.CrawledAt, Count: len(d.Markets), Errors: d.Errors}); err != nil {
+		return err
+	}
+	for _, e := range d.Markets {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadMarketDirectory reads a directory written by WriteJSONLines
+func ReadMarketDirectory(r io.Reader) (MarketDirectory, error) {
+	var d MarketDirectory
+	dec := json.NewDecoder(r)
+
+	var header directoryHeader
+	if err := dec.Decode(&header); err != nil {
+		return d, fmt.Errorf("invalid directory header: %w", err)
+	}
+	d.Version, d.CrawledAt, d.Errors = header.Version, header.CrawledAt, header.Errors
+
+	for {
+		var e DirectoryEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return d, fmt.Errorf("invalid directory entry %d: %w", len(d.Markets)+1, err)
+		}
+		d.Markets = append(d.Markets, e)
+	}
+	if len(d.Markets) != header.Count {
+		return d, fmt.Errorf("incomplete directory: expected %d markets, got %d", header.Count, len(d.Markets))
+	}
+	return d, nil
+}
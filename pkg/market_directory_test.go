@@ -0,0 +1,143 @@
+package rewerse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func directoryMarket(id, city string) Market {
+	var m Market
+	m.WWIdent = id
+	m.Name = "REWE Markt"
+	m.City = city
+	return m
+}
+
+func TestCrawlMarkets(t *testing.T) {
+	index := map[string]Markets{
+		"Köln": {directoryMarket("1", "Köln"), directoryMarket("2", "Köln")},
+		"50":   {directoryMarket("2", "Köln"), directoryMarket("3", "Hürth")},
+		"99":   nil,
+	}
+	search := func(q string) (Markets, error) {
+		markets, ok := index[q]
+		if !ok {
+			return nil, errors.New("timeout")
+		}
+		if len(markets) == 0 {
+			return nil, fmt.Errorf("%w for query %q", ErrNoMarketsFound, q)
+		}
+		return markets, nil
+	}
+	details := func(id string) (MarketDetails, error) {
+		if id == "3" {
+			return MarketDetails{}, errors.New("not found")
+		}
+		var md MarketDetails
+		md.Content.MarketData.MarketName = "REWE " + id
+		return md, nil
+	}
+
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := &MarketDirectory{Version: 4, Markets: []DirectoryEntry{
+		{Market: directoryMarket("1", "Köln"), FirstSeen: first},
+		{Market: directoryMarket("9", "Bonn"), FirstSeen: first},
+	}}
+	dir, err := crawlMarkets(&CrawlOpts{
+		Seeds:     []string{"Köln", "50", "99", "Bonn"},
+		Details:   true,
+		Previous:  prev,
+		RateLimit: &RateLimit{Workers: 2},
+	}, search, details)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 9 is kept: it might have been found by the failed search for "Bonn"
+	if dir.Version != 5 || len(dir.Markets) != 4 {
+		t.Fatalf("expected version 5 with 4 markets, got v%d with %d", dir.Version, len(dir.Markets))
+	}
+	if !dir.Markets[0].FirstSeen.Equal(first) || !dir.Markets[1].FirstSeen.Equal(dir.CrawledAt) {
+		t.Errorf("unexpected first seen times: %v, %v", dir.Markets[0].FirstSeen, dir.Markets[1].FirstSeen)
+	}
+	if c := dir.Markets[1].Content; c == nil || c.MarketData.MarketName != "REWE 2" {
+		t.Errorf("expected enriched market 2, got %+v", c)
+	}
+	if dir.Markets[2].Content != nil {
+		t.Error("market 3 must not be enriched")
+	}
+	if dir.Markets[3].Market.WWIdent != "9" || !dir.Markets[3].FirstSeen.Equal(first) {
+		t.Errorf("expected market 9 to be carried over, got %+v", dir.Markets[3])
+	}
+	// the timeout of "Bonn" and the failed details of 3; "99" has no markets
+	if len(dir.Errors) != 2 {
+		t.Errorf("expected 2 errors, got %v", dir.Errors)
+	}
+
+	diff := dir.Diff(*prev)
+	if len(diff.Appeared) != 2 || diff.Appeared[0].WWIdent != "2" || len(diff.Disappeared) != 0 {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+
+	// without failed searches, 9 is gone
+	index["Bonn"] = nil
+	dir, err = crawlMarkets(&CrawlOpts{Seeds: []string{"Köln", "Bonn"}, Previous: prev, RateLimit: &RateLimit{}}, search, details)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	diff = dir.Diff(*prev)
+	if len(dir.Markets) != 2 || len(diff.Disappeared) != 1 || diff.Disappeared[0].WWIdent != "9" {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+	delete(index, "Bonn")
+
+	if _, err := crawlMarkets(&CrawlOpts{Seeds: []string{"Bonn"}, RateLimit: &RateLimit{}}, search, details); err == nil {
+		t.Error("expected error if all searches fail")
+	}
+}
+
+func TestMarketDirectoryJSONLines(t *testing.T) {
+	dir := MarketDirectory{
+		Version:   2,
+		CrawledAt: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+		Markets: []DirectoryEntry{
+			{Market: directoryMarket("1", "Köln")},
+			{Market: directoryMarket("2", "Bonn"), Content: &MarketContent{}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := dir.WriteJSONLines(&buf); err != nil {
+		t.Fatalf("WriteJSONLines failed: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("expected header and 2 market lines, got %d lines", lines)
+	}
+
+	got, err := ReadMarketDirectory(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadMarketDirectory failed: %v", err)
+	}
+	if got.Version != 2 || !got.CrawledAt.Equal(dir.CrawledAt) || len(got.Markets) != 2 || got.Markets[1].Content == nil {
+		t.Errorf("unexpected directory: %+v", got)
+	}
+
+	// a truncated file is detected
+	truncated := buf.Bytes()[:bytes.LastIndexByte(buf.Bytes()[:buf.Len()-1], '\n')+1]
+	if _, err := ReadMarketDirectory(bytes.NewReader(truncated)); err == nil {
+		t.Error("expected error for truncated directory")
+	}
+}
+
+func TestZipPrefixSeeds(t *testing.T) {
+	seeds, err := ZipPrefixSeeds(2)
+	if err != nil || len(seeds) != 100 || seeds[1] != "01" || seeds[99] != "99" {
+		t.Errorf("unexpected seeds: %v (%v)", seeds, err)
+	}
+	if _, err := ZipPrefixSeeds(5); err == nil {
+		t.Error("expected error for 5 digits")
+	}
+}
@@ -0,0 +1,268 @@
+package rewerse
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MapFormat is an export format for market locations
+type MapFormat string
+
+const (
+	// MapGeoJSON is a GeoJSON FeatureCollection of points (RFC 7946)
+	MapGeoJSON MapFormat = "geojson"
+	// MapKML is a KML 2.2 document with one placemark per market
+	MapKML MapFormat = "kml"
+)
+
+// ParseMapFormat validates a format name
+func ParseMapFormat(s string) (MapFormat, error) {
+	switch strings.ToLower(s) {
+	case "geojson", "json":
+		return MapGeoJSON, nil
+	case "kml":
+		return MapKML, nil
+	}
+	return "", fmt.Errorf("invalid map format: %q (must be geojson or kml)", s)
+}
+
+// FeatureCollection is a GeoJSON FeatureCollection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature with a point geometry
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   PointGeometry  `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// PointGeometry is a GeoJSON Point. Coordinates are [longitude, latitude].
+type PointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// mapPlace is a market location with its properties in output order
+type mapPlace struct {
+	id         string
+	name       string
+	lat, lon   float64
+	properties []mapProperty
+}
+
+type mapProperty struct {
+	key   string
+	value any
+}
+
+func (p *mapPlace) add(key string, value any) {
+	p.properties = append(p.properties, mapProperty{key, value})
+}
+
+func (p mapPlace) feature() Feature {
+	props := make(map[string]any, len(p.properties))
+	for _, prop := range p.properties {
+		props[prop.key] = prop.value
+	}
+	return Feature{
+		Type:       "Feature",
+		ID:         p.id,
+		Geometry:   PointGeometry{Type: "Point", Coordinates: [2]float64{p.lon, p.lat}},
+		Properties: props,
+	}
+}
+
+func newFeatureCollection(places []mapPlace) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, len(places))}
+	for _, p := range places {
+		fc.Features = append(fc.Features, p.feature())
+	}
+	return fc
+}
+
+// marketPlace collects the mapped properties of a market
+func marketPlace(m Market) mapPlace {
+	p := mapPlace{id: m.WWIdent, name: fmt.Sprintf("%s %s", m.Name, m.City), lat: m.Location.Latitude, lon: m.Location.Longitude}
+	p.add("wwIdent", m.WWIdent)
+	p.add("name", m.Name)
+	p.add("companyName", m.CompanyName)
+	p.add("typeId", m.TypeID)
+	p.add("street", m.Street)
+	p.add("zipCode", m.ZipCode)
+	p.add("city", m.City)
+	p.add("phone", m.Phone)
+	p.add("openingHours", formatOpeningInfo(m.OpeningInfo))
+	p.add("openState", m.OpeningStatus.OpenState)
+	p.add("hasPickup", m.ServiceFlags.HasPickup)
+	if m.Distance != nil {
+		p.add("distanceKm", *m.Distance)
+	}
+	return p
+}
+
+// formatOpeningInfo joins opening hours to one line: "Mo - Sa 07:00 - 22:00; 03.04. geschlossen"
+func formatOpeningInfo(info []OpeningInfo) string {
+	parts := make([]string, len(info))
+	for i, oi := range info {
+		parts[i] = oi.Days + " " + oi.Hours
+	}
+	return strings.Join(parts, "; ")
+}
+
+func marketPlaces(ms Markets) []mapPlace {
+	places := make([]mapPlace, len(ms))
+	for i, m := range ms {
+		places[i] = marketPlace(m)
+	}
+	return places
+}
+
+// GeoJSON returns the markets as a FeatureCollection
+func (ms Markets) GeoJSON() FeatureCollection {
+	return newFeatureCollection(marketPlaces(ms))
+}
+
+// Coordinates parses the string coordinates of the pickup market
+func (pm PickupMarket) Coordinates() (lat, lon float64, err error) {
+	lat, err = parseCoordinate(pm.Latitude, 90)
+	if err != nil {
+		return 0, 0, fmt.Errorf("market %s: invalid latitude: %w", pm.WWIdent, err)
+	}
+	lon, err = parseCoordinate(pm.Longitude, 180)
+	if err != nil {
+		return 0, 0, fmt.Errorf("market %s: invalid longitude: %w", pm.WWIdent, err)
+	}
+	return lat, lon, nil
+}
+
+func parseCoordinate(s string, limit float64) (float64, error) {
+	v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if v < -limit || v > limit {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return v, nil
+}
+
+// portfolioPlaces collects the pickup markets. Unparseable coordinates are an error.
+func (sp ServicePortfolio) portfolioPlaces() ([]mapPlace, error) {
+	places := make([]mapPlace, 0, len(sp.PickupMarkets))
+	for _, pm := range sp.PickupMarkets {
+		lat, lon, err := pm.Coordinates()
+		if err != nil {
+			return nil, err
+		}
+		p := mapPlace{id: pm.WWIdent, name: fmt.Sprintf("%s %s", pm.DisplayName, pm.City), lat: lat, lon: lon}
+		p.add("wwIdent", pm.WWIdent)
+		p.add("name", pm.DisplayName)
+		p.add("companyName", pm.CompanyName)
+		p.add("street", pm.StreetWithHouseNumber)
+		p.add("zipCode", pm.ZipCode)
+		p.add("city", pm.City)
+		p.add("pickupType", pm.PickupType)
+		p.add("isPickupStation", pm.IsPickupStation)
+		p.add("delivers", sp.DeliveryMarket != nil && sp.DeliveryMarket.WWIdent == pm.WWIdent)
+		p.add("customerZipCode", sp.CustomerZipCode)
+		places = append(places, p)
+	}
+	return places, nil
+}
+
+// GeoJSON returns the pickup markets as a FeatureCollection. The delivery market has no
+// coordinates; it is marked by the "delivers" property if it also offers pickup.
+func (sp ServicePortfolio) GeoJSON() (FeatureCollection, error) {
+	places, err := sp.portfolioPlaces()
+	if err != nil {
+		return FeatureCollection{}, err
+	}
+	return newFeatureCollection(places), nil
+}
+
+// kmlDocument is the KML 2.2 subset we write
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	ID          string    `xml:"id,attr,omitempty"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"description,omitempty"`
+	Data        []kmlData `xml:"ExtendedData>Data"`
+	Coordinates string    `xml:"Point>coordinates"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+func writeKML(w io.Writer, title string, places []mapPlace) error {
+	doc := kmlDocument{Namespace: "http://www.opengis.net/kml/2.2", Name: title}
+	for _, p := range places {
+		pm := kmlPlacemark{
+			ID:          p.id,
+			Name:        p.name,
+			Coordinates: strconv.FormatFloat(p.lon, 'f', -1, 64) + "," + strconv.FormatFloat(p.lat, 'f', -1, 64),
+		}
+		var address []string
+		for _, prop := range p.properties {
+			value := fmt.Sprint(prop.value)
+			pm.Data = append(pm.Data, kmlData{Name: prop.key, Value: value})
+			if prop.key == "street" || prop.key == "city" {
+				address = append(address, value)
+			}
+		}
+		pm.Description = strings.Join(address, ", ")
+		doc.Placemarks = append(doc.Placemarks, pm)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("error encoding KML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeMap(w io.Writer, title string, places []mapPlace, format MapFormat) error {
+	switch format {
+	case MapGeoJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newFeatureCollection(places))
+	case MapKML:
+		return writeKML(w, title, places)
+	}
+	return fmt.Errorf("invalid map format: %q", format)
+}
+
+// ExportMarkets writes the markets as GeoJSON or KML
+func ExportMarkets(w io.Writer, markets Markets, format MapFormat) error {
+	return writeMap(w, "REWE Märkte", marketPlaces(markets), format)
+}
+
+// ExportServicePortfolio writes the pickup markets of a service portfolio as GeoJSON or KML
+func ExportServicePortfolio(w io.Writer, sp ServicePortfolio, format MapFormat) error {
+	places, err := sp.portfolioPlaces()
+	if err != nil {
+		return err
+	}
+	return writeMap(w, "Abholmärkte "+sp.CustomerZipCode, places, format)
+}
@@ -0,0 +1,86 @@
+package rewerse
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestMarketsGeoJSON(t *testing.T) {
+	markets := loadMarketSearchFixture(t)
+
+	var buf bytes.Buffer
+	if err := ExportMarkets(&buf, markets, MapGeoJSON); err != nil {
+		t.Fatalf("ExportMarkets failed: %v", err)
+	}
+	var fc FeatureCollection
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != len(markets) {
+		t.Fatalf("expected %d features, got %d (%s)", len(markets), len(fc.Features), fc.Type)
+	}
+
+	f := fc.Features[0]
+	m := markets[0]
+	if f.Geometry.Type != "Point" || f.Geometry.Coordinates != [2]float64{m.Location.Longitude, m.Location.Latitude} {
+		t.Errorf("expected [lon, lat] point, got %v", f.Geometry)
+	}
+	if f.ID != m.WWIdent || f.Properties["zipCode"] != m.ZipCode || f.Properties["hasPickup"] != m.ServiceFlags.HasPickup {
+		t.Errorf("unexpected properties: %v", f.Properties)
+	}
+	if hours, _ := f.Properties["openingHours"].(string); !strings.HasPrefix(hours, "Mo - Sa 07:00 - 21:00") {
+		t.Errorf("unexpected opening hours: %q", hours)
+	}
+}
+
+func TestServicePortfolioKML(t *testing.T) {
+	var res servicePortfolioResponse
+	loadJSONFixture(t, "service_portfolio.json", &res)
+	sp := res.Data.ServicePortfolio
+
+	var buf bytes.Buffer
+	if err := ExportServicePortfolio(&buf, sp, MapKML); err != nil {
+		t.Fatalf("ExportServicePortfolio failed: %v", err)
+	}
+	var doc kmlDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid KML: %v", err)
+	}
+	if len(doc.Placemarks) != len(sp.PickupMarkets) {
+		t.Fatalf("expected %d placemarks, got %d", len(sp.PickupMarkets), len(doc.Placemarks))
+	}
+	pm := doc.Placemarks[0]
+	if pm.ID != "430461" || pm.Coordinates != "9.99815,53.54319" || pm.Description != "Osakaallee 7, Hamburg" {
+		t.Errorf("unexpected placemark: %+v", pm)
+	}
+
+	// broken coordinates are reported
+	sp.PickupMarkets[0].Latitude = "n/a"
+	if _, err := sp.GeoJSON(); err == nil {
+		t.Error("expected error for invalid latitude")
+	}
+}
+
+func TestPickupMarketCoordinates(t *testing.T) {
+	tests := []struct {
+		lat, lon string
+		ok       bool
+	}{
+		{"49.45762", "8.43085", true},
+		{" 49,45762", "8,43085", true},
+		{"", "8.43085", false},
+		{"149.1", "8.4", false},
+	}
+	for _, tt := range tests {
+		lat, lon, err := PickupMarket{Latitude: tt.lat, Longitude: tt.lon}.Coordinates()
+		if (err == nil) != tt.ok {
+			t.Errorf("Coordinates(%q, %q): unexpected error state: %v", tt.lat, tt.lon, err)
+		}
+		if tt.ok && (lat != 49.45762 || lon != 8.43085) {
+			t.Errorf("Coordinates(%q, %q) = %v, %v", tt.lat, tt.lon, lat, lon)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package rewerse
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// earthRadiusKm is the mean earth radius used for distances
+const earthRadiusKm = 6371.0
+
+// haversineKm returns the great-circle distance between two coordinates in km
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// DistanceTo returns the distance from the market to a coordinate in km
+func (m Market) DistanceTo(lat, lon float64) float64 {
+	return haversineKm(m.Location.Latitude, m.Location.Longitude, lat, lon)
+}
+
+// withinRadius returns the markets within radiusKm (0 = no limit) of a coordinate,
+// nearest first. Distance is always computed (haversine); a server-side distance is
+// replaced, as its unit and reference point are unknown.
+func withinRadius(markets Markets, lat, lon, radiusKm float64) Markets {
+	var near Markets
+	for _, m := range markets {
+		d := math.Round(m.DistanceTo(lat, lon)*100) / 100
+		m.Distance = &d
+		if radiusKm > 0 && d > radiusKm {
+			continue
+		}
+		near = append(near, m)
+	}
+	sort.SliceStable(near, func(i, j int) bool {
+		return *near[i].Distance < *near[j].Distance
+	})
+	return near
+}
+
+// MarketsNearZip returns the markets found by MarketSearch for a zip code within radiusKm,
+// nearest first. The zip code has no coordinates of its own, so the center is the mean
+// location of the markets in that zip code (or the first result if there are none).
+func MarketsNearZip(zip string, radiusKm float64) (Markets, error) {
+	markets, err := MarketSearch(zip)
+	if err != nil {
+		return nil, err
+	}
+
+	var lat, lon float64
+	var n int
+	for _, m := range markets {
+		if m.ZipCode == zip {
+			lat += m.Location.Latitude
+			lon += m.Location.Longitude
+			n++
+		}
+	}
+	if n == 0 {
+		lat, lon, n = markets[0].Location.Latitude, markets[0].Location.Longitude, 1
+	}
+
+	near := withinRadius(markets, lat/float64(n), lon/float64(n), radiusKm)
+	if len(near) == 0 {
+		return nil, fmt.Errorf("no markets within %.1f km of %s", radiusKm, zip)
+	}
+	return near, nil
+}
+
+// MarketSearchNear returns the markets within radiusKm of a coordinate, nearest first.
+//
+// Unverified API behavior: the coordinate is sent as the query parameters latitude and
+// longitude, which are a guess; no captured response shows that the server honors them.
+// If it ignores them, the server returns its default result set and markets near the
+// coordinate may be missing. Distance and radius are therefore always computed
+// client-side (haversine) from the returned markets.
+func MarketSearchNear(lat, lon, radiusKm float64) (Markets, error) {
+	return marketSearchNear(lat, lon, radiusKm, fetchMarketSearch)
+}
+
+func marketSearchNear(lat, lon, radiusKm float64, fetch func(url.Values) (Markets, error)) (Markets, error) {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, fmt.Errorf("invalid coordinate: %f, %f", lat, lon)
+	}
+
+	query := url.Values{}
+	query.Add("latitude", strconv.FormatFloat(lat, 'f', -1, 64))
+	query.Add("longitude", strconv.FormatFloat(lon, 'f', -1, 64))
+	markets, err := fetch(query)
+	if err != nil {
+		return nil, err
+	}
+
+	near := withinRadius(markets, lat, lon, radiusKm)
+	if len(near) == 0 {
+		return nil, fmt.Errorf("no markets within %.1f km of %f, %f", radiusKm, lat, lon)
+	}
+	return near, nil
+}
+
+func fetchMarketSearch(query url.Values) (Markets, error) {
+	req, err := BuildCustomRequest(clientHost, "stationary-markets?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var res marketSearchResponse
+	if err := DoRequest(req, &res); err != nil {
+		return nil, err
+	}
+	return res.Data.MarketSearch.Markets, nil
+}
+
+// MarketFilter filters markets client-side. Zero values don't filter.
+type MarketFilter struct {
+	// TypeID keeps markets of this type: "REWE", "CENTER"
+	TypeID string
+	// HasPickup keeps markets offering pickup
+	HasPickup bool
+	// Open keeps markets whose OpeningStatus is currently OPEN
+	Open bool
+	// OpenAt keeps markets that are open at this time according to their opening hours
+	OpenAt time.Time
+}
+
+// Match reports whether a market passes the filter
+func (f MarketFilter) Match(m Market) bool {
+	if f.TypeID != "" && !strings.EqualFold(m.TypeID, f.TypeID) {
+		return false
+	}
+	if f.HasPickup && !m.ServiceFlags.HasPickup {
+		return false
+	}
+	if f.Open && m.OpeningStatus.OpenState != "OPEN" {
+		return false
+	}
+	if !f.OpenAt.IsZero() && !m.IsOpenAt(f.OpenAt) {
+		return false
+	}
+	return true
+}
+
+// Filter returns the markets that pass the filter
+func (ms Markets) Filter(f MarketFilter) Markets {
+	var filtered Markets
+	for _, m := range ms {
+		if f.Match(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
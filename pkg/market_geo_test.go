@@ -0,0 +1,118 @@
+package rewerse
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func loadMarketSearchFixture(t *testing.T) Markets {
+	t.Helper()
+	var res marketSearchResponse
+	loadJSONFixture(t, "market_search.json", &res)
+	return res.Data.MarketSearch.Markets
+}
+
+func marketIDs(markets Markets) []string {
+	ids := []string{}
+	for _, m := range markets {
+		ids = append(ids, m.WWIdent)
+	}
+	return ids
+}
+
+func TestHaversineKm(t *testing.T) {
+	// Köln Dom -> Mannheim Wasserturm, about 196 km
+	d := haversineKm(50.9413, 6.9583, 49.4840, 8.4756)
+	if math.Abs(d-196) > 5 {
+		t.Errorf("expected about 196 km, got %.1f", d)
+	}
+	if d := haversineKm(49.48, 8.47, 49.48, 8.47); d != 0 {
+		t.Errorf("expected 0 for the same point, got %v", d)
+	}
+}
+
+func TestWithinRadius(t *testing.T) {
+	market := func(id string, lat, lon float64) Market {
+		var m Market
+		m.WWIdent = id
+		m.Location.Latitude = lat
+		m.Location.Longitude = lon
+		return m
+	}
+	markets := Markets{
+		market("far", 49.60, 8.47),
+		market("near", 49.49, 8.47),
+		market("center", 49.48, 8.47),
+	}
+
+	near := withinRadius(markets, 49.48, 8.47, 5)
+	if len(near) != 2 || near[0].WWIdent != "center" || near[1].WWIdent != "near" {
+		t.Fatalf("unexpected markets: %v", near)
+	}
+	if near[1].Distance == nil || math.Abs(*near[1].Distance-1.11) > 0.01 {
+		t.Errorf("expected distance 1.11 km, got %v", near[1].Distance)
+	}
+	if markets[0].Distance != nil {
+		t.Error("input markets must not be modified")
+	}
+
+	// a server-side distance in an unknown unit is replaced
+	serverDistance := 1500.0
+	markets[0].Distance = &serverDistance
+	if far := withinRadius(markets[:1], 49.48, 8.47, 0); math.Abs(*far[0].Distance-13.34) > 0.01 {
+		t.Errorf("expected computed distance 13.34 km, got %v", *far[0].Distance)
+	}
+}
+
+func TestMarketSearchNear(t *testing.T) {
+	var sent url.Values
+	fetch := func(query url.Values) (Markets, error) {
+		sent = query
+		return loadMarketSearchFixture(t), nil
+	}
+
+	// Hamburg Rathaus
+	near, err := marketSearchNear(53.5503, 9.9927, 2, fetch)
+	if err != nil {
+		t.Fatalf("marketSearchNear failed: %v", err)
+	}
+	if sent.Get("latitude") != "53.5503" || sent.Get("longitude") != "9.9927" {
+		t.Errorf("unexpected query: %v", sent)
+	}
+	if want := []string{"540934", "862988", "320621"}; !reflect.DeepEqual(marketIDs(near), want) {
+		t.Errorf("expected %v, got %v", want, marketIDs(near))
+	}
+	if math.Abs(*near[0].Distance-0.28) > 0.01 {
+		t.Errorf("expected 0.28 km, got %v", *near[0].Distance)
+	}
+
+	if _, err := marketSearchNear(91, 0, 2, fetch); err == nil {
+		t.Error("expected error for invalid latitude")
+	}
+}
+
+func TestMarketsFilter(t *testing.T) {
+	markets := loadMarketSearchFixture(t)
+
+	tests := []struct {
+		name   string
+		filter MarketFilter
+		want   []string
+	}{
+		{"center", MarketFilter{TypeID: "center"}, []string{"531077"}},
+		{"pickup", MarketFilter{HasPickup: true}, []string{"540672", "531102", "7000016", "540638", "531057", "541740", "531138", "531077", "561188", "1763938"}},
+		{"center with pickup", MarketFilter{TypeID: "CENTER", HasPickup: true}, []string{"531077"}},
+		// every market in the fixture is closed
+		{"open", MarketFilter{Open: true}, []string{}},
+	}
+	if got := len(markets.Filter(MarketFilter{})); got != 20 {
+		t.Errorf("none: expected 20 markets, got %d", got)
+	}
+	for _, tt := range tests {
+		if got := marketIDs(markets.Filter(tt.filter)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
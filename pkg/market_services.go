@@ -0,0 +1,204 @@
+package rewerse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StoreService is an in-store service of a market, identified by MarketService.Icon
+type StoreService string
+
+const (
+	StoreMeatCounter     StoreService = "sausagemeat"
+	StoreCheeseCounter   StoreService = "cheese"
+	StoreFishCounter     StoreService = "fish"
+	StoreHotCounter      StoreService = "hotbar"
+	StoreSaladBar        StoreService = "salad"
+	StoreSushi           StoreService = "sushi"
+	StoreDeli            StoreService = "deli"
+	StoreBakery          StoreService = "bakery"
+	StorePlatter         StoreService = "plate"
+	StoreGiftBasket      StoreService = "giftbasket"
+	StoreWine            StoreService = "wine"
+	StoreBeverageMarket  StoreService = "beverage"
+	StoreFlowerShop      StoreService = "flowershop"
+	StoreLotto           StoreService = "lotto"
+	StoreDeutschePost    StoreService = "deutschepost"
+	StoreDHL             StoreService = "dhl"
+	StoreCoinstar        StoreService = "coinstar"
+	StoreZooRoyal        StoreService = "zooroyal"
+	StoreScanGo          StoreService = "scango"
+	StoreParking         StoreService = "parking"
+	StoreChargingStation StoreService = "chargingstation"
+	StoreWLAN            StoreService = "wlan"
+	StoreAccessible      StoreService = "accessible"
+)
+
+// storeServiceNames are the German names as shown in the app
+var storeServiceNames = map[StoreService]string{
+	StoreMeatCounter:     "Fleisch- und Wursttheke",
+	StoreCheeseCounter:   "Käsetheke",
+	StoreFishCounter:     "Fischtheke",
+	StoreHotCounter:      "Heiße Theke",
+	StoreSaladBar:        "Salatbar",
+	StoreSushi:           "Sushi",
+	StoreDeli:            "deli am Markt",
+	StoreBakery:          "Bäckerei",
+	StorePlatter:         "Plattenservice",
+	StoreGiftBasket:      "Präsentkorb",
+	StoreWine:            "Wein",
+	StoreBeverageMarket:  "Getränkemarkt",
+	StoreFlowerShop:      "Blumengeschäft",
+	StoreLotto:           "Lotto",
+	StoreDeutschePost:    "Deutsche Post",
+	StoreDHL:             "DHL",
+	StoreCoinstar:        "Coinstar",
+	StoreZooRoyal:        "ZooRoyal",
+	StoreScanGo:          "Scan & Go",
+	StoreParking:         "Parkplätze",
+	StoreChargingStation: "Ladestation für E-Autos",
+	StoreWLAN:            "WLAN",
+	StoreAccessible:      "Barrierefreier Markt",
+}
+
+// storeServiceAliases are additional names accepted by ParseStoreService
+var storeServiceAliases = map[string]StoreService{
+	"fleischtheke": StoreMeatCounter,
+	"wursttheke":   StoreMeatCounter,
+	"parkplatz":    StoreParking,
+	"ladestation":  StoreChargingStation,
+	"barrierefrei": StoreAccessible,
+	"post":         StoreDeutschePost,
+}
+
+func (s StoreService) String() string {
+	if name, ok := storeServiceNames[s]; ok {
+		return name
+	}
+	return string(s)
+}
+
+// StoreServices returns all known services, sorted by identifier
+func StoreServices() []StoreService {
+	services := make([]StoreService, 0, len(storeServiceNames))
+	for s := range storeServiceNames {
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i] < services[j] })
+	return services
+}
+
+// ParseStoreService parses an identifier ("fish"), a German name ("Fischtheke") or a
+// short alias ("parkplatz"), case-insensitive
+func ParseStoreService(s string) (StoreService, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if _, ok := storeServiceNames[StoreService(s)]; ok {
+		return StoreService(s), nil
+	}
+	for service, name := range storeServiceNames {
+		if strings.ToLower(name) == s {
+			return service, nil
+		}
+	}
+	if service, ok := storeServiceAliases[s]; ok {
+		return service, nil
+	}
+	known := StoreServices()
+	ids := make([]string, len(known))
+	for i, k := range known {
+		ids[i] = string(k)
+	}
+	return "", fmt.Errorf("unknown market service: %q (known: %s)", s, strings.Join(ids, ", "))
+}
+
+// ParseStoreServices parses a comma-separated list: "fischtheke,parking"
+func ParseStoreServices(list string) ([]StoreService, error) {
+	var services []StoreService
+	for _, part := range strings.Split(list, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		s, err := ParseStoreService(part)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	return services, nil
+}
+
+// Service returns the typed service. Custom services of the market have the icon "default".
+func (ms MarketService) Service() StoreService {
+	return StoreService(ms.Icon)
+}
+
+// ActiveServices returns the known services the market offers
+func (md MarketDetails) ActiveServices() []StoreService {
+	var active []StoreService
+	seen := make(map[StoreService]bool)
+	for _, list := range [][]MarketService{md.Content.Services.Fixed, md.Content.Services.Editable} {
+		for _, ms := range list {
+			s := ms.Service()
+			if _, known := storeServiceNames[s]; ms.Active && known && !seen[s] {
+				seen[s] = true
+				active = append(active, s)
+			}
+		}
+	}
+	return active
+}
+
+// HasService reports whether the market offers all of the given services
+func (md MarketDetails) HasService(services ...StoreService) bool {
+	active := md.ActiveServices()
+	for _, want := range services {
+		found := false
+		for _, s := range active {
+			if s == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterByServices looks up the details of the markets concurrently and keeps those
+// offering all services, in their original order. Markets whose details can't be loaded
+// are skipped; it only fails if no lookup succeeds.
+func FilterByServices(markets Markets, services []StoreService, rl *RateLimit) (Markets, error) {
+	return filterByServices(markets, services, rl, GetMarketDetails)
+}
+
+func filterByServices(markets Markets, services []StoreService, rl *RateLimit, details func(string) (MarketDetails, error)) (Markets, error) {
+	if len(services) == 0 || len(markets) == 0 {
+		return markets, nil
+	}
+
+	keep := make([]bool, len(markets))
+	errs := make([]error, len(markets))
+	rl.run(len(markets), func(i int) {
+		md, err := details(markets[i].WWIdent)
+		errs[i] = err
+		keep[i] = err == nil && md.HasService(services...)
+	})
+
+	var filtered Markets
+	failed := 0
+	for i, m := range markets {
+		if errs[i] != nil {
+			failed++
+		}
+		if keep[i] {
+			filtered = append(filtered, m)
+		}
+	}
+	if failed == len(markets) {
+		return nil, fmt.Errorf("error loading market details: %w", errs[0])
+	}
+	return filtered, nil
+}
@@ -0,0 +1,86 @@
+package rewerse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseStoreService(t *testing.T) {
+	tests := map[string]StoreService{
+		"fish":                    StoreFishCounter,
+		"Fischtheke":              StoreFishCounter,
+		" PARKPLÄTZE ":            StoreParking,
+		"parkplatz":               StoreParking,
+		"Fleisch- und Wursttheke": StoreMeatCounter,
+	}
+	for in, want := range tests {
+		got, err := ParseStoreService(in)
+		if err != nil || got != want {
+			t.Errorf("ParseStoreService(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseStoreService("default"); err == nil {
+		t.Error("expected error for custom service icon")
+	}
+
+	services, err := ParseStoreServices("fischtheke, wlan,")
+	if err != nil || len(services) != 2 || services[1] != StoreWLAN {
+		t.Errorf("unexpected services: %v (%v)", services, err)
+	}
+}
+
+func TestMarketDetailsHasService(t *testing.T) {
+	var res marketDetailsResponse
+	loadJSONFixture(t, "market_details.json", &res)
+	md := MarketDetails{Market: res.Data.Market, Content: res.Data.Content}
+	if len(md.ActiveServices()) != 0 || md.HasService(StoreParking) {
+		t.Fatal("fixture market has no active services")
+	}
+
+	for i, s := range md.Content.Services.Fixed {
+		if s.Service() == StoreParking || s.Service() == StoreFishCounter {
+			md.Content.Services.Fixed[i].Active = true
+		}
+	}
+	md.Content.Services.Editable = append(md.Content.Services.Editable,
+		MarketService{Text: "Eigene Metzgerei", Icon: "default", Active: true})
+
+	if !md.HasService(StoreParking, StoreFishCounter) {
+		t.Error("expected parking and fish counter")
+	}
+	if md.HasService(StoreParking, StoreWLAN) {
+		t.Error("market has no WLAN")
+	}
+	if active := md.ActiveServices(); len(active) != 2 {
+		t.Errorf("custom services must not be listed: %v", active)
+	}
+	if !md.HasService() {
+		t.Error("no services means no constraint")
+	}
+}
+
+func TestFilterByServices(t *testing.T) {
+	markets := Markets{directoryMarket("1", "Köln"), directoryMarket("2", "Köln"), directoryMarket("3", "Köln")}
+	details := func(id string) (MarketDetails, error) {
+		var md MarketDetails
+		switch id {
+		case "1":
+			md.Content.Services.Fixed = []MarketService{{Icon: "fish", Active: true}, {Icon: "parking", Active: true}}
+		case "2":
+			md.Content.Services.Fixed = []MarketService{{Icon: "fish", Active: true}, {Icon: "parking"}}
+		case "3":
+			return md, errors.New("timeout")
+		}
+		return md, nil
+	}
+
+	got, err := filterByServices(markets, []StoreService{StoreFishCounter, StoreParking}, &RateLimit{}, details)
+	if err != nil || len(got) != 1 || got[0].WWIdent != "1" {
+		t.Errorf("unexpected markets: %v (%v)", got, err)
+	}
+
+	failing := func(string) (MarketDetails, error) { return MarketDetails{}, errors.New("timeout") }
+	if _, err := filterByServices(markets, []StoreService{StoreWLAN}, &RateLimit{}, failing); err == nil {
+		t.Error("expected error if all lookups fail")
+	}
+}
@@ -63,13 +63,8 @@ type Market struct {
 		// StatusText is human-readable status: "Geöffnet", "Geschlossen"
 		StatusText string `json:"statusText"`
 	} `json:"openingStatus"`
-	// OpeningInfo contains weekly opening hours
-	OpeningInfo []struct {
-		// Days is the day range: "Mo - Sa"
-		Days string `json:"days"`
-		// Hours is the time range: "07:00 - 22:00"
-		Hours string `json:"hours"`
-	} `json:"openingInfo"`
+	// OpeningInfo contains weekly opening hours, see Schedule
+	OpeningInfo []OpeningInfo `json:"openingInfo"`
 	// Category contains market type classification
 	Category struct {
 		// Template is the layout template code: "RN", "RC"
@@ -91,7 +86,11 @@ type Market struct {
 }
 
 func (m Market) String() string {
-	return fmt.Sprintf("%s: %s, %s, %s %s", m.WWIdent, m.Name, m.Street, m.ZipCode, m.City)
+	s := fmt.Sprintf("%s: %s, %s, %s %s", m.WWIdent, m.Name, m.Street, m.ZipCode, m.City)
+	if m.Distance != nil {
+		s += fmt.Sprintf(" (%.1f km)", *m.Distance)
+	}
+	return s
 }
 
 // MarketContent contains additional market details from the details endpoint
@@ -1,10 +1,14 @@
 package rewerse
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 )
 
+// ErrNoMarketsFound is returned by MarketSearch if the query matches no market
+var ErrNoMarketsFound = errors.New("no markets found")
+
 // GetMarketDetails returns the details of the market with the given ID.
 func GetMarketDetails(marketID string) (md MarketDetails, err error) {
 	req, err := BuildCustomRequest(clientHost, "stationary-markets/"+marketID)
@@ -43,7 +47,7 @@ func MarketSearch(searchQuery string) (markets Markets, err error) {
 	}
 
 	if len(res.Data.MarketSearch.Markets) == 0 {
-		err = fmt.Errorf("no markets found for query \"%s\"", searchQuery)
+		err = fmt.Errorf("%w for query \"%s\"", ErrNoMarketsFound, searchQuery)
 		return
 	}
 	markets = res.Data.MarketSearch.Markets
@@ -0,0 +1,317 @@
+package rewerse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MealPlanOpts configures PlanMeals
+type MealPlanOpts struct {
+	// Days is the number of meals to plan (default 5)
+	Days int
+	// Portions is the number of servings per meal (default: as in the recipe)
+	Portions int
+	// Search filters the candidate recipes (collection, difficulty, search term)
+	Search *RecipeSearchOpts
+	// Candidates is the number of recipes that are evaluated (default: 3 per day, max 30)
+	Candidates int
+	// Pantry lists ingredients left out of the shopping list (default: Wasser, Salz, Pfeffer)
+	Pantry []string
+}
+
+// DiscountMatch links a recipe ingredient to a current discount
+type DiscountMatch struct {
+	Ingredient string
+	Discount   Discount
+}
+
+// PlannedMeal is a recipe chosen for one day of the plan
+type PlannedMeal struct {
+	// Day is the 1-based day of the plan
+	Day int
+	// Recipe is the recipe scaled to the requested portions
+	Recipe RecipeDetail
+	// Matches are the ingredients that are currently on offer
+	Matches []DiscountMatch
+}
+
+// ShoppingListLine is an aggregated ingredient across several recipes
+type ShoppingListLine struct {
+	// Name is the ingredient name as used in the first recipe
+	Name string
+	// Quantity is the merged quantity (0 for "to taste")
+	Quantity float64
+	// Unit is the unit of Quantity
+	Unit string
+	// Recipes are the titles of the recipes using the ingredient
+	Recipes []string
+	// Discount is a matching offer, if any
+	Discount *Discount
+}
+
+func (l ShoppingListLine) String() string {
+	return RecipeIngredient{Name: l.Name, Quantity: l.Quantity, Unit: l.Unit}.String()
+}
+
+// MealPlan is a sequence of meals plus the combined shopping list
+type MealPlan struct {
+	MarketID string
+	Portions int
+	// ValidUntil is the last day of the discounts the plan is based on
+	ValidUntil time.Time
+	// Days is the number of requested meals. Meals is shorter if too few recipes were found.
+	Days         int
+	Meals        []PlannedMeal
+	ShoppingList []ShoppingListLine
+	// Errors are the recipes that could not be loaded; they are left out of the plan
+	Errors []string `json:",omitempty"`
+}
+
+func (mp MealPlan) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep(fmt.Sprintf("Essensplan (Angebote bis %s)", mp.ValidUntil.Format("02.01.2006"))))
+	sb.WriteByte('\n')
+	for _, m := range mp.Meals {
+		sb.WriteString(fmt.Sprintf("   Tag %d: %s (%s, %d Angebote)\n", m.Day, m.Recipe.Title, m.Recipe.Duration, len(m.Matches)))
+	}
+	if len(mp.Meals) < mp.Days {
+		sb.WriteString(fmt.Sprintf("   Nur %d von %d Tagen geplant: zu wenige Rezepte\n", len(mp.Meals), mp.Days))
+	}
+	for _, e := range mp.Errors {
+		sb.WriteString("   Fehler: ")
+		sb.WriteString(e)
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteByte('\n')
+	sb.WriteString(sep(fmt.Sprintf("Einkaufsliste (%d)", len(mp.ShoppingList))))
+	sb.WriteByte('\n')
+	for _, l := range mp.ShoppingList {
+		sb.WriteString("   ")
+		sb.WriteString(l.String())
+		if l.Discount != nil {
+			sb.WriteString(fmt.Sprintf(" [Angebot: %s, %s]", l.Discount.Title, l.Discount.PriceRaw))
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// PlanMeals plans meals around the current offers of a market. Candidate recipes from
+// RecipeSearch are scored by how many of their ingredients match a discount from
+// GetDiscounts; the best-scoring recipes are chosen and their ingredients are merged
+// into one shopping list. Recipes whose details fail to load are skipped and collected in
+// Errors; the plan only fails if none could be loaded. With too few recipes, the plan has
+// fewer meals than Days.
+func PlanMeals(marketID string, opts *MealPlanOpts) (MealPlan, error) {
+	return planMeals(marketID, opts, GetDiscounts, RecipeSearch, GetRecipeDetails)
+}
+
+func planMeals(marketID string, opts *MealPlanOpts, discounts func(string) (Discounts, error), search func(*RecipeSearchOpts) (RecipeSearchResults, error), details func(string) (RecipeDetails, error)) (MealPlan, error) {
+	if marketID == "" {
+		return MealPlan{}, fmt.Errorf("marketID: cannot be empty")
+	}
+	if opts == nil {
+		opts = &MealPlanOpts{}
+	}
+	days := opts.Days
+	if days <= 0 {
+		days = 5
+	}
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = days * 3
+		if candidates > 30 {
+			candidates = 30
+		}
+	}
+	pantry := opts.Pantry
+	if pantry == nil {
+		pantry = defaultPantryItems
+	}
+
+	ds, err := discounts(marketID)
+	if err != nil && len(ds.Categories) == 0 {
+		return MealPlan{}, fmt.Errorf("error loading discounts: %w", err)
+	}
+	offers := discountOffers(ds)
+
+	searchOpts := RecipeSearchOpts{}
+	if opts.Search != nil {
+		searchOpts = *opts.Search
+	}
+	searchOpts.ObjectsPerPage = candidates
+	results, err := search(&searchOpts)
+	if err != nil {
+		return MealPlan{}, fmt.Errorf("error searching recipes: %w", err)
+	}
+	if len(results.Recipes) == 0 {
+		return MealPlan{}, fmt.Errorf("no recipes found")
+	}
+
+	var scored []PlannedMeal
+	var failed []string
+	for _, r := range results.Recipes {
+		rd, err := details(r.ID)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("recipe %s: %v", r.ID, err))
+			continue
+		}
+		recipe := rd.Recipe.Scale(opts.Portions)
+		scored = append(scored, PlannedMeal{
+			Recipe:  recipe,
+			Matches: matchDiscounts(recipe, offers, pantry),
+		})
+	}
+
+	if len(scored) == 0 {
+		return MealPlan{}, fmt.Errorf("no recipe could be loaded: %s", failed[0])
+	}
+
+	// stable, so equally scored recipes keep the search order
+	sort.SliceStable(scored, func(i, j int) bool {
+		return len(scored[i].Matches) > len(scored[j].Matches)
+	})
+	if len(scored) > days {
+		scored = scored[:days]
+	}
+
+	mp := MealPlan{
+		MarketID:   marketID,
+		Portions:   opts.Portions,
+		ValidUntil: ds.ValidUntil,
+		Days:       days,
+		Errors:     failed,
+	}
+	recipes := make([]RecipeDetail, len(scored))
+	for i := range scored {
+		scored[i].Day = i + 1
+		recipes[i] = scored[i].Recipe
+	}
+	mp.Meals = scored
+	mp.ShoppingList = MergeIngredients(recipes, pantry)
+
+	for i := range mp.ShoppingList {
+		if d, ok := findDiscount(ingredientQuery(mp.ShoppingList[i].Name), offers); ok {
+			mp.ShoppingList[i].Discount = &d
+		}
+	}
+
+	return mp, nil
+}
+
+// MergeIngredients combines the ingredients of several recipes into one list.
+// Ingredients with the same name are merged if their units can be converted into each
+// other (g and kg, ml, l, EL and TL); otherwise they get separate lines. Lines that only
+// ever saw one unit keep it, mixed lines are expressed in g or ml.
+// Ingredients listed in pantry are left out.
+func MergeIngredients(recipes []RecipeDetail, pantry []string) []ShoppingListLine {
+	type key struct {
+		name string
+		unit string
+	}
+	// mergeState tracks the original unit of a line so unmixed lines can keep it
+	type mergeState struct {
+		unit  string
+		raw   float64
+		mixed bool
+	}
+
+	var lines []ShoppingListLine
+	var states []mergeState
+	index := make(map[key]int)
+
+	for _, r := range recipes {
+		for _, ing := range r.Ingredients.Items {
+			query := ingredientQuery(ing.Name)
+			if isPantryItem(query, pantry) {
+				continue
+			}
+
+			// merge in the base unit of the dimension if the unit is convertible
+			unit := NormalizeUnit(ing.Unit)
+			base, quantity := unit, ing.Quantity
+			if dim, factor, ok := unitBase(unit); ok && dim != dimCount {
+				quantity *= factor
+				base = UnitGram
+				if dim == dimVolume {
+					base = UnitMilliliter
+				}
+			}
+
+			k := key{name: strings.ToLower(query), unit: base}
+			i, ok := index[k]
+			if !ok {
+				index[k] = len(lines)
+				lines = append(lines, ShoppingListLine{Name: query, Quantity: quantity, Unit: base, Recipes: []string{r.Title}})
+				states = append(states, mergeState{unit: unit, raw: ing.Quantity})
+				continue
+			}
+
+			lines[i].Quantity += quantity
+			states[i].raw += ing.Quantity
+			if states[i].unit != unit {
+				states[i].mixed = true
+			}
+			if last := lines[i].Recipes[len(lines[i].Recipes)-1]; last != r.Title {
+				lines[i].Recipes = append(lines[i].Recipes, r.Title)
+			}
+		}
+	}
+
+	for i := range lines {
+		if !states[i].mixed {
+			lines[i].Quantity, lines[i].Unit = states[i].raw, states[i].unit
+		}
+		lines[i].Quantity, lines[i].Unit = NormalizeQuantity(lines[i].Quantity, lines[i].Unit)
+	}
+	return lines
+}
+
+// discountOffers flattens the discount categories
+func discountOffers(ds Discounts) []Discount {
+	var offers []Discount
+	for _, cat := range ds.Categories {
+		offers = append(offers, cat.Offers...)
+	}
+	return offers
+}
+
+// matchDiscounts returns the recipe ingredients that are on offer
+func matchDiscounts(r RecipeDetail, offers []Discount, pantry []string) []DiscountMatch {
+	var matches []DiscountMatch
+	for _, ing := range r.Ingredients.Items {
+		query := ingredientQuery(ing.Name)
+		if isPantryItem(query, pantry) {
+			continue
+		}
+		if d, ok := findDiscount(query, offers); ok {
+			matches = append(matches, DiscountMatch{Ingredient: ing.Name, Discount: d})
+		}
+	}
+	return matches
+}
+
+// findDiscount returns the first offer whose title contains the ingredient's main word.
+// Words shorter than 4 letters are ignored to avoid matches like "Ei" in "Eis".
+func findDiscount(query string, offers []Discount) (Discount, bool) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return Discount{}, false
+	}
+	main := words[len(words)-1]
+	if len([]rune(main)) < 4 {
+		return Discount{}, false
+	}
+
+	for _, d := range offers {
+		if strings.Contains(strings.ToLower(d.Title), main) {
+			return d, true
+		}
+	}
+	return Discount{}, false
+}
@@ -0,0 +1,94 @@
+package rewerse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMergeIngredients(t *testing.T) {
+	recipes := []RecipeDetail{
+		{Title: "Gnocchi-Pfanne", Ingredients: RecipeIngredients{Portions: 2, Items: []RecipeIngredient{
+			{Name: "Olivenöl", Quantity: 4, Unit: "EL"},
+			{Name: "Parmesan", Quantity: 20, Unit: "g"},
+			{Name: "Knoblauch", Quantity: 1, Unit: "Zehe(n)"},
+			{Name: "Salz", Quantity: 0},
+		}}},
+		{Title: "Pasta", Ingredients: RecipeIngredients{Portions: 2, Items: []RecipeIngredient{
+			{Name: "Olivenöl", Quantity: 2, Unit: "EL"},
+			{Name: "Parmesan (gerieben)", Quantity: 0.5, Unit: "kg"},
+			{Name: "Knoblauch", Quantity: 2, Unit: "Zehe(n)"},
+			{Name: "Spaghetti", Quantity: 250, Unit: "g"},
+		}}},
+	}
+
+	lines := MergeIngredients(recipes, defaultPantryItems)
+	want := []string{
+		"6 EL Olivenöl",
+		"520 g Parmesan",
+		"3 Zehen Knoblauch",
+		"250 g Spaghetti",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %+v", len(want), len(lines), lines)
+	}
+	for i, w := range want {
+		if got := lines[i].String(); got != w {
+			t.Errorf("line %d: expected %q, got %q", i, w, got)
+		}
+	}
+	if len(lines[0].Recipes) != 2 {
+		t.Errorf("Olivenöl: expected 2 recipes, got %v", lines[0].Recipes)
+	}
+}
+
+func TestFindDiscount(t *testing.T) {
+	offers := []Discount{{Title: "Eis am Stiel"}, {Title: "Bonduelle Rosenkohl"}}
+
+	if d, ok := findDiscount("frischer Rosenkohl", offers); !ok || d.Title != "Bonduelle Rosenkohl" {
+		t.Errorf("expected Rosenkohl offer, got %q (ok=%v)", d.Title, ok)
+	}
+	if _, ok := findDiscount("Ei", offers); ok {
+		t.Error("short words must not match")
+	}
+}
+
+func TestPlanMeals(t *testing.T) {
+	discounts := func(string) (Discounts, error) {
+		return Discounts{Categories: []DiscountCategory{{Offers: []Discount{{Title: "Bonduelle Rosenkohl"}}}}}, nil
+	}
+	search := func(*RecipeSearchOpts) (RecipeSearchResults, error) {
+		return RecipeSearchResults{Recipes: []Recipe{{ID: "1"}, {ID: "2"}, {ID: "3"}}}, nil
+	}
+	recipes := map[string]RecipeDetail{
+		"1": {Title: "Pasta", Ingredients: RecipeIngredients{Portions: 2, Items: []RecipeIngredient{{Name: "Spaghetti", Quantity: 250, Unit: "g"}}}},
+		"3": {Title: "Rosenkohl-Pfanne", Ingredients: RecipeIngredients{Portions: 2, Items: []RecipeIngredient{{Name: "frischer Rosenkohl", Quantity: 600, Unit: "g"}}}},
+	}
+	details := func(id string) (RecipeDetails, error) {
+		r, ok := recipes[id]
+		if !ok {
+			return RecipeDetails{}, errors.New("timeout")
+		}
+		return RecipeDetails{Recipe: r}, nil
+	}
+
+	mp, err := planMeals("831002", &MealPlanOpts{Days: 3}, discounts, search, details)
+	if err != nil {
+		t.Fatalf("planMeals failed: %v", err)
+	}
+	// recipe 2 is skipped; the discounted recipe comes first
+	if len(mp.Meals) != 2 || mp.Meals[0].Recipe.Title != "Rosenkohl-Pfanne" || mp.Meals[1].Day != 2 {
+		t.Fatalf("unexpected meals: %+v", mp.Meals)
+	}
+	if len(mp.Errors) != 1 || !strings.Contains(mp.Errors[0], "recipe 2") {
+		t.Errorf("unexpected errors: %v", mp.Errors)
+	}
+	if out := mp.String(); !strings.Contains(out, "Nur 2 von 3 Tagen geplant") || !strings.Contains(out, "Fehler: recipe 2") {
+		t.Errorf("unexpected String():\n%s", out)
+	}
+
+	recipes = nil
+	if _, err := planMeals("831002", &MealPlanOpts{Days: 3}, discounts, search, details); err == nil {
+		t.Error("expected error if no recipe can be loaded")
+	}
+}
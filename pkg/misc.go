@@ -3,6 +3,7 @@ package rewerse
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type recallsResponse struct {
@@ -103,6 +104,8 @@ type Recipe struct {
 	Duration              string `json:"duration"`
 	DifficultyLevel       int    `json:"difficultyLevel"`
 	DifficultyDescription string `json:"difficultyDescription"`
+	// CookingTime is Duration parsed (0 if it can't be parsed), in nanoseconds in JSON
+	CookingTime time.Duration `json:"cookingTime"`
 }
 
 func (r Recipe) String() string {
@@ -121,6 +124,8 @@ func GetRecipeHub() (r RecipeHub, err error) {
 		return
 	}
 
+	r.RecipeOfTheDay.CookingTime, _ = ParseRecipeDuration(r.RecipeOfTheDay.Duration)
+	setCookingTimes(r.PopularRecipes)
 	return
 }
 
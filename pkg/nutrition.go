@@ -0,0 +1,395 @@
+package rewerse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Preparation states used in NutritionFact.PreparationState
+const (
+	PreparationUnprepared = "Unzubereitet"
+	PreparationPrepared   = "Zubereitet"
+)
+
+// Nutrient identifies a value of Nutrition
+type Nutrient string
+
+const (
+	NutrientEnergyKJ      Nutrient = "kj"
+	NutrientEnergyKcal    Nutrient = "kcal"
+	NutrientFat           Nutrient = "fat"
+	NutrientSaturates     Nutrient = "saturates"
+	NutrientCarbohydrates Nutrient = "carbs"
+	NutrientSugar         Nutrient = "sugar"
+	NutrientFiber         Nutrient = "fiber"
+	NutrientProtein       Nutrient = "protein"
+	NutrientSalt          Nutrient = "salt"
+)
+
+// Nutrients lists all nutrients in label order
+var Nutrients = []Nutrient{
+	NutrientEnergyKJ, NutrientEnergyKcal, NutrientFat, NutrientSaturates,
+	NutrientCarbohydrates, NutrientSugar, NutrientFiber, NutrientProtein, NutrientSalt,
+}
+
+// ParseNutrient validates a nutrient name as used by the CLI: "protein", "sugar", ...
+func ParseNutrient(s string) (Nutrient, error) {
+	for _, n := range Nutrients {
+		if strings.EqualFold(s, string(n)) {
+			return n, nil
+		}
+	}
+	names := make([]string, len(Nutrients))
+	for i, n := range Nutrients {
+		names[i] = string(n)
+	}
+	return "", fmt.Errorf("invalid nutrient: %q (must be one of %s)", s, strings.Join(names, ", "))
+}
+
+// Nutrition contains the nutrition values of a product per 100 g (or 100 ml)
+type Nutrition struct {
+	// PreparationState is "Unzubereitet" or "Zubereitet"
+	PreparationState string
+	EnergyKJ         float64
+	EnergyKcal       float64
+	Fat              float64
+	Saturates        float64
+	Carbohydrates    float64
+	Sugar            float64
+	Fiber            float64
+	Protein          float64
+	Salt             float64
+	// Missing lists the nutrients the product does not declare (their value is 0)
+	Missing []Nutrient
+}
+
+// Value returns the value of a nutrient
+func (n Nutrition) Value(nutrient Nutrient) float64 {
+	switch nutrient {
+	case NutrientEnergyKJ:
+		return n.EnergyKJ
+	case NutrientEnergyKcal:
+		return n.EnergyKcal
+	case NutrientFat:
+		return n.Fat
+	case NutrientSaturates:
+		return n.Saturates
+	case NutrientCarbohydrates:
+		return n.Carbohydrates
+	case NutrientSugar:
+		return n.Sugar
+	case NutrientFiber:
+		return n.Fiber
+	case NutrientProtein:
+		return n.Protein
+	case NutrientSalt:
+		return n.Salt
+	}
+	return 0
+}
+
+// Has reports whether the product declares the nutrient
+func (n Nutrition) Has(nutrient Nutrient) bool {
+	for _, m := range n.Missing {
+		if m == nutrient {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Nutrition) set(nutrient Nutrient, v float64) {
+	switch nutrient {
+	case NutrientEnergyKJ:
+		n.EnergyKJ = v
+	case NutrientEnergyKcal:
+		n.EnergyKcal = v
+	case NutrientFat:
+		n.Fat = v
+	case NutrientSaturates:
+		n.Saturates = v
+	case NutrientCarbohydrates:
+		n.Carbohydrates = v
+	case NutrientSugar:
+		n.Sugar = v
+	case NutrientFiber:
+		n.Fiber = v
+	case NutrientProtein:
+		n.Protein = v
+	case NutrientSalt:
+		n.Salt = v
+	}
+}
+
+// NewNutrition converts a NutritionFact into per-100 values. Energy missing in
+// one unit is derived from the other (1 kcal = 4.184 kJ).
+func NewNutrition(nf NutritionFact) Nutrition {
+	n := Nutrition{PreparationState: nf.PreparationState}
+
+	factor := 1.0
+	if nf.ServingSize != nil && nf.ServingSize.Value > 0 {
+		factor = 100 / nf.ServingSize.Value
+	}
+
+	found := make(map[Nutrient]bool)
+	for _, ni := range nf.NutrientInformation {
+		nutrient, ok := nutrientFromType(ni.NutrientType, ni.QuantityContained.UomShortText)
+		if !ok {
+			continue
+		}
+		v := ni.QuantityContained.Value
+		if strings.EqualFold(ni.QuantityContained.UomShortText, "mg") {
+			v /= 1000
+		}
+		n.set(nutrient, v*factor)
+		found[nutrient] = true
+	}
+
+	if found[NutrientEnergyKJ] && !found[NutrientEnergyKcal] {
+		n.EnergyKcal = math.Round(n.EnergyKJ / 4.184)
+		found[NutrientEnergyKcal] = true
+	}
+	if found[NutrientEnergyKcal] && !found[NutrientEnergyKJ] {
+		n.EnergyKJ = math.Round(n.EnergyKcal * 4.184)
+		found[NutrientEnergyKJ] = true
+	}
+
+	for _, nutrient := range Nutrients {
+		if !found[nutrient] {
+			n.Missing = append(n.Missing, nutrient)
+		}
+	}
+	return n
+}
+
+// nutrientFromType maps the German NutrientType to a Nutrient. Sub-types
+// ("Fett, davon gesättigte Fettsäuren") are checked before their parent.
+func nutrientFromType(nutrientType, unit string) (Nutrient, bool) {
+	t := strings.ToLower(nutrientType)
+	switch {
+	case strings.Contains(t, "energie") || strings.Contains(t, "brennwert"):
+		if strings.EqualFold(unit, "kcal") {
+			return NutrientEnergyKcal, true
+		}
+		return NutrientEnergyKJ, true
+	case strings.Contains(t, "gesättigt") && !strings.Contains(t, "ungesättigt"):
+		return NutrientSaturates, true
+	case strings.Contains(t, "zucker"):
+		return NutrientSugar, true
+	case strings.Contains(t, "ballaststoff"):
+		return NutrientFiber, true
+	case strings.Contains(t, "eiweiß") || strings.Contains(t, "protein"):
+		return NutrientProtein, true
+	case strings.Contains(t, "salz"):
+		return NutrientSalt, true
+	case strings.Contains(t, "kohlenhydrat") && !strings.Contains(t, "davon"):
+		return NutrientCarbohydrates, true
+	case strings.Contains(t, "fett") && !strings.Contains(t, "davon") && !strings.Contains(t, "fettsäuren"):
+		return NutrientFat, true
+	}
+	return "", false
+}
+
+// Nutrition returns the nutrition values for a preparation state.
+// An empty state prefers "Unzubereitet" and falls back to the first declared state.
+func (pd ProductDetail) Nutrition(state string) (Nutrition, error) {
+	if len(pd.NutritionFacts) == 0 {
+		return Nutrition{}, fmt.Errorf("product %s has no nutrition facts", pd.ProductID)
+	}
+
+	want := state
+	if want == "" {
+		want = PreparationUnprepared
+	}
+	for _, nf := range pd.NutritionFacts {
+		if strings.EqualFold(nf.PreparationState, want) {
+			return NewNutrition(nf), nil
+		}
+	}
+	if state == "" {
+		return NewNutrition(pd.NutritionFacts[0]), nil
+	}
+	return Nutrition{}, fmt.Errorf("product %s has no nutrition facts for state %q", pd.ProductID, state)
+}
+
+// NutriScore is a Nutri-Score grade with its points
+type NutriScore struct {
+	// Grade is "A" to "E"
+	Grade string
+	// Points is the computed score (lower is better), 0 if supplied by REWE
+	Points int
+	// Computed is true if the score was computed from the nutrition values
+	Computed bool
+}
+
+func (ns NutriScore) String() string {
+	if ns.Computed {
+		return fmt.Sprintf("%s (berechnet, %d Punkte)", ns.Grade, ns.Points)
+	}
+	return ns.Grade
+}
+
+// Nutri-Score thresholds for general foods (values above the n-th threshold give n points)
+var (
+	nutriEnergyKJ  = []float64{335, 670, 1005, 1340, 1675, 2010, 2345, 2680, 3015, 3350}
+	nutriSugar     = []float64{4.5, 9, 13.5, 18, 22.5, 27, 31, 36, 40, 45}
+	nutriSaturates = []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	nutriSodiumMg  = []float64{90, 180, 270, 360, 450, 540, 630, 720, 810, 900}
+	nutriFiber     = []float64{0.9, 1.9, 2.8, 3.7, 4.7}
+	nutriProtein   = []float64{1.6, 3.2, 4.8, 6.4, 8.0}
+)
+
+// ComputeNutriScore estimates the Nutri-Score with the rules for general foods.
+// The share of fruit, vegetables and nuts is not known from the API and counts as 0,
+// missing fiber counts as 0 as well. Beverages, cheese and fats have different rules
+// and are not handled, so the result is an estimate.
+func ComputeNutriScore(n Nutrition) (NutriScore, error) {
+	for _, required := range []Nutrient{NutrientEnergyKJ, NutrientSugar, NutrientSaturates, NutrientSalt} {
+		if !n.Has(required) {
+			return NutriScore{}, fmt.Errorf("cannot compute Nutri-Score: %s missing", required)
+		}
+	}
+
+	negative := nutriPoints(n.EnergyKJ, nutriEnergyKJ) +
+		nutriPoints(n.Sugar, nutriSugar) +
+		nutriPoints(n.Saturates, nutriSaturates) +
+		nutriPoints(n.Salt*400, nutriSodiumMg) // salt = sodium * 2.5
+	fiber := nutriPoints(n.Fiber, nutriFiber)
+	protein := nutriPoints(n.Protein, nutriProtein)
+
+	// protein only counts if the negative points are low (fruit share is unknown)
+	points := negative - fiber - protein
+	if negative >= 11 {
+		points = negative - fiber
+	}
+
+	var grade string
+	switch {
+	case points <= -1:
+		grade = "A"
+	case points <= 2:
+		grade = "B"
+	case points <= 10:
+		grade = "C"
+	case points <= 18:
+		grade = "D"
+	default:
+		grade = "E"
+	}
+	return NutriScore{Grade: grade, Points: points, Computed: true}, nil
+}
+
+func nutriPoints(v float64, thresholds []float64) int {
+	points := 0
+	for _, t := range thresholds {
+		if v > t {
+			points++
+		}
+	}
+	return points
+}
+
+// NutriScoreGrade returns the Nutri-Score supplied by REWE, or computes it from the
+// unprepared nutrition values if there is none.
+func (pd ProductDetail) NutriScoreGrade() (NutriScore, error) {
+	if s, ok := pd.NutriScore.(string); ok && s != "" {
+		return NutriScore{Grade: strings.ToUpper(s)}, nil
+	}
+	n, err := pd.Nutrition("")
+	if err != nil {
+		return NutriScore{}, err
+	}
+	return ComputeNutriScore(n)
+}
+
+// NutritionRow is a product with its nutrition values in a NutritionComparison
+type NutritionRow struct {
+	Product   ProductDetail
+	Nutrition Nutrition
+	// NutriScore is nil if it could not be determined
+	NutriScore *NutriScore
+	// Available is false if the product has no nutrition facts for the state
+	Available bool
+}
+
+// NutritionComparison compares the nutrition values of several products
+type NutritionComparison []NutritionRow
+
+// CompareNutrition collects the nutrition values of the products for a preparation
+// state (empty: "Unzubereitet" where available). The order of products is kept.
+func CompareNutrition(products []ProductDetail, state string) NutritionComparison {
+	rows := make(NutritionComparison, 0, len(products))
+	for _, pd := range products {
+		row := NutritionRow{Product: pd}
+		if n, err := pd.Nutrition(state); err == nil {
+			row.Nutrition = n
+			row.Available = true
+		}
+		if ns, err := pd.NutriScoreGrade(); err == nil {
+			row.NutriScore = &ns
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// SortBy sorts the rows by a nutrient, ascending or descending.
+// Products without the value are sorted last.
+func (nc NutritionComparison) SortBy(nutrient Nutrient, descending bool) {
+	sort.SliceStable(nc, func(i, j int) bool {
+		a, b := nc[i], nc[j]
+		aOK := a.Available && a.Nutrition.Has(nutrient)
+		bOK := b.Available && b.Nutrition.Has(nutrient)
+		if aOK != bOK {
+			return aOK
+		}
+		if descending {
+			return a.Nutrition.Value(nutrient) > b.Nutrition.Value(nutrient)
+		}
+		return a.Nutrition.Value(nutrient) < b.Nutrition.Value(nutrient)
+	})
+}
+
+func (nc NutritionComparison) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep("Nährwerte pro 100 g/ml"))
+	sb.WriteByte('\n')
+	sb.WriteString(fmt.Sprintf("   %-32s %6s %6s %6s %6s %6s %6s %6s %3s\n",
+		"Produkt", "kcal", "Fett", "ges.", "KH", "Zucker", "Eiweiß", "Salz", "NS"))
+	for _, row := range nc {
+		title := []rune(row.Product.Title)
+		if len(title) > 32 {
+			title = append(title[:31], '…')
+		}
+		sb.WriteString(fmt.Sprintf("   %-32s", string(title)))
+
+		if !row.Available {
+			sb.WriteString(" keine Angaben\n")
+			continue
+		}
+		n := row.Nutrition
+		for _, nutrient := range []Nutrient{NutrientEnergyKcal, NutrientFat, NutrientSaturates,
+			NutrientCarbohydrates, NutrientSugar, NutrientProtein, NutrientSalt} {
+			if n.Has(nutrient) {
+				sb.WriteString(fmt.Sprintf(" %6.1f", n.Value(nutrient)))
+			} else {
+				sb.WriteString(fmt.Sprintf(" %6s", "-"))
+			}
+		}
+
+		grade := "-"
+		if row.NutriScore != nil {
+			grade = row.NutriScore.Grade
+			if row.NutriScore.Computed {
+				grade += "*"
+			}
+		}
+		sb.WriteString(fmt.Sprintf(" %3s\n", grade))
+	}
+	sb.WriteString("\n   * = Nutri-Score berechnet (Schätzung)\n")
+
+	return sb.String()
+}
@@ -0,0 +1,152 @@
+package rewerse
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestProductNutrition(t *testing.T) {
+	var res productDetailResponse
+	loadJSONFixture(t, "product_detail.json", &res)
+	pd := res.Data.Product[0]
+
+	n, err := pd.Nutrition("")
+	if err != nil {
+		t.Fatalf("Nutrition failed: %v", err)
+	}
+	if n.PreparationState != PreparationUnprepared {
+		t.Errorf("state: expected %s, got %s", PreparationUnprepared, n.PreparationState)
+	}
+
+	want := map[Nutrient]float64{
+		NutrientEnergyKJ:      2440,
+		NutrientEnergyKcal:    589,
+		NutrientFat:           46,
+		NutrientSaturates:     5.9,
+		NutrientCarbohydrates: 15,
+		NutrientSugar:         5,
+		NutrientProtein:       25,
+		NutrientSalt:          1.3,
+	}
+	for nutrient, v := range want {
+		if got := n.Value(nutrient); math.Abs(got-v) > 1e-9 {
+			t.Errorf("%s: expected %.1f, got %.1f", nutrient, v, got)
+		}
+	}
+	if n.Has(NutrientFiber) {
+		t.Error("fiber: expected missing")
+	}
+
+	if _, err := pd.Nutrition(PreparationPrepared); err == nil {
+		t.Error("Zubereitet: expected error, fixture only has Unzubereitet")
+	}
+}
+
+func TestNewNutritionFattyAcids(t *testing.T) {
+	var nf NutritionFact
+	data := `{
+		"preparationState": "Unzubereitet",
+		"nutrientInformation": [
+			{"nutrientType": "Fett", "quantityContained": {"value": 30, "uomShortText": "g"}},
+			{"nutrientType": "gesättigte Fettsäuren", "quantityContained": {"value": 4, "uomShortText": "g"}},
+			{"nutrientType": "einfach ungesättigte Fettsäuren", "quantityContained": {"value": 18, "uomShortText": "g"}},
+			{"nutrientType": "mehrfach ungesättigte Fettsäuren", "quantityContained": {"value": 8, "uomShortText": "g"}}
+		]
+	}`
+	if err := json.Unmarshal([]byte(data), &nf); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	n := NewNutrition(nf)
+	if n.Fat != 30 || n.Saturates != 4 {
+		t.Errorf("expected fat 30 and saturates 4, got %.1f and %.1f", n.Fat, n.Saturates)
+	}
+}
+
+func TestNewNutritionServingSize(t *testing.T) {
+	var nf NutritionFact
+	data := `{
+		"preparationState": "Zubereitet",
+		"nutrientInformation": [
+			{"nutrientType": "Energie", "quantityContained": {"value": 100, "uomShortText": "kcal"}},
+			{"nutrientType": "Salz", "quantityContained": {"value": 200, "uomShortText": "mg"}}
+		],
+		"servingSize": {"value": 250, "uomShortText": "ml"}
+	}`
+	if err := json.Unmarshal([]byte(data), &nf); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	n := NewNutrition(nf)
+	if n.EnergyKcal != 40 {
+		t.Errorf("kcal per 100: expected 40, got %.1f", n.EnergyKcal)
+	}
+	if n.EnergyKJ != 167 {
+		t.Errorf("kJ derived from kcal: expected 167, got %.1f", n.EnergyKJ)
+	}
+	if math.Abs(n.Salt-0.08) > 1e-9 {
+		t.Errorf("salt per 100: expected 0.08, got %.3f", n.Salt)
+	}
+}
+
+func TestComputeNutriScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     Nutrition
+		grade string
+	}{
+		// 2440 kJ: 7, sugar 5: 1, saturates 5.9: 5, sodium 520 mg: 5 -> 18
+		{"mixed nuts", Nutrition{EnergyKJ: 2440, Sugar: 5, Saturates: 5.9, Salt: 1.3, Protein: 25}, "D"},
+		// 150 kJ, no sugar, little salt, protein and fiber -> negative
+		{"vegetables", Nutrition{EnergyKJ: 150, Sugar: 2, Saturates: 0.1, Salt: 0.05, Protein: 3, Fiber: 3}, "A"},
+		{"soft drink", Nutrition{EnergyKJ: 1800, Sugar: 50, Saturates: 12, Salt: 2.5}, "E"},
+	}
+	for _, tt := range tests {
+		ns, err := ComputeNutriScore(tt.n)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if ns.Grade != tt.grade {
+			t.Errorf("%s: expected %s, got %s (%d points)", tt.name, tt.grade, ns.Grade, ns.Points)
+		}
+	}
+
+	if _, err := ComputeNutriScore(Nutrition{Missing: []Nutrient{NutrientSugar}}); err == nil {
+		t.Error("expected error for missing sugar")
+	}
+}
+
+func TestNutritionComparisonSort(t *testing.T) {
+	var res productDetailResponse
+	loadJSONFixture(t, "product_detail.json", &res)
+	pd := res.Data.Product[0]
+	low := pd
+	low.ProductID = "low"
+	low.NutritionFacts = []NutritionFact{{
+		PreparationState: PreparationUnprepared,
+		NutrientInformation: []NutrientInfo{
+			{NutrientType: "Eiweiß", QuantityContained: struct {
+				Value        float64 `json:"value"`
+				UomShortText string  `json:"uomShortText"`
+				UomLongText  string  `json:"uomLongText"`
+			}{Value: 3, UomShortText: "g"}},
+		},
+	}}
+	none := pd
+	none.ProductID = "none"
+	none.NutritionFacts = nil
+
+	nc := CompareNutrition([]ProductDetail{none, low, pd}, "")
+	nc.SortBy(NutrientProtein, true)
+	if nc[0].Product.ProductID != pd.ProductID || nc[1].Product.ProductID != "low" || nc[2].Product.ProductID != "none" {
+		t.Errorf("unexpected order: %s, %s, %s", nc[0].Product.ProductID, nc[1].Product.ProductID, nc[2].Product.ProductID)
+	}
+	if nc[0].NutriScore == nil || !nc[0].NutriScore.Computed {
+		t.Error("expected computed Nutri-Score for fixture product")
+	}
+	if nc[2].Available {
+		t.Error("product without nutrition facts should not be available")
+	}
+}
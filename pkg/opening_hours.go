@@ -0,0 +1,359 @@
+package rewerse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// embedded so Europe/Berlin also works on systems without zoneinfo (Windows)
+	_ "time/tzdata"
+)
+
+// berlin is the time zone of all opening hours
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// OpeningInfo is one line of the weekly opening hours
+type OpeningInfo struct {
+	// Days is the day range: "Mo - Sa", or a date for exceptions: "03.04."
+	Days string `json:"days"`
+	// Hours is the time range: "07:00 - 22:00", or "geschlossen"
+	Hours string `json:"hours"`
+}
+
+// weekdayNames are the German day abbreviations, indexed by time.Weekday
+var weekdayNames = [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"}
+
+var (
+	// timeRangeRegex matches "07:00 - 22:00" or "7 - 22 Uhr"
+	timeRangeRegex = regexp.MustCompile(`(\d{1,2})(?::(\d{2}))?\s*(?:-|–|bis)\s*(\d{1,2})(?::(\d{2}))?`)
+	// exceptionDateRegex matches dates of exceptions: "03.04.", "24.12.2025"
+	exceptionDateRegex = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})\.(\d{4})?$`)
+)
+
+// TimeRange is an opening period within a day, in minutes since midnight. Periods past
+// midnight ("07:00 - 01:00") have a Close after 24:00; RangesOn splits them.
+type TimeRange struct {
+	Open  int `json:"open"`
+	Close int `json:"close"`
+}
+
+func (tr TimeRange) String() string {
+	closing := tr.Close
+	if closing > 24*60 {
+		closing -= 24 * 60
+	}
+	return fmt.Sprintf("%02d:%02d - %02d:%02d", tr.Open/60, tr.Open%60, closing/60, closing%60)
+}
+
+// OpeningSchedule is a parsed weekly schedule with date exceptions and public holidays
+type OpeningSchedule struct {
+	// Weekly contains the opening periods per day, indexed by time.Weekday
+	Weekly [7][]TimeRange `json:"weekly"`
+	// Exceptions overrides the weekly schedule on dates given as "YYYY-MM-DD"; an empty
+	// list means closed
+	Exceptions map[string][]TimeRange `json:"exceptions,omitempty"`
+	// State selects the public holidays the market is closed on ("" = none)
+	State FederalState `json:"state,omitempty"`
+}
+
+func (s OpeningSchedule) String() string {
+	var sb strings.Builder
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		sb.WriteString(align(weekdayNames[day]))
+		sb.WriteString(formatRanges(s.Weekly[day]))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func formatRanges(ranges []TimeRange) string {
+	if len(ranges) == 0 {
+		return "geschlossen"
+	}
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseOpeningHours parses OpeningInfo lines like {"Mo - Sa", "07:00 - 22:00"} and
+// exceptions like {"03.04.", "geschlossen"}. Days that are not listed are closed.
+// Exceptions without a year are taken to be in the year that puts them nearest to ref.
+func ParseOpeningHours(info []OpeningInfo, ref time.Time) (OpeningSchedule, error) {
+	var s OpeningSchedule
+	for _, oi := range info {
+		ranges, err := parseTimeRanges(oi.Hours)
+		if err != nil {
+			return s, err
+		}
+
+		days := strings.TrimSpace(oi.Days)
+		if m := exceptionDateRegex.FindStringSubmatch(days); m != nil {
+			day, _ := strconv.Atoi(m[1])
+			month, _ := strconv.Atoi(m[2])
+			var date time.Time
+			if m[3] != "" {
+				year, _ := strconv.Atoi(m[3])
+				date = time.Date(year, time.Month(month), day, 0, 0, 0, 0, berlin)
+			} else {
+				date = nearestDate(time.Month(month), day, ref)
+			}
+			key := date.Format("2006-01-02")
+			if s.Exceptions == nil {
+				s.Exceptions = make(map[string][]TimeRange)
+			}
+			s.Exceptions[key] = ranges
+			continue
+		}
+
+		weekdays, err := parseWeekdays(days)
+		if err != nil {
+			return s, err
+		}
+		for _, d := range weekdays {
+			s.Weekly[d] = ranges
+		}
+	}
+	return s, nil
+}
+
+// nearestDate returns the date with the given month and day closest to ref
+func nearestDate(month time.Month, day int, ref time.Time) time.Time {
+	ref = ref.In(berlin)
+	var best time.Time
+	for year := ref.Year() - 1; year <= ref.Year()+1; year++ {
+		date := time.Date(year, month, day, 0, 0, 0, 0, berlin)
+		if best.IsZero() || absDuration(date.Sub(ref)) < absDuration(best.Sub(ref)) {
+			best = date
+		}
+	}
+	return best
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// parseTimeRanges parses "07:00 - 22:00", "07:00 - 13:00, 14:00 - 20:00" or "geschlossen".
+// A closing time before the opening time is on the next day: "07:00 - 01:00".
+func parseTimeRanges(hours string) ([]TimeRange, error) {
+	if strings.Contains(strings.ToLower(hours), "geschlossen") {
+		return []TimeRange{}, nil
+	}
+	matches := timeRangeRegex.FindAllStringSubmatch(hours, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("invalid opening hours: %q", hours)
+	}
+
+	ranges := make([]TimeRange, 0, len(matches))
+	for _, m := range matches {
+		open := atoiDefault(m[1])*60 + atoiDefault(m[2])
+		closing := atoiDefault(m[3])*60 + atoiDefault(m[4])
+		if open >= 24*60 || closing > 24*60 || closing == open {
+			return nil, fmt.Errorf("invalid opening hours: %q", hours)
+		}
+		if closing < open {
+			closing += 24 * 60
+		}
+		ranges = append(ranges, TimeRange{Open: open, Close: closing})
+	}
+	return ranges, nil
+}
+
+func atoiDefault(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// parseWeekdays parses "Mo - Sa", "Mo, Mi, Fr" or "So"
+func parseWeekdays(days string) ([]time.Weekday, error) {
+	var result []time.Weekday
+	for _, part := range strings.Split(days, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("invalid opening days: %q", days)
+		}
+		from, err := ParseWeekday(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = ParseWeekday(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		// ranges run Monday-based: "Fr - Mo" wraps over the weekend
+		for d := from; ; d = (d + 1) % 7 {
+			result = append(result, d)
+			if d == to {
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// ParseWeekday parses a German day name or abbreviation: "Mo", "Di.", "Samstag"
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	if len(s) >= 2 {
+		for d, name := range weekdayNames {
+			if strings.HasPrefix(s, strings.ToLower(name)) {
+				return time.Weekday(d), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday: %q", s)
+}
+
+// RangesOn returns the opening periods on the date of t (in Europe/Berlin). Periods past
+// midnight are split: the part after midnight is returned for the next day.
+// Date exceptions win over public holidays, which win over the weekly schedule.
+func (s OpeningSchedule) RangesOn(t time.Time) []TimeRange {
+	t = t.In(berlin)
+	var ranges []TimeRange
+	for _, r := range s.dayRanges(t.AddDate(0, 0, -1)) {
+		if r.Close > 24*60 {
+			ranges = append(ranges, TimeRange{Open: 0, Close: r.Close - 24*60})
+		}
+	}
+	for _, r := range s.dayRanges(t) {
+		if r.Close > 24*60 {
+			r.Close = 24 * 60
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// dayRanges returns the periods starting on the date of t, unsplit
+func (s OpeningSchedule) dayRanges(t time.Time) []TimeRange {
+	if r, ok := s.Exceptions[t.Format("2006-01-02")]; ok {
+		return r
+	}
+	if s.State != "" {
+		if _, ok := PublicHoliday(t, s.State); ok {
+			return nil
+		}
+	}
+	return s.Weekly[t.Weekday()]
+}
+
+// IsOpenAt reports whether the market is open at t
+func (s OpeningSchedule) IsOpenAt(t time.Time) bool {
+	t = t.In(berlin)
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range s.RangesOn(t) {
+		if minute >= r.Open && minute < r.Close {
+			return true
+		}
+	}
+	return false
+}
+
+// maxScheduleDays limits the search of NextOpening and NextClosing
+const maxScheduleDays = 14
+
+// NextOpening returns the next time after t at which the market opens.
+// If it is open at t, that is the opening after the current period.
+func (s OpeningSchedule) NextOpening(t time.Time) (time.Time, bool) {
+	t = t.In(berlin)
+	for day := 0; day < maxScheduleDays; day++ {
+		date := startOfDay(t).AddDate(0, 0, day)
+		for _, r := range s.RangesOn(date) {
+			open := atMinute(date, r.Open)
+			if open.After(t) && !s.IsOpenAt(open.Add(-time.Minute)) {
+				return open, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// NextClosing returns the end of the current opening period, or of the next one if the
+// market is closed at t
+func (s OpeningSchedule) NextClosing(t time.Time) (time.Time, bool) {
+	t = t.In(berlin)
+	for day := 0; day < maxScheduleDays; day++ {
+		date := startOfDay(t).AddDate(0, 0, day)
+		for _, r := range s.RangesOn(date) {
+			closing := atMinute(date, r.Close)
+			// periods ending at 24:00 continue if the next day opens at 00:00
+			if closing.After(t) && !s.IsOpenAt(closing) {
+				return closing, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// atMinute returns the wall clock time minute minutes after midnight on the date of t.
+// Unlike adding a duration to midnight, it stays correct on DST changeover days.
+func atMinute(t time.Time, minute int) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, minute/60, minute%60, 0, 0, t.Location())
+}
+
+// Schedule parses the opening hours of the market, with yearless exceptions near ref.
+// Public holidays are taken from the federal state derived from the zip code.
+func (m Market) Schedule(ref time.Time) (OpeningSchedule, error) {
+	s, err := ParseOpeningHours(m.OpeningInfo, ref)
+	if err != nil {
+		return s, err
+	}
+	s.State, _ = FederalStateForZip(m.ZipCode)
+	return s, nil
+}
+
+// IsOpenAt reports whether the market is open at t. Markets with unparseable
+// opening hours count as closed.
+func (m Market) IsOpenAt(t time.Time) bool {
+	s, err := m.Schedule(t)
+	return err == nil && s.IsOpenAt(t)
+}
+
+// NextWeekdayTime returns the next time from now that falls on the given day and time
+// in Europe/Berlin: "Sa 21:30", "Mo 07:00". A time earlier today means next week.
+func NextWeekdayTime(s string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return time.Time{}, fmt.Errorf("invalid day and time: %q (expected e.g. \"Sa 21:30\")", s)
+	}
+	day, err := ParseWeekday(fields[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+	clock, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %q", fields[1])
+	}
+
+	now = now.In(berlin)
+	t := atMinute(now, clock.Hour()*60+clock.Minute())
+	t = t.AddDate(0, 0, (int(day)-int(now.Weekday())+7)%7)
+	if t.Before(now) {
+		t = t.AddDate(0, 0, 7)
+	}
+	return t, nil
+}
@@ -0,0 +1,210 @@
+package rewerse
+
+import (
+	"testing"
+	"time"
+)
+
+func berlinTime(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, berlin)
+}
+
+func TestParseOpeningHours(t *testing.T) {
+	var market Market
+	for _, m := range loadMarketSearchFixture(t) {
+		if m.WWIdent == "540934" {
+			market = m
+		}
+	}
+	if market.WWIdent == "" {
+		t.Fatal("market 540934 not in fixture")
+	}
+
+	s, err := market.Schedule(berlinTime(2025, 5, 8, 12, 0))
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if s.State != StateHamburg {
+		t.Errorf("expected state HH, got %q", s.State)
+	}
+	if got := formatRanges(s.Weekly[time.Friday]); got != "07:00 - 23:00" {
+		t.Errorf("unexpected friday hours: %s", got)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		open bool
+	}{
+		{"thursday evening", berlinTime(2025, 5, 8, 21, 59), true},
+		{"thursday closing time", berlinTime(2025, 5, 8, 22, 0), false},
+		{"friday late", berlinTime(2025, 5, 9, 22, 30), true},
+		{"sunday", berlinTime(2025, 5, 11, 12, 0), false},
+		{"date exception", berlinTime(2025, 4, 3, 12, 0), false},
+		{"date exception is not repeated", berlinTime(2024, 4, 3, 12, 0), true},
+		{"reformation day in Hamburg", berlinTime(2025, 10, 31, 12, 0), false},
+		{"utc input", time.Date(2025, 5, 9, 20, 30, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := s.IsOpenAt(tt.at); got != tt.open {
+			t.Errorf("%s: expected open=%v, got %v", tt.name, tt.open, got)
+		}
+	}
+
+	// reformation day is no holiday in Baden-Württemberg
+	s.State = StateBadenWuerttemberg
+	if !s.IsOpenAt(berlinTime(2025, 10, 31, 12, 0)) {
+		t.Error("expected market to be open on reformation day in BW")
+	}
+}
+
+func TestNextOpeningClosing(t *testing.T) {
+	s, err := ParseOpeningHours([]OpeningInfo{
+		{Days: "Mo - Fr", Hours: "08:00 - 12:00, 14:00 - 20:00"},
+		{Days: "Sa", Hours: "08:00 - 14:00"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("ParseOpeningHours failed: %v", err)
+	}
+	s.State = StateNordrheinWestfalen
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		opening   time.Time
+		closing   time.Time
+		wantOpen  bool
+		wantClose bool
+	}{
+		{"lunch break", berlinTime(2025, 5, 6, 12, 30),
+			berlinTime(2025, 5, 6, 14, 0), berlinTime(2025, 5, 6, 20, 0), true, true},
+		{"while open", berlinTime(2025, 5, 6, 9, 0),
+			berlinTime(2025, 5, 6, 14, 0), berlinTime(2025, 5, 6, 12, 0), true, true},
+		{"saturday evening", berlinTime(2025, 5, 10, 18, 0),
+			berlinTime(2025, 5, 12, 8, 0), berlinTime(2025, 5, 12, 12, 0), true, true},
+		// Pfingstmontag 2025-06-09
+		{"before holiday", berlinTime(2025, 6, 7, 15, 0),
+			berlinTime(2025, 6, 10, 8, 0), berlinTime(2025, 6, 10, 12, 0), true, true},
+	}
+	for _, tt := range tests {
+		opening, ok := s.NextOpening(tt.at)
+		if ok != tt.wantOpen || !opening.Equal(tt.opening) {
+			t.Errorf("%s: expected next opening %v, got %v", tt.name, tt.opening, opening)
+		}
+		closing, ok := s.NextClosing(tt.at)
+		if ok != tt.wantClose || !closing.Equal(tt.closing) {
+			t.Errorf("%s: expected next closing %v, got %v", tt.name, tt.closing, closing)
+		}
+	}
+
+	if _, ok := (OpeningSchedule{}).NextOpening(time.Now()); ok {
+		t.Error("expected no opening for empty schedule")
+	}
+}
+
+func TestOpeningHoursOvernight(t *testing.T) {
+	s, err := ParseOpeningHours([]OpeningInfo{
+		{Days: "Fr - Sa", Hours: "07:00 - 01:00"},
+		{Days: "So", Hours: "geschlossen"},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("ParseOpeningHours failed: %v", err)
+	}
+	if got := formatRanges(s.Weekly[time.Friday]); got != "07:00 - 01:00" {
+		t.Errorf("unexpected friday hours: %s", got)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		open bool
+	}{
+		{"friday night", berlinTime(2025, 5, 9, 23, 30), true},
+		{"after midnight", berlinTime(2025, 5, 10, 0, 30), true},
+		{"saturday early", berlinTime(2025, 5, 10, 1, 0), false},
+		{"sunday after midnight", berlinTime(2025, 5, 11, 0, 30), true},
+		{"sunday", berlinTime(2025, 5, 11, 12, 0), false},
+	}
+	for _, tt := range tests {
+		if got := s.IsOpenAt(tt.at); got != tt.open {
+			t.Errorf("%s: expected open=%v, got %v", tt.name, tt.open, got)
+		}
+	}
+
+	// the period continues over midnight
+	if closing, ok := s.NextClosing(berlinTime(2025, 5, 9, 20, 0)); !ok || !closing.Equal(berlinTime(2025, 5, 10, 1, 0)) {
+		t.Errorf("expected closing at 01:00, got %v", closing)
+	}
+}
+
+func TestOpeningHoursDST(t *testing.T) {
+	s, err := ParseOpeningHours([]OpeningInfo{{Days: "Mo - So", Hours: "08:00 - 20:00"}}, time.Now())
+	if err != nil {
+		t.Fatalf("ParseOpeningHours failed: %v", err)
+	}
+	// clocks change on 2025-03-30 and 2025-10-26
+	for _, day := range []time.Time{berlinTime(2025, 3, 30, 1, 0), berlinTime(2025, 10, 26, 1, 0)} {
+		y, m, d := day.Date()
+		if opening, _ := s.NextOpening(day); !opening.Equal(berlinTime(y, m, d, 8, 0)) {
+			t.Errorf("expected opening at 08:00, got %v", opening)
+		}
+		if closing, _ := s.NextClosing(day); !closing.Equal(berlinTime(y, m, d, 20, 0)) {
+			t.Errorf("expected closing at 20:00, got %v", closing)
+		}
+	}
+}
+
+func TestOpeningHoursYearlessException(t *testing.T) {
+	info := []OpeningInfo{{Days: "Mo - So", Hours: "08:00 - 20:00"}, {Days: "03.01.", Hours: "geschlossen"}}
+
+	// in December, the exception is next January's
+	s, err := ParseOpeningHours(info, berlinTime(2025, 12, 20, 12, 0))
+	if err != nil {
+		t.Fatalf("ParseOpeningHours failed: %v", err)
+	}
+	if s.IsOpenAt(berlinTime(2026, 1, 3, 12, 0)) || !s.IsOpenAt(berlinTime(2025, 1, 3, 12, 0)) {
+		t.Errorf("expected the exception on 2026-01-03 only, got %v", s.Exceptions)
+	}
+}
+
+func TestParseOpeningHoursErrors(t *testing.T) {
+	for _, oi := range []OpeningInfo{
+		{Days: "Mo - Sa", Hours: "nach Vereinbarung"},
+		{Days: "Mo - Sa", Hours: "22:00 - 22:00"},
+		{Days: "Werktags", Hours: "07:00 - 22:00"},
+	} {
+		if _, err := ParseOpeningHours([]OpeningInfo{oi}, time.Now()); err == nil {
+			t.Errorf("expected error for %v", oi)
+		}
+	}
+}
+
+func TestNextWeekdayTime(t *testing.T) {
+	// Wednesday, 2025-05-07 12:00
+	now := berlinTime(2025, 5, 7, 12, 0)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"Sa 21:30", berlinTime(2025, 5, 10, 21, 30)},
+		{"mi 13:00", berlinTime(2025, 5, 7, 13, 0)},
+		{"Mi 11:00", berlinTime(2025, 5, 14, 11, 0)},
+		{"Montag 07:00", berlinTime(2025, 5, 12, 7, 0)},
+	}
+	for _, tt := range tests {
+		got, err := NextWeekdayTime(tt.in, now)
+		if err != nil {
+			t.Errorf("NextWeekdayTime(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("NextWeekdayTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"Sa", "Xy 10:00", "Sa 25:00"} {
+		if _, err := NextWeekdayTime(in, now); err == nil {
+			t.Errorf("NextWeekdayTime(%q): expected error", in)
+		}
+	}
+}
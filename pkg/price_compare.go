@@ -0,0 +1,248 @@
+package rewerse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CompareOpts configures ComparePrices and ComparePricesByQuery
+type CompareOpts struct {
+	// ServiceType must match the capabilities of all markets (default: PICKUP)
+	ServiceType ServiceType
+	// Candidates is the number of search results per market for ComparePricesByQuery (default 10)
+	Candidates int
+	// RateLimit bounds the concurrent requests (default: DefaultRateLimit)
+	RateLimit *RateLimit
+}
+
+// MarketPrice is the price of a product in one market
+type MarketPrice struct {
+	MarketID string `json:"marketId"`
+	// Available is true if the market lists the product
+	Available bool `json:"available"`
+	// PriceCents is the current price in cents
+	PriceCents int `json:"priceCents,omitempty"`
+	// Discounted is true if the price is an offer
+	Discounted bool `json:"discounted,omitempty"`
+	// Grammage is the pack size and base price in this market
+	Grammage string `json:"grammage,omitempty"`
+	// Error is set if the market could not be queried
+	Error string `json:"error,omitempty"`
+}
+
+// ComparedProduct is a product with its prices in every compared market
+type ComparedProduct struct {
+	ProductID string `json:"productId"`
+	Title     string `json:"title"`
+	// Prices has one entry per market, in the order of PriceComparison.Markets
+	Prices []MarketPrice `json:"prices"`
+}
+
+// Cheapest returns the market with the lowest price
+func (cp ComparedProduct) Cheapest() (MarketPrice, bool) {
+	var best MarketPrice
+	found := false
+	for _, p := range cp.Prices {
+		if p.Available && (!found || p.PriceCents < best.PriceCents) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// PriceComparison is a product x market price matrix
+type PriceComparison struct {
+	// Markets are the compared market IDs
+	Markets  []string          `json:"markets"`
+	Products []ComparedProduct `json:"products"`
+}
+
+func (pc PriceComparison) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep(fmt.Sprintf("Preisvergleich (%d Märkte)", len(pc.Markets))))
+	sb.WriteByte('\n')
+	sb.WriteString(fmt.Sprintf("   %-40s", "Produkt"))
+	for _, m := range pc.Markets {
+		sb.WriteString(fmt.Sprintf(" %9s", m))
+	}
+	sb.WriteByte('\n')
+
+	for _, p := range pc.Products {
+		title := []rune(p.Title)
+		if len(title) > 40 {
+			title = append(title[:39], '…')
+		}
+		sb.WriteString(fmt.Sprintf("   %-40s", string(title)))
+
+		cheapest, hasCheapest := p.Cheapest()
+		for _, mp := range p.Prices {
+			var cell string
+			switch {
+			case mp.Error != "":
+				cell = "Fehler"
+			case !mp.Available:
+				cell = "-"
+			default:
+				cell = fmt.Sprintf("%.2f", centsToEuros(mp.PriceCents))
+				if mp.Discounted {
+					cell += "%"
+				}
+				if hasCheapest && mp.PriceCents == cheapest.PriceCents {
+					cell = "*" + cell
+				}
+			}
+			sb.WriteString(fmt.Sprintf(" %9s", cell))
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString("\n   * = günstigster Markt, % = Angebot\n")
+
+	return sb.String()
+}
+
+// ComparePrices looks up a product in every market concurrently.
+// Markets that don't list the product are marked as unavailable.
+func ComparePrices(productID string, marketIDs []string, opts *CompareOpts) (PriceComparison, error) {
+	if productID == "" {
+		return PriceComparison{}, fmt.Errorf("productID: cannot be empty")
+	}
+	if len(marketIDs) == 0 {
+		return PriceComparison{}, fmt.Errorf("marketIDs: cannot be empty")
+	}
+	if opts == nil {
+		opts = &CompareOpts{}
+	}
+
+	prices := make([]MarketPrice, len(marketIDs))
+	titles := make([]string, len(marketIDs))
+	opts.RateLimit.run(len(marketIDs), func(i int) {
+		mp := MarketPrice{MarketID: marketIDs[i]}
+		pd, err := GetProductByID(marketIDs[i], productID)
+		switch {
+		case err == nil:
+			mp.Available = true
+			mp.PriceCents = pd.Listing.CurrentRetailPrice
+			mp.Discounted = pd.Listing.Discount != nil
+			mp.Grammage = pd.Listing.Grammage
+			titles[i] = pd.Title
+		case !isNotFound(err):
+			mp.Error = err.Error()
+		}
+		prices[i] = mp
+	})
+
+	cp := ComparedProduct{ProductID: productID, Prices: prices}
+	for _, t := range titles {
+		if t != "" {
+			cp.Title = t
+			break
+		}
+	}
+	if cp.Title == "" {
+		if err := allFailed(prices); err != nil {
+			return PriceComparison{}, err
+		}
+		cp.Title = productID
+	}
+
+	return PriceComparison{Markets: marketIDs, Products: []ComparedProduct{cp}}, nil
+}
+
+// ComparePricesByQuery searches every market concurrently and merges the results by
+// product ID. A product counts as unavailable in a market if it is not among that
+// market's first Candidates results.
+func ComparePricesByQuery(query string, marketIDs []string, opts *CompareOpts) (PriceComparison, error) {
+	if query == "" {
+		return PriceComparison{}, fmt.Errorf("query: cannot be empty")
+	}
+	if len(marketIDs) == 0 {
+		return PriceComparison{}, fmt.Errorf("marketIDs: cannot be empty")
+	}
+	if opts == nil {
+		opts = &CompareOpts{}
+	}
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = 10
+	}
+
+	results := make([][]Product, len(marketIDs))
+	errs := make([]error, len(marketIDs))
+	opts.RateLimit.run(len(marketIDs), func(i int) {
+		res, err := GetProducts(marketIDs[i], query, &ProductOpts{
+			ObjectsPerPage: candidates,
+			ServiceType:    opts.ServiceType,
+		})
+		results[i], errs[i] = res.Products, err
+	})
+
+	pc := PriceComparison{Markets: marketIDs}
+	index := make(map[string]int)
+	for i, products := range results {
+		for _, p := range products {
+			j, ok := index[p.ProductID]
+			if !ok {
+				j = len(pc.Products)
+				index[p.ProductID] = j
+				pc.Products = append(pc.Products, ComparedProduct{
+					ProductID: p.ProductID,
+					Title:     p.Title,
+					Prices:    make([]MarketPrice, len(marketIDs)),
+				})
+			}
+			pc.Products[j].Prices[i] = MarketPrice{
+				MarketID:   marketIDs[i],
+				Available:  true,
+				PriceCents: p.Listing.CurrentRetailPrice,
+				Discounted: p.Listing.Discount != nil,
+				Grammage:   p.Listing.Grammage,
+			}
+		}
+	}
+
+	// fill in markets that didn't list the product or failed
+	for j := range pc.Products {
+		for i := range marketIDs {
+			mp := &pc.Products[j].Prices[i]
+			if mp.Available {
+				continue
+			}
+			mp.MarketID = marketIDs[i]
+			if errs[i] != nil {
+				mp.Error = errs[i].Error()
+			}
+		}
+	}
+
+	if len(pc.Products) == 0 {
+		for _, err := range errs {
+			if err != nil {
+				return PriceComparison{}, fmt.Errorf("error searching markets: %w", err)
+			}
+		}
+		return PriceComparison{}, fmt.Errorf("no products found for %q", query)
+	}
+	return pc, nil
+}
+
+// isNotFound reports whether err means the product is not listed
+func isNotFound(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	return errors.Is(err, ErrProductNotFound)
+}
+
+// allFailed returns an error if no market could be queried
+func allFailed(prices []MarketPrice) error {
+	for _, p := range prices {
+		if p.Error == "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("all markets failed: %s", prices[0].Error)
+}
@@ -0,0 +1,46 @@
+package rewerse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPriceComparison(t *testing.T) {
+	pc := PriceComparison{
+		Markets: []string{"831002", "840174", "1763153"},
+		Products: []ComparedProduct{{
+			ProductID: "123",
+			Title:     "Hafermilch 1l",
+			Prices: []MarketPrice{
+				{MarketID: "831002", Available: true, PriceCents: 199},
+				{MarketID: "840174", Available: true, PriceCents: 149, Discounted: true},
+				{MarketID: "1763153"},
+			},
+		}},
+	}
+
+	best, ok := pc.Products[0].Cheapest()
+	if !ok || best.MarketID != "840174" {
+		t.Errorf("expected 840174 as cheapest, got %+v", best)
+	}
+
+	s := pc.String()
+	for _, want := range []string{"*1.49%", "1.99", "Hafermilch 1l"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("String() missing %q:\n%s", want, s)
+		}
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !isNotFound(&HTTPError{StatusCode: 404}) {
+		t.Error("404 should count as not found")
+	}
+	if !isNotFound(fmt.Errorf("%w: 123", ErrProductNotFound)) {
+		t.Error("ErrProductNotFound should count as not found")
+	}
+	if isNotFound(&HTTPError{StatusCode: 500}) {
+		t.Error("500 is a real error")
+	}
+}
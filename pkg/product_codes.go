@@ -0,0 +1,382 @@
+package rewerse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProductCode maps the identifiers of a product
+type ProductCode struct {
+	// EAN is the barcode (GTIN-8/13), if it has been looked up
+	EAN       string `json:"ean,omitempty"`
+	ProductID string `json:"productId"`
+	// NAN is the article number (German: Artikelnummer)
+	NAN   string `json:"nan,omitempty"`
+	Title string `json:"title,omitempty"`
+	// LookedUpAt is the time of the lookup that found the mapping
+	LookedUpAt time.Time `json:"lookedUpAt"`
+	// Unverified is set if the EAN was only inferred by ResolveEAN, since no endpoint returns
+	// EANs. Use ProductCodeTable.Confirm once the product has been checked.
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+func (pc ProductCode) String() string {
+	var sb strings.Builder
+	sb.WriteString(sep("Produktcode"))
+	sb.WriteByte('\n')
+	ean := pc.EAN
+	if ean != "" && pc.Unverified {
+		ean += " (nicht verifiziert)"
+	}
+	for _, f := range [][2]string{{"Titel", pc.Title}, {"Produkt-ID", pc.ProductID}, {"EAN", ean}, {"Artikelnummer", pc.NAN}} {
+		if f[1] == "" {
+			continue
+		}
+		sb.WriteString(align(f[0]))
+		sb.WriteString(f[1])
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ProductCodeTable is a local EAN <-> product ID <-> NAN mapping table. Lookups that are
+// in the table need no request. It's safe for concurrent use.
+type ProductCodeTable struct {
+	mu sync.Mutex
+	// codes are keyed by product ID
+	codes map[string]ProductCode
+}
+
+// NewProductCodeTable returns an empty table
+func NewProductCodeTable() *ProductCodeTable {
+	return &ProductCodeTable{codes: make(map[string]ProductCode)}
+}
+
+// LoadProductCodeTable reads the table from a JSON file. A missing file is an empty table.
+func LoadProductCodeTable(path string) (*ProductCodeTable, error) {
+	t := NewProductCodeTable()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading product codes: %w", err)
+	}
+	var codes []ProductCode
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return nil, fmt.Errorf("error unmarshalling product codes: %w", err)
+	}
+	for _, c := range codes {
+		t.Add(c)
+	}
+	return t, nil
+}
+
+// Save writes the table to a JSON file
+func (t *ProductCodeTable) Save(path string) error {
+	data, err := json.MarshalIndent(t.Codes(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling product codes: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing product codes: %w", err)
+	}
+	return nil
+}
+
+// Add records a mapping. Fields missing in c are kept from an earlier mapping of the
+// same product ID.
+func (t *ProductCodeTable) Add(c ProductCode) {
+	if c.ProductID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.codes == nil {
+		t.codes = make(map[string]ProductCode)
+	}
+	if prev, ok := t.codes[c.ProductID]; ok {
+		// an inferred EAN doesn't replace a confirmed one
+		if c.EAN == "" || (c.Unverified && prev.EAN != "" && !prev.Unverified) {
+			c.EAN, c.Unverified = prev.EAN, prev.Unverified
+		}
+		if c.NAN == "" {
+			c.NAN = prev.NAN
+		}
+		if c.Title == "" {
+			c.Title = prev.Title
+		}
+		if c.LookedUpAt.IsZero() {
+			c.LookedUpAt = prev.LookedUpAt
+		}
+	}
+	t.codes[c.ProductID] = c
+}
+
+// AddSuggestions records the product ID and NAN of search suggestions
+func (t *ProductCodeTable) AddSuggestions(suggestions ProductSuggestions) {
+	for _, s := range suggestions {
+		t.Add(ProductCode{ProductID: s.RawValues.ProductID, NAN: s.RawValues.Nan, Title: s.Title})
+	}
+}
+
+// AddBasket records the product ID and NAN of the basket's products
+func (t *ProductCodeTable) AddBasket(b Basket) {
+	for _, li := range b.LineItems {
+		t.Add(ProductCode{ProductID: li.Product.ProductID, NAN: li.Product.NAN, Title: li.Product.Title})
+	}
+}
+
+// Codes returns all mappings, sorted by product ID
+func (t *ProductCodeTable) Codes() []ProductCode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	codes := make([]ProductCode, 0, len(t.codes))
+	for _, c := range t.codes {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].ProductID < codes[j].ProductID })
+	return codes
+}
+
+func (t *ProductCodeTable) find(match func(ProductCode) bool) (ProductCode, bool) {
+	if t == nil {
+		return ProductCode{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, c := range t.codes {
+		if match(c) {
+			return c, true
+		}
+	}
+	return ProductCode{}, false
+}
+
+// ByEAN returns the mapping of a barcode, preferring a confirmed one. The mapping may be
+// Unverified; callers should point that out. Leading zeros are ignored.
+func (t *ProductCodeTable) ByEAN(ean string) (ProductCode, bool) {
+	if t == nil {
+		return ProductCode{}, false
+	}
+	ean = strings.TrimLeft(ean, "0")
+	var inferred *ProductCode
+	// sorted by product ID, so the result is stable if several products have the same inferred EAN
+	for _, c := range t.Codes() {
+		if c.EAN == "" || strings.TrimLeft(c.EAN, "0") != ean {
+			continue
+		}
+		if !c.Unverified {
+			return c, true
+		}
+		if inferred == nil {
+			c := c
+			inferred = &c
+		}
+	}
+	if inferred == nil {
+		return ProductCode{}, false
+	}
+	return *inferred, true
+}
+
+// Confirm marks the EAN of a product as verified, e.g. after checking the package. The
+// EAN is removed from other products it was inferred for. It reports whether the product
+// has an EAN in the table.
+func (t *ProductCodeTable) Confirm(productID string) (ProductCode, bool) {
+	if t == nil {
+		return ProductCode{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.codes[productID]
+	if !ok || c.EAN == "" {
+		return ProductCode{}, false
+	}
+	ean := strings.TrimLeft(c.EAN, "0")
+	for id, other := range t.codes {
+		if id != productID && other.Unverified && strings.TrimLeft(other.EAN, "0") == ean {
+			other.EAN, other.Unverified = "", false
+			t.codes[id] = other
+		}
+	}
+	c.Unverified = false
+	t.codes[productID] = c
+	return c, true
+}
+
+// ByNAN returns the mapping of an article number
+func (t *ProductCodeTable) ByNAN(nan string) (ProductCode, bool) {
+	return t.find(func(c ProductCode) bool { return c.NAN == nan })
+}
+
+// ByProductID returns the mapping of a product ID
+func (t *ProductCodeTable) ByProductID(productID string) (ProductCode, bool) {
+	return t.find(func(c ProductCode) bool { return c.ProductID == productID })
+}
+
+// codeLookup resolves codes with the suggestion and search endpoints
+type codeLookup struct {
+	suggest func(query string) (ProductSuggestions, error)
+	search  func(marketID, query string) (ProductResults, error)
+	now     func() time.Time
+}
+
+var defaultCodeLookup = codeLookup{
+	suggest: func(query string) (ProductSuggestions, error) { return GetProductSuggestions(query, nil) },
+	search:  func(marketID, query string) (ProductResults, error) { return GetProducts(marketID, query, nil) },
+	now:     time.Now,
+}
+
+// ResolveEAN finds the product ID of a barcode. Mappings in table are returned directly,
+// unverified ones included. Otherwise the product must be among the search results of the
+// market as well as the suggestions for the barcode; without marketID, the suggestions
+// must name a single product. Since the API returns no EANs, such a match is only likely
+// and marked Unverified until it's confirmed with ProductCodeTable.Confirm. The mapping
+// is added to table, which may be nil.
+func ResolveEAN(ean, marketID string, table *ProductCodeTable) (ProductCode, error) {
+	return defaultCodeLookup.resolveEAN(ean, marketID, table)
+}
+
+// ResolveNAN finds the product ID of an article number. Suggestions carry the NAN and are
+// matched exactly. Otherwise the search results of the market are checked for a product
+// ID equal to the NAN; both are the same for the products seen so far.
+func ResolveNAN(nan, marketID string, table *ProductCodeTable) (ProductCode, error) {
+	return defaultCodeLookup.resolveNAN(nan, marketID, table)
+}
+
+// GetProductByEAN returns the product with the barcode, e.g. a scanned 4001686301265.
+// Unless the table has a confirmed mapping, the match is only likely, see ResolveEAN.
+func GetProductByEAN(marketID, ean string, table *ProductCodeTable) (ProductDetail, error) {
+	if marketID == "" {
+		return ProductDetail{}, fmt.Errorf("marketID: cannot be empty")
+	}
+	code, err := ResolveEAN(ean, marketID, table)
+	if err != nil {
+		return ProductDetail{}, err
+	}
+	return GetProductByID(marketID, code.ProductID)
+}
+
+// GetProductByNAN returns the product with the article number
+func GetProductByNAN(marketID, nan string, table *ProductCodeTable) (ProductDetail, error) {
+	if marketID == "" {
+		return ProductDetail{}, fmt.Errorf("marketID: cannot be empty")
+	}
+	code, err := ResolveNAN(nan, marketID, table)
+	if err != nil {
+		return ProductDetail{}, err
+	}
+	return GetProductByID(marketID, code.ProductID)
+}
+
+func (cl codeLookup) resolveEAN(ean, marketID string, table *ProductCodeTable) (ProductCode, error) {
+	ean = strings.TrimSpace(ean)
+	if !ValidGTIN(ean) {
+		return ProductCode{}, fmt.Errorf("invalid EAN: %q", ean)
+	}
+	if c, ok := table.ByEAN(ean); ok {
+		return c, nil
+	}
+
+	suggestions, err := cl.suggest(ean)
+	if err != nil {
+		return ProductCode{}, err
+	}
+	var ids []string
+	byID := make(map[string]ProductSuggestion)
+	for _, s := range suggestions {
+		if _, ok := byID[s.RawValues.ProductID]; !ok && s.RawValues.ProductID != "" {
+			byID[s.RawValues.ProductID] = s
+			ids = append(ids, s.RawValues.ProductID)
+		}
+	}
+
+	var match *ProductCode
+	if marketID == "" {
+		if len(ids) > 1 {
+			return ProductCode{}, fmt.Errorf("%w: %d products suggested for EAN %s, a market is needed to match one", ErrProductNotFound, len(ids), ean)
+		}
+		if len(ids) == 1 {
+			s := byID[ids[0]]
+			match = &ProductCode{ProductID: ids[0], NAN: s.RawValues.Nan, Title: s.Title}
+		}
+	} else {
+		results, err := cl.search(marketID, ean)
+		if err != nil {
+			return ProductCode{}, err
+		}
+		for _, p := range results.Products {
+			if s, ok := byID[p.ProductID]; ok {
+				match = &ProductCode{ProductID: p.ProductID, NAN: s.RawValues.Nan, Title: p.Title}
+				break
+			}
+		}
+	}
+	if match == nil {
+		return ProductCode{}, fmt.Errorf("%w: no match for EAN %s", ErrProductNotFound, ean)
+	}
+
+	match.EAN = ean
+	match.Unverified = true
+	match.LookedUpAt = cl.now().UTC()
+	if table != nil {
+		table.Add(*match)
+		if merged, ok := table.ByProductID(match.ProductID); ok {
+			return merged, nil
+		}
+	}
+	return *match, nil
+}
+
+func (cl codeLookup) resolveNAN(nan, marketID string, table *ProductCodeTable) (ProductCode, error) {
+	nan = strings.TrimSpace(nan)
+	if nan == "" {
+		return ProductCode{}, fmt.Errorf("nan: cannot be empty")
+	}
+	if c, ok := table.ByNAN(nan); ok {
+		return c, nil
+	}
+
+	suggestions, err := cl.suggest(nan)
+	if err != nil {
+		return ProductCode{}, err
+	}
+	var match *ProductCode
+	for _, s := range suggestions {
+		if s.RawValues.Nan == nan && s.RawValues.ProductID != "" {
+			match = &ProductCode{ProductID: s.RawValues.ProductID, NAN: nan, Title: s.Title}
+			break
+		}
+	}
+	if match == nil && marketID != "" {
+		results, err := cl.search(marketID, nan)
+		if err != nil {
+			return ProductCode{}, err
+		}
+		for _, p := range results.Products {
+			if p.ProductID == nan {
+				match = &ProductCode{ProductID: p.ProductID, NAN: nan, Title: p.Title}
+				break
+			}
+		}
+	}
+	if match == nil {
+		return ProductCode{}, fmt.Errorf("%w: no verified match for NAN %s", ErrProductNotFound, nan)
+	}
+
+	match.LookedUpAt = cl.now().UTC()
+	if table != nil {
+		table.Add(*match)
+		if merged, ok := table.ByProductID(match.ProductID); ok {
+			return merged, nil
+		}
+	}
+	return *match, nil
+}
@@ -0,0 +1,142 @@
+package rewerse
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCodeLookup serves the suggestion fixture and the given search results
+func testCodeLookup(t *testing.T, search ...Product) (codeLookup, *int) {
+	t.Helper()
+	var suggestions ProductSuggestions
+	loadJSONFixture(t, "product_suggestions.json", &suggestions)
+	requests := 0
+	return codeLookup{
+		suggest: func(string) (ProductSuggestions, error) {
+			requests++
+			return suggestions, nil
+		},
+		search: func(string, string) (ProductResults, error) {
+			requests++
+			return ProductResults{Products: search}, nil
+		},
+		now: func() time.Time { return time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC) },
+	}, &requests
+}
+
+func TestResolveEAN(t *testing.T) {
+	cl, requests := testCodeLookup(t, Product{ProductID: "1234"}, Product{ProductID: "7828199", Title: "Newgene Selbsttest"})
+	table := NewProductCodeTable()
+
+	code, err := cl.resolveEAN("4001686301265", "831002", table)
+	if err != nil {
+		t.Fatalf("resolveEAN failed: %v", err)
+	}
+	if code.ProductID != "7828199" || code.NAN != "7828199" || code.EAN != "4001686301265" || !code.Unverified || code.LookedUpAt.IsZero() {
+		t.Errorf("unexpected code: %+v", code)
+	}
+	if byNAN, ok := table.ByNAN("7828199"); !ok || byNAN.EAN != "4001686301265" || !byNAN.Unverified {
+		t.Errorf("unexpected NAN mapping: %+v", byNAN)
+	}
+
+	// the inferred mapping answers the next lookup, also without market
+	if again, err := cl.resolveEAN("4001686301265", "", table); err != nil || again.ProductID != "7828199" || !again.Unverified || *requests != 2 {
+		t.Errorf("expected unverified lookup from table, got %+v (%v, %d requests)", again, err, *requests)
+	}
+
+	// a confirmed mapping is preferred, also as GTIN-14
+	table.Add(ProductCode{ProductID: "1234", EAN: "4001686301265", Unverified: true})
+	if confirmed, ok := table.Confirm("7828199"); !ok || confirmed.Unverified {
+		t.Errorf("unexpected confirmed mapping: %+v", confirmed)
+	}
+	if again, err := cl.resolveEAN("04001686301265", "", table); err != nil || again.ProductID != "7828199" || again.Unverified || *requests != 2 {
+		t.Errorf("expected lookup from table, got %+v (%v, %d requests)", again, err, *requests)
+	}
+	if other, _ := table.ByProductID("1234"); other.EAN != "" {
+		t.Errorf("confirming must remove the EAN from other products, got %+v", other)
+	}
+	if _, ok := table.Confirm("999"); ok {
+		t.Error("expected no mapping to confirm")
+	}
+
+	if _, err := cl.resolveEAN("4001686301266", "", nil); err == nil {
+		t.Error("expected error for invalid check digit")
+	}
+}
+
+func TestResolveEANUnverified(t *testing.T) {
+	// the search disagrees with the suggestions
+	cl, _ := testCodeLookup(t, Product{ProductID: "1234"})
+	if _, err := cl.resolveEAN("4001686301265", "831002", nil); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+
+	// without market, the single suggested product is taken
+	table := NewProductCodeTable()
+	cl, requests := testCodeLookup(t)
+	code, err := cl.resolveEAN("4001686301265", "", table)
+	if err != nil || code.ProductID != "7828199" || !code.Unverified || *requests != 1 {
+		t.Errorf("unexpected result: %+v (%v, %d requests)", code, err, *requests)
+	}
+
+	// several suggested products can't be told apart without market
+	suggest := cl.suggest
+	cl.suggest = func(query string) (ProductSuggestions, error) {
+		suggestions, err := suggest(query)
+		other := suggestions[0]
+		other.RawValues.ProductID = "1234"
+		return append(suggestions, other), err
+	}
+	if code, err := cl.resolveEAN("4001686301265", "", nil); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %+v (%v)", code, err)
+	}
+}
+
+func TestResolveNAN(t *testing.T) {
+	cl, _ := testCodeLookup(t, Product{ProductID: "5551234", Title: "Hafermilch"})
+
+	code, err := cl.resolveNAN("7828199", "", nil)
+	if err != nil || code.ProductID != "7828199" {
+		t.Fatalf("unexpected result: %+v (%v)", code, err)
+	}
+	// no suggestion carries the NAN, but the search finds the product ID
+	if code, err := cl.resolveNAN("5551234", "831002", nil); err != nil || code.Title != "Hafermilch" {
+		t.Errorf("unexpected result: %+v (%v)", code, err)
+	}
+	if _, err := cl.resolveNAN("999", "831002", nil); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestProductCodeTable(t *testing.T) {
+	table := NewProductCodeTable()
+	table.Add(ProductCode{ProductID: "1", EAN: "4001686301265", Title: "Gouda"})
+	table.Add(ProductCode{ProductID: "1", NAN: "111"})
+	table.AddBasket(Basket{LineItems: []LineItem{{Product: LineItemProduct{ProductID: "2", NAN: "222", Title: "Milch"}}}})
+
+	if c, ok := table.ByNAN("111"); !ok || c.EAN != "4001686301265" || c.Title != "Gouda" {
+		t.Errorf("mappings were not merged: %+v", c)
+	}
+	// an inferred EAN doesn't replace the confirmed one
+	table.Add(ProductCode{ProductID: "1", EAN: "4001686301272", Unverified: true})
+	if c, ok := table.ByEAN("4001686301265"); !ok || c.Unverified {
+		t.Errorf("confirmed mapping was replaced: %+v", c)
+	}
+
+	path := filepath.Join(t.TempDir(), "codes.json")
+	if err := table.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadProductCodeTable(path)
+	if err != nil || len(loaded.Codes()) != 2 {
+		t.Fatalf("unexpected table: %+v (%v)", loaded.Codes(), err)
+	}
+	if c, ok := loaded.ByProductID("2"); !ok || c.NAN != "222" {
+		t.Errorf("unexpected mapping: %+v", c)
+	}
+	if empty, err := LoadProductCodeTable(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(empty.Codes()) != 0 {
+		t.Errorf("missing file must be an empty table: %v", err)
+	}
+}
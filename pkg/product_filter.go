@@ -0,0 +1,335 @@
+package rewerse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProductPredicate is a client-side product filter. Predicates are composed with
+// AllOf, AnyOf and Not and applied with ProductResults.Filter or ProductIterator.
+// Unlike ProductFilter they work on every attribute, but only on fetched products.
+type ProductPredicate func(p Product) bool
+
+// ProductAttribute is a flag of Product.Attributes
+type ProductAttribute string
+
+const (
+	AttributeVegan       ProductAttribute = "vegan"
+	AttributeVegetarian  ProductAttribute = "vegetarian"
+	AttributeOrganic     ProductAttribute = "organic"
+	AttributeGlutenFree  ProductAttribute = "glutenfree"
+	AttributeDairyFree   ProductAttribute = "dairyfree"
+	AttributeRegional    ProductAttribute = "regional"
+	AttributeNew         ProductAttribute = "new"
+	AttributeLowestPrice ProductAttribute = "lowestprice"
+	AttributeBulkyGood   ProductAttribute = "bulky"
+)
+
+var productAttributes = []ProductAttribute{
+	AttributeVegan, AttributeVegetarian, AttributeOrganic, AttributeGlutenFree, AttributeDairyFree,
+	AttributeRegional, AttributeNew, AttributeLowestPrice, AttributeBulkyGood,
+}
+
+// ParseProductAttributes parses a comma-separated list like "vegan,glutenfree"
+func ParseProductAttributes(list string) ([]ProductAttribute, error) {
+	var attrs []ProductAttribute
+	for _, s := range strings.Split(list, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		attr, ok := findProductAttribute(s)
+		if !ok {
+			names := make([]string, len(productAttributes))
+			for i, a := range productAttributes {
+				names[i] = string(a)
+			}
+			return nil, fmt.Errorf("invalid attribute: %q (must be one of %s)", s, strings.Join(names, ", "))
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+func findProductAttribute(s string) (ProductAttribute, bool) {
+	for _, a := range productAttributes {
+		if string(a) == s {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// Has reports whether the product has the attribute
+func (a ProductAttribute) Has(p Product) bool {
+	switch a {
+	case AttributeVegan:
+		return p.Attributes.IsVegan
+	case AttributeVegetarian:
+		return p.Attributes.IsVegetarian
+	case AttributeOrganic:
+		return p.Attributes.IsOrganic
+	case AttributeGlutenFree:
+		return p.Attributes.IsGlutenFree
+	case AttributeDairyFree:
+		return p.Attributes.IsDairyFree
+	case AttributeRegional:
+		return p.Attributes.IsRegional
+	case AttributeNew:
+		return p.Attributes.IsNew
+	case AttributeLowestPrice:
+		return p.Attributes.IsLowestPrice
+	case AttributeBulkyGood:
+		return p.Attributes.IsBulkyGood
+	}
+	return false
+}
+
+// ServerFilter returns the server-side ProductFilter for the attribute, if the API has one.
+// Using it in ProductOpts.Filters reduces the number of pages to scan.
+func (a ProductAttribute) ServerFilter() (ProductFilter, bool) {
+	switch a {
+	case AttributeVegan:
+		return FilterVegan, true
+	case AttributeVegetarian:
+		return FilterVegetarian, true
+	case AttributeOrganic:
+		return FilterOrganic, true
+	case AttributeRegional:
+		return FilterRegional, true
+	case AttributeNew:
+		return FilterNew, true
+	}
+	return "", false
+}
+
+// AllOf matches products that match every predicate
+func AllOf(preds ...ProductPredicate) ProductPredicate {
+	return func(p Product) bool {
+		for _, pred := range preds {
+			if !pred(p) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf matches products that match at least one predicate
+func AnyOf(preds ...ProductPredicate) ProductPredicate {
+	return func(p Product) bool {
+		for _, pred := range preds {
+			if pred(p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not inverts a predicate
+func Not(pred ProductPredicate) ProductPredicate {
+	return func(p Product) bool {
+		return !pred(p)
+	}
+}
+
+// HasAttributes matches products that have all given attributes
+func HasAttributes(attrs ...ProductAttribute) ProductPredicate {
+	return func(p Product) bool {
+		for _, a := range attrs {
+			if !a.Has(p) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// PriceRange matches products whose current price in cents is within [min, max].
+// 0 leaves a bound open.
+func PriceRange(minCents, maxCents int) ProductPredicate {
+	return func(p Product) bool {
+		price := p.Listing.CurrentRetailPrice
+		return (minCents <= 0 || price >= minCents) && (maxCents <= 0 || price <= maxCents)
+	}
+}
+
+// GrammageRange matches products whose pack size is within [min, max] in the given unit
+// ("g", "kg", "ml", "l", "Stück"). 0 leaves a bound open. Products with a pack size in
+// another dimension or without a parseable grammage don't match.
+func GrammageRange(minAmount, maxAmount float64, unit string) (ProductPredicate, error) {
+	u, ok := baseUnits[strings.ToLower(unit)]
+	if !ok {
+		return nil, fmt.Errorf("invalid grammage unit: %q", unit)
+	}
+	return func(p Product) bool {
+		amount, dim, ok := parsePackSize(p.Listing.Grammage)
+		if !ok || dim != u.dim {
+			return false
+		}
+		amount /= u.factor
+		return (minAmount <= 0 || amount >= minAmount) && (maxAmount <= 0 || amount <= maxAmount)
+	}, nil
+}
+
+// Brand matches products whose title starts with the brand, case-insensitively.
+// Search results carry no brand field; REWE titles start with the brand name
+// ("MAX Mixnuts ...", "REWE Bio Hafermilch ...").
+func Brand(brand string) ProductPredicate {
+	prefix := strings.ToLower(strings.TrimSpace(brand))
+	return func(p Product) bool {
+		title := strings.ToLower(p.Title)
+		if !strings.HasPrefix(title, prefix) {
+			return false
+		}
+		// whole words only: "REWE" must not match "REWEnta"
+		rest := title[len(prefix):]
+		return rest == "" || rest[0] == ' '
+	}
+}
+
+// NotAgeRestricted excludes products that require an age check
+func NotAgeRestricted() ProductPredicate {
+	return func(p Product) bool {
+		return p.Attributes.IsAgeRestricted == nil || !*p.Attributes.IsAgeRestricted
+	}
+}
+
+// NotBiocide excludes biocidal products
+func NotBiocide() ProductPredicate {
+	return func(p Product) bool {
+		return !p.Attributes.IsBiocide
+	}
+}
+
+// Filter returns a copy of the results with only the matching products of this page.
+// Pagination still describes the unfiltered search.
+func (pr ProductResults) Filter(pred ProductPredicate) ProductResults {
+	filtered := pr
+	filtered.Products = FilterProducts(pr.Products, pred)
+	return filtered
+}
+
+// FilterProducts returns the matching products
+func FilterProducts(products []Product, pred ProductPredicate) []Product {
+	var matched []Product
+	for _, p := range products {
+		if pred == nil || pred(p) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+// ProductIterator pages through product search results:
+//
+//	it := rewerse.IterateProducts(marketID, "Milch", nil).Where(rewerse.HasAttributes(rewerse.AttributeVegan))
+//	for it.Next() {
+//		fmt.Println(it.Product().Title)
+//	}
+//	if err := it.Err(); err != nil { ... }
+type ProductIterator struct {
+	fetch func(page int) (ProductResults, error)
+	pred  ProductPredicate
+	// MaxPages limits the pages fetched (0 = all pages)
+	MaxPages int
+
+	page    int
+	pages   int
+	buf     []Product
+	current Product
+	err     error
+}
+
+// IterateProducts iterates over all pages of GetProducts
+func IterateProducts(marketID, search string, opts *ProductOpts) *ProductIterator {
+	o := copyProductOpts(opts)
+	return &ProductIterator{fetch: func(page int) (ProductResults, error) {
+		o.Page = page
+		return GetProducts(marketID, search, &o)
+	}}
+}
+
+// IterateCategoryProducts iterates over all pages of GetCategoryProducts
+func IterateCategoryProducts(marketID, categorySlug string, opts *ProductOpts) *ProductIterator {
+	o := copyProductOpts(opts)
+	return &ProductIterator{fetch: func(page int) (ProductResults, error) {
+		o.Page = page
+		return GetCategoryProducts(marketID, categorySlug, &o)
+	}}
+}
+
+func copyProductOpts(opts *ProductOpts) ProductOpts {
+	if opts == nil {
+		return defaultOpts
+	}
+	o := *opts
+	o.Filters = append([]ProductFilter(nil), opts.Filters...)
+	return o
+}
+
+// Where restricts the iterator to matching products. Several calls are combined with AllOf.
+func (it *ProductIterator) Where(pred ProductPredicate) *ProductIterator {
+	if it.pred == nil {
+		it.pred = pred
+	} else {
+		it.pred = AllOf(it.pred, pred)
+	}
+	return it
+}
+
+// Next advances to the next matching product, fetching pages as needed
+func (it *ProductIterator) Next() bool {
+	for {
+		if it.err != nil {
+			return false
+		}
+		for len(it.buf) > 0 {
+			p := it.buf[0]
+			it.buf = it.buf[1:]
+			if it.pred == nil || it.pred(p) {
+				it.current = p
+				return true
+			}
+		}
+
+		if it.page > 0 && it.page >= it.pages {
+			return false
+		}
+		if it.MaxPages > 0 && it.page >= it.MaxPages {
+			return false
+		}
+
+		it.page++
+		res, err := it.fetch(it.page)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.pages = res.Pagination.PageCount
+		it.buf = res.Products
+		if len(res.Products) == 0 {
+			return false
+		}
+	}
+}
+
+// Product returns the current product
+func (it *ProductIterator) Product() Product {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ProductIterator) Err() error {
+	return it.err
+}
+
+// Collect returns up to limit matching products (0 = all)
+func (it *ProductIterator) Collect(limit int) ([]Product, error) {
+	var products []Product
+	for (limit <= 0 || len(products) < limit) && it.Next() {
+		products = append(products, it.Product())
+	}
+	return products, it.Err()
+}
@@ -0,0 +1,123 @@
+package rewerse
+
+import (
+	"errors"
+	"testing"
+)
+
+func productTitles(products []Product) []string {
+	titles := make([]string, len(products))
+	for i, p := range products {
+		titles[i] = p.Title
+	}
+	return titles
+}
+
+func TestProductPredicates(t *testing.T) {
+	restricted := true
+	beer := testProduct("Krombacher Pils 0,5l", "0,5l (1 l = 1,98 €)", 99, asVegan)
+	beer.Attributes.IsAgeRestricted = &restricted
+	spray := testProduct("REWE Beste Wahl Insektenspray 400ml", "400ml (1 l = 9,98 €)", 399)
+	spray.Attributes.IsBiocide = true
+
+	products := []Product{
+		testProduct("REWE Bio Hafermilch 1l", "1l", 129, asVegan, asGlutenFree),
+		testProduct("Alpro Sojadrink 1l", "1l", 229, asVegan),
+		testProduct("REWE Beste Wahl Gouda 400g", "400g (1 kg = 8,98 €)", 359, asGlutenFree),
+		testProduct("REWEnta Kaugummi", "30g", 99, asVegan, asGlutenFree),
+		beer,
+		spray,
+	}
+
+	grammage, err := GrammageRange(0.5, 1, "l")
+	if err != nil {
+		t.Fatalf("GrammageRange failed: %v", err)
+	}
+	if _, err := GrammageRange(1, 2, "Pfund"); err == nil {
+		t.Error("expected error for unknown unit")
+	}
+
+	tests := []struct {
+		name string
+		pred ProductPredicate
+		want []string
+	}{
+		{"vegan and gluten-free", HasAttributes(AttributeVegan, AttributeGlutenFree),
+			[]string{"REWE Bio Hafermilch 1l", "REWEnta Kaugummi"}},
+		{"price range", PriceRange(100, 300),
+			[]string{"REWE Bio Hafermilch 1l", "Alpro Sojadrink 1l"}},
+		{"grammage range", grammage,
+			[]string{"REWE Bio Hafermilch 1l", "Alpro Sojadrink 1l", "Krombacher Pils 0,5l"}},
+		{"brand is a whole word", Brand("rewe"),
+			[]string{"REWE Bio Hafermilch 1l", "REWE Beste Wahl Gouda 400g", "REWE Beste Wahl Insektenspray 400ml"}},
+		{"exclude restricted", AllOf(NotAgeRestricted(), NotBiocide(), grammage),
+			[]string{"REWE Bio Hafermilch 1l", "Alpro Sojadrink 1l"}},
+		{"any of / not", AllOf(AnyOf(Brand("Alpro"), Brand("Krombacher")), Not(NotAgeRestricted())),
+			[]string{"Krombacher Pils 0,5l"}},
+	}
+	for _, tt := range tests {
+		got := productTitles(FilterProducts(products, tt.pred))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestParseProductAttributes(t *testing.T) {
+	attrs, err := ParseProductAttributes("Vegan, glutenfree,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(attrs) != 2 || attrs[0] != AttributeVegan || attrs[1] != AttributeGlutenFree {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if _, err := ParseProductAttributes("vegan,halal"); err == nil {
+		t.Error("expected error for unknown attribute")
+	}
+}
+
+func TestProductIterator(t *testing.T) {
+	pages := [][]Product{
+		{testProduct("a", "", 0, asVegan), testProduct("b", "", 0)},
+		{testProduct("c", "", 0, asVegan)},
+		{testProduct("d", "", 0, asVegan)},
+	}
+	var fetched int
+	fetch := func(page int) (ProductResults, error) {
+		fetched++
+		var res ProductResults
+		res.Products = pages[page-1]
+		res.Pagination.PageCount = len(pages)
+		return res, nil
+	}
+
+	it := (&ProductIterator{fetch: fetch}).Where(HasAttributes(AttributeVegan))
+	got, err := it.Collect(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if titles := productTitles(got); len(titles) != 3 || titles[0] != "a" || titles[1] != "c" || titles[2] != "d" {
+		t.Errorf("unexpected products: %v", titles)
+	}
+
+	// limit stops before fetching the last page
+	fetched = 0
+	it = (&ProductIterator{fetch: fetch}).Where(HasAttributes(AttributeVegan))
+	if got, _ := it.Collect(2); len(got) != 2 || fetched != 2 {
+		t.Errorf("limit: expected 2 products from 2 pages, got %d from %d", len(got), fetched)
+	}
+
+	// errors stop the iteration
+	boom := errors.New("boom")
+	it = &ProductIterator{fetch: func(int) (ProductResults, error) { return ProductResults{}, boom }}
+	if _, err := it.Collect(0); !errors.Is(err, boom) {
+		t.Errorf("expected boom, got %v", err)
+	}
+}
@@ -1,12 +1,16 @@
 package rewerse
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 )
 
+// ErrProductNotFound is returned by GetProductByID if the market does not list the product
+var ErrProductNotFound = errors.New("product not found")
+
 // ProductFilter is a filter type for product search.
 // Use with ProductOpts.Filters to narrow down search results.
 type ProductFilter string
@@ -82,7 +86,7 @@ func GetProductByID(marketID, productID string) (ProductDetail, error) {
 	}
 
 	if len(res.Data.Product) == 0 {
-		return ProductDetail{}, fmt.Errorf("product %s not found", productID)
+		return ProductDetail{}, fmt.Errorf("%w: %s", ErrProductNotFound, productID)
 	}
 
 	return res.Data.Product[0], nil
@@ -0,0 +1,62 @@
+package rewerse
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit bounds the requests of helpers that call the API for many markets or zip codes
+type RateLimit struct {
+	// Workers is the number of concurrent requests (default 4)
+	Workers int
+	// Interval is the minimum time between the start of two requests (0 = no throttling)
+	Interval time.Duration
+}
+
+// DefaultRateLimit is used when no RateLimit is given
+var DefaultRateLimit = RateLimit{Workers: 4, Interval: 250 * time.Millisecond}
+
+// run calls fn for 0..n-1 on the workers, starting at most one call per Interval.
+// It returns when all calls are done. A nil RateLimit uses DefaultRateLimit.
+func (rl *RateLimit) run(n int, fn func(i int)) {
+	limit := DefaultRateLimit
+	if rl != nil {
+		limit = *rl
+	}
+	workers := limit.Workers
+	if workers <= 0 {
+		workers = DefaultRateLimit.Workers
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var tick <-chan time.Time
+	if limit.Interval > 0 {
+		ticker := time.NewTicker(limit.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		// the first request starts immediately
+		if tick != nil && i > 0 {
+			<-tick
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+package rewerse
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRun(t *testing.T) {
+	var mu sync.Mutex
+	var running, maxRunning int
+	done := make([]bool, 10)
+
+	rl := &RateLimit{Workers: 3, Interval: time.Millisecond}
+	rl.run(len(done), func(i int) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		done[i] = true
+		mu.Unlock()
+	})
+
+	for i, d := range done {
+		if !d {
+			t.Errorf("job %d not run", i)
+		}
+	}
+	if maxRunning > 3 {
+		t.Errorf("expected at most 3 concurrent jobs, got %d", maxRunning)
+	}
+
+	// nil uses the defaults, zero jobs must not block
+	var nilLimit *RateLimit
+	nilLimit.run(0, func(int) { t.Error("unexpected call") })
+}
@@ -0,0 +1,456 @@
+package rewerse
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecallDetails are the structured fields of a recall's detail page. Pages are written by
+// hand, so every field may be incomplete.
+type RecallDetails struct {
+	Recall
+	// Title is the headline of the page
+	Title        string `json:"title,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// EANs are the affected barcodes (GTIN-8/13) with a valid check digit
+	EANs       []string `json:"eans,omitempty"`
+	LotNumbers []string `json:"lotNumbers,omitempty"`
+	// BestBefore are the affected best-before (MHD) or use-by dates as YYYY-MM-DD
+	BestBefore []string `json:"bestBefore,omitempty"`
+	// Regions are the states the products were sold in; empty if unknown or Nationwide
+	Regions    []FederalState `json:"regions,omitempty"`
+	Nationwide bool           `json:"nationwide,omitempty"`
+}
+
+func (d RecallDetails) String() string {
+	title := d.SubjectProduct
+	if title == "" {
+		title = d.Title
+	}
+	var sb strings.Builder
+	sb.WriteString(sep(title))
+	sb.WriteByte('\n')
+	line := func(label, value string) {
+		if value == "" {
+			return
+		}
+		sb.WriteString(align(label))
+		sb.WriteString(value)
+		sb.WriteByte('\n')
+	}
+	line("Grund", d.SubjectReason)
+	line("Hersteller", d.Manufacturer)
+	line("EAN", strings.Join(d.EANs, ", "))
+	line("Los", strings.Join(d.LotNumbers, ", "))
+	dates := make([]string, 0, len(d.BestBefore))
+	for _, s := range d.BestBefore {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			s = t.Format("02.01.2006")
+		}
+		dates = append(dates, s)
+	}
+	line("MHD", strings.Join(dates, ", "))
+	if d.Nationwide {
+		line("Regionen", "bundesweit")
+	} else {
+		states := make([]string, len(d.Regions))
+		for i, st := range d.Regions {
+			states[i] = string(st)
+		}
+		line("Regionen", strings.Join(states, ", "))
+	}
+	line("URL", d.URL)
+	return sb.String()
+}
+
+// RecallDetailsList is the result of EnrichRecalls
+type RecallDetailsList []RecallDetails
+
+func (l RecallDetailsList) String() string {
+	var sb strings.Builder
+	for i, d := range l {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(d.String())
+	}
+	return sb.String()
+}
+
+// AffectsGTIN reports whether the barcode is listed on the page. Leading zeros are ignored,
+// so a GTIN-14 "04001686301265" matches the EAN-13 "4001686301265".
+func (d RecallDetails) AffectsGTIN(gtin string) bool {
+	gtin = strings.TrimLeft(strings.TrimSpace(gtin), "0")
+	if gtin == "" {
+		return false
+	}
+	for _, ean := range d.EANs {
+		if strings.TrimLeft(ean, "0") == gtin {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidGTIN reports whether s is a GTIN-8, -12, -13 or -14 with a correct check digit
+func ValidGTIN(s string) bool {
+	switch len(s) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		if i == len(s)-1 {
+			continue
+		}
+		n := int(c - '0')
+		// weights alternate 3, 1, ... starting left of the check digit
+		if (len(s)-1-i)%2 == 1 {
+			n *= 3
+		}
+		sum += n
+	}
+	return (10-sum%10)%10 == int(s[len(s)-1]-'0')
+}
+
+// recallDetailsClient fetches the detail pages, which are not part of the API
+var recallDetailsClient = &http.Client{Timeout: 15 * time.Second}
+
+// maxRecallPageSize bounds the size of a detail page read by FetchRecallDetails
+const maxRecallPageSize = 5 << 20
+
+// FetchRecallDetails loads and parses the detail page behind the recall's URL
+func FetchRecallDetails(r Recall) (d RecallDetails, err error) {
+	if r.URL == "" {
+		return d, fmt.Errorf("recall has no URL")
+	}
+	resp, err := recallDetailsClient.Get(r.URL)
+	if err != nil {
+		return d, fmt.Errorf("error loading recall page: %w", err)
+	}
+	defer CloseWithWrap(resp.Body, &err)
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return d, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	d, err = ParseRecallDetails(io.LimitReader(resp.Body, maxRecallPageSize))
+	d.Recall = r
+	return d, err
+}
+
+// EnrichRecalls fetches the detail pages of the recalls concurrently. Recalls whose page
+// can't be loaded are skipped; it only fails if no page could be loaded.
+func EnrichRecalls(recalls Recalls, rl *RateLimit) (RecallDetailsList, error) {
+	return enrichRecalls(recalls, rl, FetchRecallDetails)
+}
+
+func enrichRecalls(recalls Recalls, rl *RateLimit, fetch func(Recall) (RecallDetails, error)) (RecallDetailsList, error) {
+	if len(recalls) == 0 {
+		return nil, nil
+	}
+	results := make([]RecallDetails, len(recalls))
+	errs := make([]error, len(recalls))
+	rl.run(len(recalls), func(i int) {
+		results[i], errs[i] = fetch(recalls[i])
+	})
+
+	var details RecallDetailsList
+	for i, d := range results {
+		if errs[i] == nil {
+			details = append(details, d)
+		}
+	}
+	if len(details) == 0 {
+		return nil, fmt.Errorf("error loading recall details: %w", errs[0])
+	}
+	return details, nil
+}
+
+// RecallGTINMatchScore is the score of barcode matches. It's below 1 because the EAN
+// extraction has only been tested against hand-written pages, not saved ones.
+const RecallGTINMatchScore = 0.9
+
+// MatchRecallsByGTIN matches candidates with a GTIN against the EANs of the detail pages.
+// Every match has a score of RecallGTINMatchScore.
+func MatchRecallsByGTIN(details []RecallDetails, candidates []RecallCandidate) []RecallMatch {
+	var matches []RecallMatch
+	for _, d := range details {
+		for _, c := range candidates {
+			if c.GTIN != "" && d.AffectsGTIN(c.GTIN) {
+				matches = append(matches, RecallMatch{Recall: d.Recall, Candidate: c, Score: RecallGTINMatchScore, ByGTIN: true})
+			}
+		}
+	}
+	return matches
+}
+
+var (
+	dropElementsRe = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|noscript|svg|nav|header|footer)\b.*?</(script|style|noscript|svg|nav|header|footer)>`)
+	h1Re           = regexp.MustCompile(`(?is)<h1\b[^>]*>(.*?)</h1>`)
+	headingRe      = regexp.MustCompile(`(?is)<h[2-6]\b[^>]*>(.*?)</h[2-6]>`)
+	cellEndRe      = regexp.MustCompile(`(?i)</t[dh]>`)
+	blockTagRe     = regexp.MustCompile(`(?i)<(br|p|div|li|ul|ol|tr|table|section|article|dt|dd|dl)\b[^>]*>|</(p|div|li|ul|ol|tr|table|section|article|dt|dd|dl)>`)
+	tagRe          = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRe        = regexp.MustCompile(`[ \t\p{Zs}]+`)
+
+	// labelRe matches a line that only announces the following lines: "Betroffene Chargen:"
+	labelRe = regexp.MustCompile(`^[^:\d]{2,60}:$`)
+
+	eanLabelRe      = regexp.MustCompile(`(?i)\b(ean|gtin|barcode|strichcode)`)
+	digitRunRe      = regexp.MustCompile(`\d+(?: \d+)*`)
+	ean13Re         = regexp.MustCompile(`\b\d{13}\b`)
+	lotRe           = regexp.MustCompile(`(?i)(?:\blos(?:-?nummer|-?nr\.?|kennzeichnung)?|\bchargen?(?:-?nummer|-?nr\.?|bezeichnung)?n?|\blot(?:-?nr\.?)?)\s*[:.]?\s+(.+)`)
+	lotLabelRe      = regexp.MustCompile(`(?i)\b(los-?nummer|los-?nr|losnummern|lose|los|chargen?|chargennummern?|lot)\b`)
+	lotStopRe       = regexp.MustCompile(`(?i)\b(mhd|mindesthaltbar|verbrauch|ean|gtin|hersteller)\b`)
+	lotSplitRe      = regexp.MustCompile(`\s*(?:[,;]|\s/\s|\bund\b|\bsowie\b|\boder\b|\t)\s*`)
+	parenRe         = regexp.MustCompile(`\([^)]*\)`)
+	bestBeforeRe    = regexp.MustCompile(`(?i)\b(mhd|mindesthaltbar|verbrauchsdatum|verbrauchen bis|haltbar bis)`)
+	dateRe          = regexp.MustCompile(`\b(\d{1,2})\.\s?(\d{1,2})\.\s?(\d{4}|\d{2})\b`)
+	manufacturerRe  = regexp.MustCompile(`(?i)^(?:hersteller|inverkehrbringer|herstellerangaben|hergestellt von)\s*[:\t]\s*(.+)$`)
+	manufacturerCtx = regexp.MustCompile(`(?i)^(hersteller|inverkehrbringer|herstellerangaben|hergestellt von)\b`)
+	regionLabelRe   = regexp.MustCompile(`(?i)(bundesl[äa]nd|region|verkauft|vertrieb|erh[äa]ltlich|filialen|m[äa]rkte)`)
+	nationwideRe    = regexp.MustCompile(`(?i)\b(bundesweit|deutschlandweit|in ganz deutschland|allen bundesländern)\b`)
+	nrwRe           = regexp.MustCompile(`(?i)\bnrw\b`)
+)
+
+// stateNames are the German state names, longest first so "Sachsen-Anhalt" isn't read as "Sachsen"
+var stateNames = []struct {
+	name  string
+	state FederalState
+}{
+	{"mecklenburg-vorpommern", StateMecklenburgVorpommern},
+	{"nordrhein-westfalen", StateNordrheinWestfalen},
+	{"baden-württemberg", StateBadenWuerttemberg},
+	{"schleswig-holstein", StateSchleswigHolstein},
+	{"rheinland-pfalz", StateRheinlandPfalz},
+	{"sachsen-anhalt", StateSachsenAnhalt},
+	{"niedersachsen", StateNiedersachsen},
+	{"brandenburg", StateBrandenburg},
+	{"thüringen", StateThueringen},
+	{"saarland", StateSaarland},
+	{"hamburg", StateHamburg},
+	{"sachsen", StateSachsen},
+	{"bremen", StateBremen},
+	{"hessen", StateHessen},
+	{"berlin", StateBerlin},
+	{"bayern", StateBayern},
+}
+
+// maxEANLabelDistance is the number of lines after an EAN/GTIN label that may contain
+// unlabelled barcodes, e.g. the rows below a table header
+const maxEANLabelDistance = 3
+
+// ParseRecallDetails extracts the structured fields from the HTML of a recall page.
+// Recall is left empty.
+func ParseRecallDetails(r io.Reader) (RecallDetails, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return RecallDetails{}, fmt.Errorf("error reading recall page: %w", err)
+	}
+	page := string(data)
+	var d RecallDetails
+	if m := h1Re.FindStringSubmatch(page); m != nil {
+		d.Title = cleanText(m[1])
+	}
+
+	seen := make(map[string]bool)
+	add := func(list *[]string, kind, value string) {
+		if value == "" || seen[kind+value] {
+			return
+		}
+		seen[kind+value] = true
+		*list = append(*list, value)
+	}
+	states := make(map[FederalState]bool)
+
+	// context is the label announcing the current line, e.g. a heading or "EAN:"
+	var context string
+	// eanLabelLine is the index of the last line mentioning EAN/GTIN; unlabelled 13-digit
+	// numbers are only taken close to it, since phone and order numbers look the same
+	eanLabelLine := -maxEANLabelDistance - 1
+	for i, line := range recallPageLines(page) {
+		if eanLabelRe.MatchString(line) {
+			eanLabelLine = i
+		}
+		if strings.HasPrefix(line, "\x00") {
+			context = line[1:]
+			continue
+		}
+		if labelRe.MatchString(line) {
+			context = line
+			continue
+		}
+		labelled := context + " " + line
+
+		if i-eanLabelLine <= maxEANLabelDistance {
+			for _, n := range ean13Re.FindAllString(line, -1) {
+				if ValidGTIN(n) {
+					add(&d.EANs, "ean", n)
+				}
+			}
+		}
+		if eanLabelRe.MatchString(labelled) {
+			for _, run := range digitRunRe.FindAllString(line, -1) {
+				for _, n := range gtinsInRun(run) {
+					add(&d.EANs, "ean", n)
+				}
+			}
+		}
+
+		if bestBeforeRe.MatchString(labelled) {
+			for _, m := range dateRe.FindAllStringSubmatch(line, -1) {
+				add(&d.BestBefore, "mhd", parseRecallDate(m[1], m[2], m[3]))
+			}
+		}
+
+		if m := lotRe.FindStringSubmatch(line); m != nil {
+			addLots(m[1], func(lot string) { add(&d.LotNumbers, "lot", lot) })
+		} else if lotLabelRe.MatchString(context) {
+			addLots(line, func(lot string) { add(&d.LotNumbers, "lot", lot) })
+		}
+
+		if d.Manufacturer == "" {
+			if m := manufacturerRe.FindStringSubmatch(line); m != nil {
+				d.Manufacturer = strings.TrimSpace(m[1])
+			} else if manufacturerCtx.MatchString(context) {
+				d.Manufacturer = line
+			}
+		}
+
+		if nationwideRe.MatchString(line) {
+			d.Nationwide = true
+		}
+		if regionLabelRe.MatchString(labelled) {
+			for _, st := range findStates(line) {
+				states[st] = true
+			}
+		}
+	}
+
+	for _, st := range FederalStates {
+		if states[st] {
+			d.Regions = append(d.Regions, st)
+		}
+	}
+	if len(d.Regions) == len(FederalStates) {
+		d.Nationwide = true
+	}
+	if d.Nationwide {
+		d.Regions = nil
+	}
+	sort.Strings(d.BestBefore)
+	return d, nil
+}
+
+// recallPageLines converts the page to trimmed text lines. Headings are returned with a
+// leading NUL, since they announce the lines after them.
+func recallPageLines(page string) []string {
+	page = dropElementsRe.ReplaceAllString(page, "")
+	page = h1Re.ReplaceAllString(page, "\n")
+	page = headingRe.ReplaceAllString(page, "\n\x00$1\n")
+	page = cellEndRe.ReplaceAllString(page, "\t")
+	page = blockTagRe.ReplaceAllString(page, "\n")
+
+	var lines []string
+	for _, raw := range strings.Split(page, "\n") {
+		heading := strings.HasPrefix(raw, "\x00")
+		line := cleanText(strings.TrimPrefix(raw, "\x00"))
+		if line == "" {
+			continue
+		}
+		if heading {
+			line = "\x00" + line
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// cleanText strips tags and entities and collapses whitespace. Table cells stay separated
+// by a tab.
+func cleanText(s string) string {
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, " "))
+	cells := strings.Split(s, "\t")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(spaceRe.ReplaceAllString(c, " "))
+	}
+	return strings.Trim(strings.Join(cells, "\t"), "\t ")
+}
+
+// gtinsInRun finds the barcodes in digits separated by spaces, as in "4 001686 301265".
+// Groups are joined until they form a valid GTIN-13 or, failing that, GTIN-8.
+func gtinsInRun(run string) []string {
+	groups := strings.Fields(run)
+	var found []string
+	for i := 0; i < len(groups); {
+		best, next := "", i+1
+		acc := ""
+		for j := i; j < len(groups) && len(acc) < 13; j++ {
+			acc += groups[j]
+			if (len(acc) == 13 || len(acc) == 8 && best == "") && ValidGTIN(acc) {
+				best, next = acc, j+1
+			}
+		}
+		if best != "" {
+			found = append(found, best)
+		}
+		i = next
+	}
+	return found
+}
+
+// addLots splits a list of lot numbers and passes those containing a digit to add
+func addLots(s string, add func(string)) {
+	if loc := lotStopRe.FindStringIndex(s); loc != nil {
+		s = s[:loc[0]]
+	}
+	s = parenRe.ReplaceAllString(s, "")
+	for _, part := range lotSplitRe.Split(s, -1) {
+		part = strings.Trim(part, " .:()\"„“")
+		if part == "" || len(part) > 24 || !strings.ContainsAny(part, "0123456789") {
+			continue
+		}
+		add(part)
+	}
+}
+
+// parseRecallDate returns the date as YYYY-MM-DD, or "" if it's invalid
+func parseRecallDate(day, month, year string) string {
+	if len(year) == 2 {
+		year = "20" + year
+	}
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	dd, _ := strconv.Atoi(day)
+	t := time.Date(y, time.Month(m), dd, 0, 0, 0, 0, time.UTC)
+	if t.Day() != dd || int(t.Month()) != m {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// findStates returns the federal states named in s
+func findStates(s string) []FederalState {
+	s = strings.ToLower(nrwRe.ReplaceAllString(s, "nordrhein-westfalen"))
+	var found []FederalState
+	for _, sn := range stateNames {
+		if strings.Contains(s, sn.name) {
+			found = append(found, sn.state)
+			s = strings.ReplaceAll(s, sn.name, " ")
+		}
+	}
+	return found
+}
@@ -0,0 +1,205 @@
+package rewerse
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseRecallFixture(t *testing.T, name string) RecallDetails {
+	t.Helper()
+	d, err := ParseRecallDetails(bytes.NewReader(loadFixture(t, name)))
+	if err != nil {
+		t.Fatalf("ParseRecallDetails failed: %v", err)
+	}
+	return d
+}
+
+func TestParseRecallDetails(t *testing.T) {
+	d := parseRecallFixture(t, "recall_details_beba.html")
+
+	if d.Title != "Vorsorglicher Produktrückruf von verschiedenen Beba Produkten" {
+		t.Errorf("unexpected title: %q", d.Title)
+	}
+	if !strings.HasPrefix(d.Manufacturer, "Nestlé Deutschland AG") {
+		t.Errorf("unexpected manufacturer: %q", d.Manufacturer)
+	}
+	// the numbers in the script and footer must be ignored
+	if want := []string{"7613036251006", "7613036251013", "7613036251020"}; !reflect.DeepEqual(d.EANs, want) {
+		t.Errorf("EANs = %v, want %v", d.EANs, want)
+	}
+	if want := []string{"4321A001", "4321A002", "4328B114", "4335C207"}; !reflect.DeepEqual(d.LotNumbers, want) {
+		t.Errorf("LotNumbers = %v, want %v", d.LotNumbers, want)
+	}
+	if want := []string{"2025-11-12", "2025-11-19", "2025-11-26", "2025-12-03"}; !reflect.DeepEqual(d.BestBefore, want) {
+		t.Errorf("BestBefore = %v, want %v", d.BestBefore, want)
+	}
+	if want := []FederalState{StateHessen, StateNordrheinWestfalen, StateRheinlandPfalz}; !reflect.DeepEqual(d.Regions, want) || d.Nationwide {
+		t.Errorf("Regions = %v (nationwide %v), want %v", d.Regions, d.Nationwide, want)
+	}
+}
+
+func TestParseRecallDetailsTable(t *testing.T) {
+	d := parseRecallFixture(t, "recall_details_table.html")
+
+	if d.Manufacturer != "Käserei Allgäu GmbH & Co. KG" {
+		t.Errorf("unexpected manufacturer: %q", d.Manufacturer)
+	}
+	// spaced barcode in the table; the one in the HTML comment is ignored
+	if want := []string{"4001686301265"}; !reflect.DeepEqual(d.EANs, want) {
+		t.Errorf("EANs = %v, want %v", d.EANs, want)
+	}
+	if want := []string{"L24 311", "L24 312", "L24 313", "L24 314"}; !reflect.DeepEqual(d.LotNumbers, want) {
+		t.Errorf("LotNumbers = %v, want %v", d.LotNumbers, want)
+	}
+	if want := []string{"2024-11-28"}; !reflect.DeepEqual(d.BestBefore, want) {
+		t.Errorf("BestBefore = %v, want %v", d.BestBefore, want)
+	}
+	if !d.Nationwide || len(d.Regions) != 0 {
+		t.Errorf("expected nationwide recall, got %v", d.Regions)
+	}
+	if !strings.Contains(d.String(), "bundesweit") || !strings.Contains(d.String(), "28.11.2024") {
+		t.Errorf("unexpected String():\n%s", d)
+	}
+}
+
+func TestParseRecallDetailsEANLabel(t *testing.T) {
+	page := `<h1>Rückruf</h1>
+<p>Produkt</p><p>Schnittkäse</p><p>Gouda</p><p>Edamer</p>
+<p>Strichcode</p><p>Gouda 400 g</p><p>4001686301265</p>`
+	d, err := ParseRecallDetails(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("ParseRecallDetails failed: %v", err)
+	}
+	if want := []string{"4001686301265"}; !reflect.DeepEqual(d.EANs, want) {
+		t.Errorf("EANs = %v, want %v", d.EANs, want)
+	}
+
+	// without a label, the number is ignored
+	d, err = ParseRecallDetails(strings.NewReader(`<p>Artikelnummer 4001686301265</p>`))
+	if err != nil {
+		t.Fatalf("ParseRecallDetails failed: %v", err)
+	}
+	if len(d.EANs) != 0 {
+		t.Errorf("expected no EANs, got %v", d.EANs)
+	}
+}
+
+func TestValidGTIN(t *testing.T) {
+	for s, want := range map[string]bool{
+		"4001686301265":  true,
+		"04001686301265": true,
+		"40055008":       true,
+		"4001686301266":  false,
+		"400168630126":   false,
+		"40016863012a5":  false,
+		"":               false,
+	} {
+		if got := ValidGTIN(s); got != want {
+			t.Errorf("ValidGTIN(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestAffectsGTIN(t *testing.T) {
+	d := RecallDetails{EANs: []string{"4001686301265"}}
+	if !d.AffectsGTIN("04001686301265") || !d.AffectsGTIN(" 4001686301265") {
+		t.Error("expected GTIN-14 and padded barcode to match")
+	}
+	if d.AffectsGTIN("7613036251006") || d.AffectsGTIN("") {
+		t.Error("unexpected match")
+	}
+}
+
+func TestFetchRecallDetails(t *testing.T) {
+	page := loadFixture(t, "recall_details_beba.html")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(page)
+	}))
+	defer srv.Close()
+
+	r := Recall{URL: srv.URL + "/beba", SubjectProduct: "Beba Produkte", SubjectReason: "Cereulid"}
+	d, err := FetchRecallDetails(r)
+	if err != nil {
+		t.Fatalf("FetchRecallDetails failed: %v", err)
+	}
+	if d.Recall != r || len(d.EANs) != 3 {
+		t.Errorf("unexpected details: %+v", d)
+	}
+
+	var httpErr *HTTPError
+	if _, err := FetchRecallDetails(Recall{URL: srv.URL + "/missing"}); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected HTTP 404, got %v", err)
+	}
+}
+
+func TestEnrichRecalls(t *testing.T) {
+	recalls := Recalls{{URL: "a"}, {URL: "b"}}
+	fetch := func(r Recall) (RecallDetails, error) {
+		if r.URL == "b" {
+			return RecallDetails{}, errors.New("timeout")
+		}
+		return RecallDetails{Recall: r, EANs: []string{"4001686301265"}}, nil
+	}
+	details, err := enrichRecalls(recalls, &RateLimit{Workers: 2}, fetch)
+	if err != nil || len(details) != 1 || details[0].URL != "a" {
+		t.Fatalf("unexpected result: %+v (%v)", details, err)
+	}
+	if _, err := enrichRecalls(recalls[1:], &RateLimit{}, fetch); err == nil {
+		t.Error("expected error when every page fails")
+	}
+
+	candidates := []RecallCandidate{
+		{Source: "watchlist", Title: "Gouda", GTIN: "04001686301265"},
+		{Source: "watchlist", Title: "Gouda alt"},
+	}
+	matches := MatchRecallsByGTIN(details, candidates)
+	if len(matches) != 1 || matches[0].Score != RecallGTINMatchScore || !matches[0].ByGTIN || matches[0].Recall.URL != "a" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestRecallMonitorDetails(t *testing.T) {
+	recalls := Recalls{{URL: "https://example.org/gouda", SubjectProduct: "Produktwarnung Gouda jung"}}
+	var notes recordingNotifier
+	rm := &RecallMonitor{
+		Candidates: []RecallCandidate{
+			{Source: "watchlist", Title: "Gouda jung", GTIN: "4001686301265"},
+			{Source: "watchlist", Title: "Scheibenkäse", GTIN: "7613036251006"},
+		},
+		Notifiers: []Notifier{&notes},
+	}
+	fetches := 0
+	fetch := func(r Recall) (RecallDetails, error) {
+		fetches++
+		return RecallDetails{Recall: r, EANs: []string{"4001686301265"}}, nil
+	}
+	now := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	report := rm.check(recalls, now, fetch)
+	// the title match of the first candidate is replaced by the barcode match
+	if len(report.Matches) != 1 || !report.Matches[0].ByGTIN || report.Matches[0].Candidate.GTIN != "4001686301265" {
+		t.Fatalf("unexpected matches: %+v", report.Matches)
+	}
+	if len(notes) != 1 || !strings.HasPrefix(notes[0].String(), "Wahrscheinlich betroffen (watchlist, EAN 4001686301265)") {
+		t.Errorf("unexpected notifications: %+v", notes)
+	}
+
+	// stored details are not loaded again
+	rm.check(recalls, now.Add(time.Hour), fetch)
+	if fetches != 1 {
+		t.Errorf("expected 1 fetch, got %d", fetches)
+	}
+	if sr := rm.History.Recalls[recalls[0].URL]; sr.Details == nil || len(sr.Details.EANs) != 1 {
+		t.Errorf("details not stored: %+v", sr)
+	}
+}
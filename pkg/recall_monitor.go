@@ -0,0 +1,620 @@
+package rewerse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// StoredRecall is a recall with its history. Recalls have no ID; they are keyed by URL.
+type StoredRecall struct {
+	Recall
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// WithdrawnAt is set once the recall is no longer listed
+	WithdrawnAt *time.Time `json:"withdrawnAt,omitempty"`
+	// Notified are the keys of the candidates whose match was delivered to every notifier
+	Notified []string `json:"notified,omitempty"`
+	// Pending are the new and withdrawn events not yet delivered to every notifier
+	Pending []RecallEvent `json:"pending,omitempty"`
+	// Details are the fields of the recall page, if RecallMonitor.Details is set
+	Details *RecallDetails `json:"details,omitempty"`
+}
+
+// RecallHistory contains all recalls seen so far, keyed by URL
+type RecallHistory struct {
+	CheckedAt time.Time               `json:"checkedAt"`
+	Recalls   map[string]StoredRecall `json:"recalls"`
+}
+
+// RecallChanges are the differences between two recall checks
+type RecallChanges struct {
+	New       []StoredRecall `json:"new"`
+	Withdrawn []StoredRecall `json:"withdrawn"`
+}
+
+// LoadRecallHistory reads the history from a JSON file. A missing file is an empty history.
+func LoadRecallHistory(path string) (RecallHistory, error) {
+	h := RecallHistory{Recalls: make(map[string]StoredRecall)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("error reading recall history: %w", err)
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("error unmarshalling recall history: %w", err)
+	}
+	if h.Recalls == nil {
+		h.Recalls = make(map[string]StoredRecall)
+	}
+	return h, nil
+}
+
+// Save writes the history to a JSON file
+func (h RecallHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling recall history: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing recall history: %w", err)
+	}
+	return nil
+}
+
+// Update records the currently listed recalls. Recalls that are listed again after
+// being withdrawn count as new.
+func (h *RecallHistory) Update(current Recalls, now time.Time) RecallChanges {
+	if h.Recalls == nil {
+		h.Recalls = make(map[string]StoredRecall)
+	}
+	var changes RecallChanges
+	listed := make(map[string]bool, len(current))
+	for _, r := range current {
+		if listed[r.URL] {
+			continue
+		}
+		listed[r.URL] = true
+		sr, known := h.Recalls[r.URL]
+		isNew := !known || sr.WithdrawnAt != nil
+		if isNew {
+			sr = StoredRecall{FirstSeen: now}
+		}
+		sr.Recall = r
+		sr.LastSeen = now
+		h.Recalls[r.URL] = sr
+		if isNew {
+			changes.New = append(changes.New, sr)
+		}
+	}
+
+	for url, sr := range h.Recalls {
+		if listed[url] || sr.WithdrawnAt != nil {
+			continue
+		}
+		withdrawn := now
+		sr.WithdrawnAt = &withdrawn
+		h.Recalls[url] = sr
+		changes.Withdrawn = append(changes.Withdrawn, sr)
+	}
+	sort.Slice(changes.Withdrawn, func(i, j int) bool { return changes.Withdrawn[i].URL < changes.Withdrawn[j].URL })
+
+	h.CheckedAt = now
+	return changes
+}
+
+// Active returns the recalls that are currently listed, newest first
+func (h RecallHistory) Active() []StoredRecall {
+	var active []StoredRecall
+	for _, sr := range h.Recalls {
+		if sr.WithdrawnAt == nil {
+			active = append(active, sr)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if !active[i].FirstSeen.Equal(active[j].FirstSeen) {
+			return active[i].FirstSeen.After(active[j].FirstSeen)
+		}
+		return active[i].URL < active[j].URL
+	})
+	return active
+}
+
+// RecallCandidate is a product a recall is matched against
+type RecallCandidate struct {
+	// Source names where the product comes from: "basket", "watchlist"
+	Source    string `json:"source"`
+	ProductID string `json:"productId,omitempty"`
+	Title     string `json:"title"`
+	// Brand is optional; a matching brand raises the score
+	Brand string `json:"brand,omitempty"`
+	// GTIN is the optional barcode, matched exactly against the EANs of RecallDetails
+	GTIN string `json:"gtin,omitempty"`
+}
+
+func (c RecallCandidate) key() string {
+	if c.ProductID != "" {
+		return c.Source + ":" + c.ProductID
+	}
+	if c.GTIN != "" {
+		return c.Source + ":" + c.GTIN
+	}
+	return c.Source + ":" + strings.ToLower(c.Title)
+}
+
+// BasketRecallCandidates returns the products of a basket
+func BasketRecallCandidates(b Basket) []RecallCandidate {
+	candidates := make([]RecallCandidate, 0, len(b.LineItems))
+	for _, li := range b.LineItems {
+		candidates = append(candidates, RecallCandidate{
+			Source:    "basket",
+			ProductID: li.Product.ProductID,
+			Title:     li.Product.Title,
+		})
+	}
+	return candidates
+}
+
+// ReadWatchlist reads a watchlist with one product per line. A brand can be given
+// before a "|" and a barcode after one: "Nestlé | BEBA Optipro Pre | 7613036251006".
+// A line can also be just a barcode. Empty lines and lines starting with # are skipped.
+func ReadWatchlist(r io.Reader) ([]RecallCandidate, error) {
+	var candidates []RecallCandidate
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		c := RecallCandidate{Source: "watchlist"}
+		if last := fields[len(fields)-1]; ValidGTIN(last) {
+			c.GTIN = last
+			fields = fields[:len(fields)-1]
+		}
+		switch len(fields) {
+		case 0:
+			c.Title = c.GTIN
+		case 1:
+			c.Title = fields[0]
+		default:
+			c.Brand, c.Title = fields[0], strings.Join(fields[1:], " | ")
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, scanner.Err()
+}
+
+// RecallMatch is a candidate product that probably is affected by a recall
+type RecallMatch struct {
+	Recall    Recall          `json:"recall"`
+	Candidate RecallCandidate `json:"candidate"`
+	// Score is the share of the recall's product words found in the candidate (0-1),
+	// or RecallGTINMatchScore for barcode matches
+	Score float64 `json:"score"`
+	// ByGTIN is set if the candidate's barcode was found on the recall page
+	ByGTIN bool `json:"byGtin,omitempty"`
+}
+
+// DefaultRecallMatchScore is the minimum score of MatchRecalls if none is given
+const DefaultRecallMatchScore = 0.6
+
+// recallStopwords are words of recall subjects that don't describe the product
+var recallStopwords = map[string]bool{
+	"vorsorglicher": true, "vorsorglich": true, "produktrückruf": true, "rückruf": true,
+	"warnung": true, "öffentliche": true, "verbraucher": true, "verschiedenen": true,
+	"verschiedener": true, "produkte": true, "produkten": true, "produkt": true, "artikel": true,
+	"der": true, "die": true, "das": true, "den": true, "des": true, "dem": true, "und": true,
+	"oder": true, "von": true, "vom": true, "mit": true, "für": true, "im": true, "in": true,
+	"aus": true, "bei": true, "zu": true, "zum": true, "zur": true, "ein": true, "eine": true,
+	"einer": true, "marke": true, "firma": true, "hersteller": true, "sorte": true, "sorten": true,
+	"liter": true, "gramm": true, "kg": true, "ml": true, "stück": true, "packung": true, "flasche": true,
+}
+
+// weakRecallTokens are words that appear in many product titles and count less
+var weakRecallTokens = map[string]bool{
+	"rewe": true, "bio": true, "ja": true, "beste": true, "wahl": true, "regional": true,
+	"feine": true, "welt": true, "frei": true,
+}
+
+// recallTokens splits text into lowercase words, dropping stopwords, numbers and sizes
+func recallTokens(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var tokens []string
+	for _, f := range fields {
+		if len([]rune(f)) < 2 || recallStopwords[f] || unicode.IsDigit([]rune(f)[0]) {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// tokenMatch compares words, accepting inflections and compounds: "hafer" ~ "hafermilch"
+func tokenMatch(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len([]rune(a)) < 5 || len([]rune(b)) < 5 {
+		return false
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// recallScore is the weighted share of the recall's product words found in the candidate
+func recallScore(r Recall, c RecallCandidate) float64 {
+	recall := recallTokens(r.SubjectProduct)
+	product := recallTokens(c.Brand + " " + c.Title)
+	if len(recall) == 0 || len(product) == 0 {
+		return 0
+	}
+
+	var total, found float64
+	for _, rt := range recall {
+		weight := 1.0
+		if weakRecallTokens[rt] {
+			weight = 0.25
+		}
+		total += weight
+		for _, pt := range product {
+			if tokenMatch(rt, pt) {
+				found += weight
+				break
+			}
+		}
+	}
+	score := found / total
+
+	// a brand named in the recall confirms the match
+	if brand := recallTokens(c.Brand); len(brand) > 0 && found > 0 {
+		for _, bt := range brand {
+			for _, rt := range recall {
+				if tokenMatch(bt, rt) {
+					score += 0.2
+					break
+				}
+			}
+		}
+	}
+	if score > 1 {
+		score = 1
+	}
+	return score
+}
+
+// MatchRecalls matches every recall against every candidate by title and brand words.
+// Matches below minScore (default: DefaultRecallMatchScore) are dropped; the rest is
+// sorted by score, best first.
+func MatchRecalls(recalls []Recall, candidates []RecallCandidate, minScore float64) []RecallMatch {
+	if minScore <= 0 {
+		minScore = DefaultRecallMatchScore
+	}
+	var matches []RecallMatch
+	for _, r := range recalls {
+		for _, c := range candidates {
+			if score := recallScore(r, c); score >= minScore {
+				matches = append(matches, RecallMatch{Recall: r, Candidate: c, Score: score})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
+
+// RecallEvent is the kind of a recall notification
+type RecallEvent string
+
+const (
+	RecallEventNew       RecallEvent = "new"
+	RecallEventWithdrawn RecallEvent = "withdrawn"
+	RecallEventMatch     RecallEvent = "match"
+)
+
+// RecallNotification is sent to Notifiers for every change found by RecallMonitor.Check
+type RecallNotification struct {
+	Event  RecallEvent  `json:"event"`
+	Time   time.Time    `json:"time"`
+	Recall Recall       `json:"recall"`
+	Match  *RecallMatch `json:"match,omitempty"`
+}
+
+func (n RecallNotification) String() string {
+	switch n.Event {
+	case RecallEventNew:
+		return fmt.Sprintf("Neuer Rückruf: %s (%s) %s", n.Recall.SubjectProduct, n.Recall.SubjectReason, n.Recall.URL)
+	case RecallEventWithdrawn:
+		return fmt.Sprintf("Rückruf beendet: %s", n.Recall.SubjectProduct)
+	case RecallEventMatch:
+		if n.Match.ByGTIN {
+			return fmt.Sprintf("Wahrscheinlich betroffen (%s, EAN %s): %s <- %s %s", n.Match.Candidate.Source,
+				n.Match.Candidate.GTIN, n.Match.Candidate.Title, n.Recall.SubjectProduct, n.Recall.URL)
+		}
+		return fmt.Sprintf("Möglicherweise betroffen (%s, %.0f%%): %s <- %s %s", n.Match.Candidate.Source,
+			n.Match.Score*100, n.Match.Candidate.Title, n.Recall.SubjectProduct, n.Recall.URL)
+	}
+	return string(n.Event)
+}
+
+// Notifier delivers recall notifications
+type Notifier interface {
+	Notify(n RecallNotification) error
+}
+
+// WriterNotifier writes one line per notification
+type WriterNotifier struct {
+	W io.Writer
+}
+
+func (wn WriterNotifier) Notify(n RecallNotification) error {
+	_, err := fmt.Fprintln(wn.W, n.String())
+	return err
+}
+
+// WebhookNotifier posts each notification as JSON to a URL
+type WebhookNotifier struct {
+	URL string
+	// Client is used for the requests (default: a client with a 10s timeout)
+	Client *http.Client
+}
+
+func (wn WebhookNotifier) Notify(n RecallNotification) (err error) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	client := wn.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(wn.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error posting to webhook: %w", err)
+	}
+	defer CloseWithWrap(resp.Body, &err)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RecallMonitor checks the recalls, records them in the history and notifies about
+// new and withdrawn recalls and about matches with the candidate products.
+// The first check of an empty history only records the listed recalls; it notifies
+// matches but not every recall as new.
+type RecallMonitor struct {
+	History    *RecallHistory
+	Candidates []RecallCandidate
+	Notifiers  []Notifier
+	// MinScore is the minimum match score (default: DefaultRecallMatchScore)
+	MinScore float64
+	// Details loads the page of every new recall, so candidates with a GTIN are matched
+	// by barcode as well
+	Details bool
+}
+
+// RecallReport is the result of one RecallMonitor check
+type RecallReport struct {
+	RecallChanges
+	// Seeded is set if the history was empty; the listed recalls were recorded without
+	// notifying them as new
+	Seeded bool `json:"seeded,omitempty"`
+	// Active is the number of currently listed recalls
+	Active int `json:"active"`
+	// Matches are all matches of listed recalls; each is notified until it was delivered
+	// to every notifier once
+	Matches []RecallMatch `json:"matches"`
+	// NotifyErrors are the failed notifications
+	NotifyErrors []string `json:"notifyErrors,omitempty"`
+	// DetailErrors are the recall pages that could not be loaded; they are retried next check
+	DetailErrors []string `json:"detailErrors,omitempty"`
+}
+
+func (rr RecallReport) String() string {
+	var sb strings.Builder
+	sb.WriteString(sep(fmt.Sprintf("Rückrufe (%d aktiv)", rr.Active)))
+	sb.WriteByte('\n')
+	if rr.Seeded {
+		sb.WriteString("   Verlauf angelegt, aktuelle Rückrufe ohne Benachrichtigung übernommen\n")
+	}
+	sb.WriteString(align("Neu"))
+	sb.WriteString(fmt.Sprint(len(rr.New)))
+	sb.WriteByte('\n')
+	sb.WriteString(align("Beendet"))
+	sb.WriteString(fmt.Sprint(len(rr.Withdrawn)))
+	sb.WriteByte('\n')
+	for _, r := range rr.New {
+		sb.WriteString("   + ")
+		sb.WriteString(r.SubjectProduct)
+		sb.WriteByte('\n')
+	}
+	for _, r := range rr.Withdrawn {
+		sb.WriteString("   - ")
+		sb.WriteString(r.SubjectProduct)
+		sb.WriteByte('\n')
+	}
+
+	if len(rr.Matches) > 0 {
+		sb.WriteByte('\n')
+		sb.WriteString(sep("Möglicherweise betroffen"))
+		sb.WriteByte('\n')
+		for _, m := range rr.Matches {
+			sb.WriteString(fmt.Sprintf("   %3.0f%%  %s (%s)\n         %s\n", m.Score*100, m.Candidate.Title, m.Candidate.Source, m.Recall.SubjectProduct))
+		}
+	}
+	for _, e := range append(rr.NotifyErrors, rr.DetailErrors...) {
+		sb.WriteString("   Fehler: ")
+		sb.WriteString(e)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Check fetches the current recalls and updates the history. The caller saves the history.
+func (rm *RecallMonitor) Check() (RecallReport, error) {
+	recalls, err := GetRecalls()
+	if err != nil {
+		return RecallReport{}, err
+	}
+	var details func(Recall) (RecallDetails, error)
+	if rm.Details {
+		details = FetchRecallDetails
+	}
+	return rm.check(recalls, time.Now().UTC(), details), nil
+}
+
+// check updates the history with recalls. If details is set, it's used to load the pages
+// of listed recalls that have none yet.
+func (rm *RecallMonitor) check(recalls Recalls, now time.Time, details func(Recall) (RecallDetails, error)) RecallReport {
+	if rm.History == nil {
+		rm.History = &RecallHistory{}
+	}
+	seed := rm.History.CheckedAt.IsZero() && len(rm.History.Recalls) == 0
+	report := RecallReport{RecallChanges: rm.History.Update(recalls, now)}
+	// notify reports whether every notifier delivered n
+	notify := func(n RecallNotification) bool {
+		n.Time = now
+		delivered := true
+		for _, nf := range rm.Notifiers {
+			if err := nf.Notify(n); err != nil {
+				report.NotifyErrors = append(report.NotifyErrors, err.Error())
+				delivered = false
+			}
+		}
+		return delivered
+	}
+
+	if seed {
+		report.Seeded = true
+		report.New = nil
+	}
+	for _, r := range report.New {
+		rm.addPending(r.URL, RecallEventNew)
+	}
+	for _, r := range report.Withdrawn {
+		rm.addPending(r.URL, RecallEventWithdrawn)
+	}
+	// failed events are notified again in the next check
+	var urls []string
+	for url, sr := range rm.History.Recalls {
+		if len(sr.Pending) > 0 {
+			urls = append(urls, url)
+		}
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		sr := rm.History.Recalls[url]
+		var failed []RecallEvent
+		for _, ev := range sr.Pending {
+			if !notify(RecallNotification{Event: ev, Recall: sr.Recall}) {
+				failed = append(failed, ev)
+			}
+		}
+		sr.Pending = failed
+		rm.History.Recalls[url] = sr
+	}
+
+	if details != nil {
+		report.DetailErrors = rm.loadDetails(details)
+	}
+
+	active := rm.History.Active()
+	report.Active = len(active)
+	listed := make([]Recall, len(active))
+	var pages []RecallDetails
+	for i, sr := range active {
+		listed[i] = sr.Recall
+		if sr.Details != nil {
+			d := *sr.Details
+			d.Recall = sr.Recall
+			pages = append(pages, d)
+		}
+	}
+
+	// barcode matches are more specific and replace title matches of the same pair
+	report.Matches = MatchRecallsByGTIN(pages, rm.Candidates)
+	byGTIN := make(map[string]bool, len(report.Matches))
+	for _, m := range report.Matches {
+		byGTIN[m.Recall.URL+" "+m.Candidate.key()] = true
+	}
+	for _, m := range MatchRecalls(listed, rm.Candidates, rm.MinScore) {
+		if !byGTIN[m.Recall.URL+" "+m.Candidate.key()] {
+			report.Matches = append(report.Matches, m)
+		}
+	}
+
+	for i := range report.Matches {
+		m := report.Matches[i]
+		sr := rm.History.Recalls[m.Recall.URL]
+		key := m.Candidate.key()
+		if containsString(sr.Notified, key) {
+			continue
+		}
+		// failed matches are notified again in the next check
+		if notify(RecallNotification{Event: RecallEventMatch, Recall: m.Recall, Match: &m}) {
+			sr.Notified = append(sr.Notified, key)
+			rm.History.Recalls[m.Recall.URL] = sr
+		}
+	}
+	return report
+}
+
+// addPending queues an event of the recall at url for notification
+func (rm *RecallMonitor) addPending(url string, ev RecallEvent) {
+	sr := rm.History.Recalls[url]
+	sr.Pending = append(sr.Pending, ev)
+	rm.History.Recalls[url] = sr
+}
+
+// loadDetails loads the missing pages of the listed recalls and returns the failures
+func (rm *RecallMonitor) loadDetails(fetch func(Recall) (RecallDetails, error)) []string {
+	var missing Recalls
+	for _, sr := range rm.History.Active() {
+		if sr.Details == nil {
+			missing = append(missing, sr.Recall)
+		}
+	}
+	results := make([]RecallDetails, len(missing))
+	errs := make([]error, len(missing))
+	// the pages are on a separate host and only a handful are new per check
+	(&RateLimit{Workers: 2}).run(len(missing), func(i int) {
+		results[i], errs[i] = fetch(missing[i])
+	})
+
+	var failed []string
+	for i, r := range missing {
+		if errs[i] != nil {
+			failed = append(failed, fmt.Sprintf("details %s: %v", r.URL, errs[i]))
+			continue
+		}
+		sr := rm.History.Recalls[r.URL]
+		d := results[i]
+		sr.Details = &d
+		rm.History.Recalls[r.URL] = sr
+	}
+	return failed
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
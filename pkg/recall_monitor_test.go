@@ -0,0 +1,171 @@
+package rewerse
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecallHistoryUpdate(t *testing.T) {
+	a := Recall{URL: "https://example.org/a", SubjectProduct: "A"}
+	b := Recall{URL: "https://example.org/b", SubjectProduct: "B"}
+	t1 := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	t2, t3 := t1.Add(24*time.Hour), t1.Add(48*time.Hour)
+
+	var h RecallHistory
+	if c := h.Update(Recalls{a, b}, t1); len(c.New) != 2 || len(c.Withdrawn) != 0 {
+		t.Fatalf("first check: unexpected changes %+v", c)
+	}
+	c := h.Update(Recalls{b}, t2)
+	if len(c.New) != 0 || len(c.Withdrawn) != 1 || c.Withdrawn[0].URL != a.URL {
+		t.Fatalf("second check: unexpected changes %+v", c)
+	}
+	if active := h.Active(); len(active) != 1 || !active[0].FirstSeen.Equal(t1) || !active[0].LastSeen.Equal(t2) {
+		t.Errorf("unexpected active recalls: %+v", active)
+	}
+
+	// a recall listed again counts as new
+	c = h.Update(Recalls{a, b}, t3)
+	if len(c.New) != 1 || c.New[0].URL != a.URL || !c.New[0].FirstSeen.Equal(t3) || c.New[0].WithdrawnAt != nil {
+		t.Errorf("third check: unexpected changes %+v", c)
+	}
+
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := h.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadRecallHistory(path)
+	if err != nil || len(loaded.Recalls) != 2 || !loaded.CheckedAt.Equal(t3) {
+		t.Errorf("unexpected loaded history: %+v (%v)", loaded, err)
+	}
+	if empty, err := LoadRecallHistory(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(empty.Recalls) != 0 {
+		t.Errorf("missing file must be an empty history: %v", err)
+	}
+}
+
+func TestMatchRecalls(t *testing.T) {
+	var res recallsResponse
+	loadJSONFixture(t, "recalls.json", &res)
+	recalls := append(res.Data.ProductRecalls.Products, Recall{
+		URL:            "https://example.org/hafer",
+		SubjectProduct: "Rückruf von REWE Bio Hafermilch 1 Liter",
+	})
+
+	candidates := []RecallCandidate{
+		{Source: "basket", ProductID: "1", Title: "Nestlé BEBA Optipro Pre Anfangsmilch 800g"},
+		{Source: "basket", ProductID: "2", Title: "REWE Beste Wahl Gouda 400g"},
+		{Source: "watchlist", Title: "REWE Bio Hafermilch ungesüßt 1l"},
+		{Source: "watchlist", Title: "Alpro Haferdrink", Brand: "Alpro"},
+	}
+	matches := MatchRecalls(recalls, candidates, 0)
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %+v", matches)
+	}
+	for _, m := range matches {
+		if m.Candidate.Title == candidates[1].Title || m.Candidate.Brand == "Alpro" {
+			t.Errorf("unexpected match: %+v", m)
+		}
+		if m.Score < DefaultRecallMatchScore || m.Score > 1 {
+			t.Errorf("score out of range: %v", m.Score)
+		}
+	}
+}
+
+type recordingNotifier []RecallNotification
+
+func (rn *recordingNotifier) Notify(n RecallNotification) error {
+	*rn = append(*rn, n)
+	return nil
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(RecallNotification) error {
+	return errors.New("unreachable")
+}
+
+func TestRecallMonitorCheck(t *testing.T) {
+	recalls := Recalls{{URL: "https://example.org/beba", SubjectProduct: "Beba Produkte"}}
+	var notes recordingNotifier
+	rm := &RecallMonitor{
+		Candidates: []RecallCandidate{{Source: "basket", ProductID: "1", Title: "BEBA Optipro"}},
+		Notifiers:  []Notifier{&notes},
+	}
+	now := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// the first check records the recalls and only notifies the match
+	report := rm.check(recalls, now, nil)
+	if !report.Seeded || len(report.New) != 0 || len(report.Matches) != 1 || report.Active != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(notes) != 1 || notes[0].Event != RecallEventMatch {
+		t.Fatalf("unexpected notifications: %+v", notes)
+	}
+
+	// the match is reported but not notified again
+	report = rm.check(recalls, now.Add(time.Hour), nil)
+	if report.Seeded || len(report.Matches) != 1 || len(notes) != 1 {
+		t.Errorf("expected no new notifications, got %+v", notes[1:])
+	}
+
+	// failed notifications are retried
+	recalls = append(recalls, Recall{URL: "https://example.org/optipro", SubjectProduct: "BEBA Optipro Pre"})
+	rm.Notifiers = []Notifier{&notes, failingNotifier{}}
+	report = rm.check(recalls, now.Add(90*time.Minute), nil)
+	if len(report.New) != 1 || len(report.NotifyErrors) != 2 || len(notes) != 3 {
+		t.Fatalf("expected new and match notification with 2 errors, got %+v, %v", notes[1:], report.NotifyErrors)
+	}
+	rm.Notifiers = []Notifier{&notes}
+	rm.check(recalls, now.Add(100*time.Minute), nil)
+	if len(notes) != 5 || notes[3].Event != RecallEventNew || notes[4].Event != RecallEventMatch || notes[4].Recall.URL != "https://example.org/optipro" {
+		t.Fatalf("expected retried new and match notification, got %+v", notes[3:])
+	}
+
+	rm.Notifiers = []Notifier{&notes, failingNotifier{}}
+	rm.check(nil, now.Add(2*time.Hour), nil)
+	rm.Notifiers = []Notifier{&notes}
+	rm.check(nil, now.Add(3*time.Hour), nil)
+	if len(notes) != 9 || notes[7].Event != RecallEventWithdrawn || notes[8].Event != RecallEventWithdrawn {
+		t.Errorf("expected retried withdrawn notifications, got %+v", notes[5:])
+	}
+	rm.check(nil, now.Add(4*time.Hour), nil)
+	if len(notes) != 9 {
+		t.Errorf("expected no further notifications, got %+v", notes[9:])
+	}
+}
+
+func TestReadWatchlist(t *testing.T) {
+	candidates, err := ReadWatchlist(strings.NewReader("# Baby\nNestlé | BEBA Optipro Pre | 7613036251006\n\nHafermilch\n4001686301265\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 3 || candidates[0].Brand != "Nestlé" || candidates[0].Title != "BEBA Optipro Pre" || candidates[1].Title != "Hafermilch" {
+		t.Errorf("unexpected watchlist: %+v", candidates)
+	}
+	if candidates[0].GTIN != "7613036251006" || candidates[1].GTIN != "" || candidates[2].GTIN != "4001686301265" {
+		t.Errorf("unexpected GTINs: %+v", candidates)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got RecallNotification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	n := RecallNotification{Event: RecallEventNew, Recall: Recall{URL: "https://example.org/a"}}
+	if err := (WebhookNotifier{URL: srv.URL}).Notify(n); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got.Event != RecallEventNew || got.Recall.URL != n.Recall.URL {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
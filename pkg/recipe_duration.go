@@ -0,0 +1,233 @@
+package rewerse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recipeDurationRegex matches the parts of a duration text: "50 min", "1 Std. 20 min"
+var recipeDurationRegex = regexp.MustCompile(`(?i)(\d+)\s*(std\.?|stunden?|h|min\.?|minuten)`)
+
+// maxTagSearchPages limits the searches run per tag by SearchRecipesFiltered. Tags with
+// more results are reported in RecipeFilterResults.IncompleteTags.
+const maxTagSearchPages = 5
+
+// ParseRecipeDuration parses the free-text cooking time of a recipe:
+// "50 min", "90 min", "1 Std. 20 min", "2 Std."
+func ParseRecipeDuration(s string) (time.Duration, error) {
+	matches := recipeDurationRegex.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("invalid recipe duration: %q", s)
+	}
+
+	var d time.Duration
+	for _, m := range matches {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid recipe duration: %q", s)
+		}
+		switch m[2][0] {
+		case 's', 'S', 'h', 'H':
+			d += time.Duration(n) * time.Hour
+		default:
+			d += time.Duration(n) * time.Minute
+		}
+	}
+	return d, nil
+}
+
+// isoDuration formats a duration as ISO 8601 (as used by schema.org): "PT1H20M"
+func isoDuration(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("PT%dH%dM", h, m)
+	case h > 0:
+		return fmt.Sprintf("PT%dH", h)
+	default:
+		return fmt.Sprintf("PT%dM", m)
+	}
+}
+
+// setCookingTimes fills the parsed CookingTime of recipes returned by the API
+func setCookingTimes(recipes []Recipe) {
+	for i := range recipes {
+		recipes[i].CookingTime, _ = ParseRecipeDuration(recipes[i].Duration)
+	}
+}
+
+// RecipeFilter filters recipe search results client-side
+type RecipeFilter struct {
+	// MaxDuration drops recipes whose CookingTime is longer (0 = no limit).
+	// Recipes without a parseable duration are dropped as well.
+	MaxDuration time.Duration
+	// MaxDifficultyLevel drops harder recipes: 1=easy, 2=medium, 3=hard (0 = no limit)
+	MaxDifficultyLevel int
+	// Tags are tags from RecipeMetadata.Tags the recipes must have, e.g. "schnell", "Vegan".
+	// Only evaluated by SearchRecipesFiltered, see there.
+	Tags []string
+}
+
+// IsZero reports whether the filter lets every recipe through
+func (f RecipeFilter) IsZero() bool {
+	return f.MaxDuration <= 0 && f.MaxDifficultyLevel <= 0 && len(f.Tags) == 0
+}
+
+// Match reports whether a recipe passes the duration and difficulty filters
+func (f RecipeFilter) Match(r Recipe) bool {
+	if f.MaxDuration > 0 && (r.CookingTime <= 0 || r.CookingTime > f.MaxDuration) {
+		return false
+	}
+	if f.MaxDifficultyLevel > 0 && r.DifficultyLevel > f.MaxDifficultyLevel {
+		return false
+	}
+	return true
+}
+
+// Apply returns the recipes that pass the duration and difficulty filters
+func (f RecipeFilter) Apply(recipes []Recipe) []Recipe {
+	var filtered []Recipe
+	for _, r := range recipes {
+		if f.Match(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// RecipeFilterResults are the recipes found by SearchRecipesFiltered
+type RecipeFilterResults struct {
+	Recipes []Recipe `json:"recipes"`
+	// IncompleteTags are the tags whose search was cut off after maxTagSearchPages pages.
+	// Recipes found beyond that are missing from Recipes.
+	IncompleteTags []string `json:"incompleteTags,omitempty"`
+}
+
+func (r RecipeFilterResults) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Showing %d matching recipes:\n", len(r.Recipes)))
+	for _, recipe := range r.Recipes {
+		sb.WriteString(recipe.String())
+		sb.WriteByte('\n')
+	}
+	if len(r.IncompleteTags) > 0 {
+		sb.WriteString(fmt.Sprintf("Incomplete: the search for %s was cut off after %d pages.\n",
+			strings.Join(r.IncompleteTags, ", "), maxTagSearchPages))
+	}
+	return sb.String()
+}
+
+// SearchRecipesFiltered pages through RecipeSearch and returns up to limit recipes
+// (default 20) that pass the filter.
+//
+// The API lists tags only as facets in RecipeMetadata, not per recipe, so tag filters are
+// a full-text approximation: each tag is checked against the available tags and searched
+// as search term (within the same collection and difficulty), and a recipe passes if
+// every tag search found it. Recipes that merely mention the tag pass as well. Tag
+// searches are capped at maxTagSearchPages pages; cut-off tags are listed in
+// IncompleteTags.
+func SearchRecipesFiltered(opts *RecipeSearchOpts, filter RecipeFilter, limit int) (RecipeFilterResults, error) {
+	var res RecipeFilterResults
+	if limit <= 0 {
+		limit = defaultRecipeOpts.ObjectsPerPage
+	}
+	search := RecipeSearchOpts{}
+	if opts != nil {
+		search = *opts
+	}
+	if search.Page <= 0 {
+		search.Page = 1
+	}
+
+	results, err := RecipeSearch(&search)
+	if err != nil {
+		return res, err
+	}
+
+	tags, err := NewRecipeFacets(results.Metadata).ValidateTags(filter.Tags)
+	if err != nil {
+		return res, err
+	}
+	var tagged []map[string]bool
+	for _, tag := range tags {
+		ids, complete, err := recipeIDsForTag(tag, search)
+		if err != nil {
+			return res, err
+		}
+		if !complete {
+			res.IncompleteTags = append(res.IncompleteTags, tag)
+		}
+		tagged = append(tagged, ids)
+	}
+
+	seen := 0
+	for {
+		for _, r := range filter.Apply(results.Recipes) {
+			if hasAllTags(r.ID, tagged) {
+				res.Recipes = append(res.Recipes, r)
+				if len(res.Recipes) == limit {
+					return res, nil
+				}
+			}
+		}
+
+		seen += len(results.Recipes)
+		if len(results.Recipes) == 0 || seen >= results.TotalCount {
+			return res, nil
+		}
+		search.Page++
+		results, err = RecipeSearch(&search)
+		if err != nil {
+			return res, err
+		}
+	}
+}
+
+// recipeIDsForTag collects the IDs of the recipes found when searching for a tag.
+// complete is false if the search had more than maxTagSearchPages pages.
+func recipeIDsForTag(tag string, base RecipeSearchOpts) (ids map[string]bool, complete bool, err error) {
+	search := RecipeSearchOpts{
+		SearchTerm:     tag,
+		Collection:     base.Collection,
+		Difficulty:     base.Difficulty,
+		ObjectsPerPage: 100,
+	}
+	ids = make(map[string]bool)
+	for page := 1; page <= maxTagSearchPages; page++ {
+		search.Page = page
+		res, err := RecipeSearch(&search)
+		if err != nil {
+			return nil, false, fmt.Errorf("error searching tag %q: %w", tag, err)
+		}
+		for _, r := range res.Recipes {
+			ids[r.ID] = true
+		}
+		if len(res.Recipes) == 0 || len(ids) >= res.TotalCount {
+			return ids, true, nil
+		}
+	}
+	return ids, false, nil
+}
+
+// findTag looks up a tag case-insensitively and returns its canonical spelling
+func findTag(tag string, available []string) (string, bool) {
+	for _, t := range available {
+		if strings.EqualFold(strings.TrimSpace(tag), t) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func hasAllTags(id string, tagged []map[string]bool) bool {
+	for _, ids := range tagged {
+		if !ids[id] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package rewerse
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRecipeDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"50 min", 50 * time.Minute, true},
+		{"90 min", 90 * time.Minute, true},
+		{"1 Std. 20 min", 80 * time.Minute, true},
+		{"2 Std.", 2 * time.Hour, true},
+		{"", 0, false},
+		{"schnell", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRecipeDuration(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseRecipeDuration(%q) = %v, %v; want %v, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+
+	if got := isoDuration(80 * time.Minute); got != "PT1H20M" {
+		t.Errorf("isoDuration: expected PT1H20M, got %s", got)
+	}
+}
+
+func TestRecipeFilter(t *testing.T) {
+	recipes := []Recipe{
+		{ID: "a", Duration: "25 min", DifficultyLevel: 1},
+		{ID: "b", Duration: "1 Std. 10 min", DifficultyLevel: 1},
+		{ID: "c", Duration: "30 min", DifficultyLevel: 3},
+		{ID: "d", Duration: "", DifficultyLevel: 1},
+	}
+	setCookingTimes(recipes)
+
+	tests := []struct {
+		name   string
+		filter RecipeFilter
+		want   []string
+	}{
+		{"no filter", RecipeFilter{}, []string{"a", "b", "c", "d"}},
+		{"max duration", RecipeFilter{MaxDuration: 30 * time.Minute}, []string{"a", "c"}},
+		{"max difficulty", RecipeFilter{MaxDifficultyLevel: 2}, []string{"a", "b", "d"}},
+		{"both", RecipeFilter{MaxDuration: 30 * time.Minute, MaxDifficultyLevel: 1}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		got := tt.filter.Apply(recipes)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %d recipes", tt.name, tt.want, len(got))
+			continue
+		}
+		for i, r := range got {
+			if r.ID != tt.want[i] {
+				t.Errorf("%s: expected %v, got %s at %d", tt.name, tt.want, r.ID, i)
+			}
+		}
+	}
+}
+
+func TestFindTag(t *testing.T) {
+	available := []string{"Geringer Aufwand", "schnell", "Vegan"}
+	if got, ok := findTag("vegan", available); !ok || got != "Vegan" {
+		t.Errorf("vegan: expected Vegan, got %q, %v", got, ok)
+	}
+	if _, ok := findTag("Dessert", available); ok {
+		t.Error("Dessert: expected no match")
+	}
+}
+
+func TestRecipeFilterResults(t *testing.T) {
+	recipes := []Recipe{{ID: "a", Title: "Pasta", Duration: "25 min"}}
+	setCookingTimes(recipes)
+	res := RecipeFilterResults{Recipes: recipes, IncompleteTags: []string{"schnell"}}
+
+	if !strings.Contains(res.String(), "search for schnell was cut off") {
+		t.Errorf("missing cut-off note:\n%s", res)
+	}
+	data, err := json.Marshal(res)
+	if err != nil || !strings.Contains(string(data), `"cookingTime":1500000000000`) {
+		t.Errorf("expected cookingTime in JSON, got %s (%v)", data, err)
+	}
+}
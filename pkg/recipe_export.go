@@ -0,0 +1,283 @@
+package rewerse
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecipeExportFormat is an export format for recipes
+type RecipeExportFormat string
+
+const (
+	// ExportJSONLD is schema.org Recipe JSON-LD. Mealie imports this format directly.
+	ExportJSONLD RecipeExportFormat = "jsonld"
+	// ExportMarkdown is a human-readable Markdown document
+	ExportMarkdown RecipeExportFormat = "md"
+	// ExportPaprika is the Paprika Recipe Manager format: a gzipped .paprikarecipe file,
+	// or a .paprikarecipes zip archive for several recipes
+	ExportPaprika RecipeExportFormat = "paprika"
+)
+
+// ParseRecipeExportFormat validates a format name. "mealie" is accepted as alias for jsonld.
+func ParseRecipeExportFormat(s string) (RecipeExportFormat, error) {
+	switch strings.ToLower(s) {
+	case "jsonld", "json-ld", "mealie":
+		return ExportJSONLD, nil
+	case "md", "markdown":
+		return ExportMarkdown, nil
+	case "paprika":
+		return ExportPaprika, nil
+	}
+	return "", fmt.Errorf("invalid export format: %q (must be jsonld, md or paprika)", s)
+}
+
+// schemaRecipe is the schema.org Recipe subset we can fill
+type schemaRecipe struct {
+	Context            string       `json:"@context"`
+	Type               string       `json:"@type"`
+	Name               string       `json:"name"`
+	Description        string       `json:"description,omitempty"`
+	Image              string       `json:"image,omitempty"`
+	URL                string       `json:"url,omitempty"`
+	TotalTime          string       `json:"totalTime,omitempty"`
+	RecipeYield        string       `json:"recipeYield,omitempty"`
+	RecipeIngredient   []string     `json:"recipeIngredient"`
+	RecipeInstructions []schemaStep `json:"recipeInstructions"`
+}
+
+type schemaStep struct {
+	Type string `json:"@type"`
+	Text string `json:"text"`
+}
+
+// paprikaRecipe is the JSON payload of a .paprikarecipe file
+type paprikaRecipe struct {
+	UID         string `json:"uid"`
+	Name        string `json:"name"`
+	Ingredients string `json:"ingredients"`
+	Directions  string `json:"directions"`
+	Servings    string `json:"servings"`
+	TotalTime   string `json:"total_time"`
+	Difficulty  string `json:"difficulty"`
+	Source      string `json:"source"`
+	SourceURL   string `json:"source_url"`
+	ImageURL    string `json:"image_url"`
+}
+
+// JSONLD returns the recipe as schema.org Recipe JSON-LD.
+// schema.org has no difficulty property, so it is part of the description.
+func (r RecipeDetail) JSONLD() ([]byte, error) {
+	return json.MarshalIndent(r.schema(), "", "  ")
+}
+
+func (r RecipeDetail) schema() schemaRecipe {
+	sr := schemaRecipe{
+		Context:            "https://schema.org",
+		Type:               "Recipe",
+		Name:               r.Title,
+		Image:              r.ImageURL,
+		URL:                r.DetailURL,
+		RecipeIngredient:   r.ingredientLines(),
+		RecipeInstructions: make([]schemaStep, len(r.Steps)),
+	}
+	if r.DifficultyDescription != "" {
+		sr.Description = "Schwierigkeit: " + r.DifficultyDescription
+	}
+	if d, err := ParseRecipeDuration(r.Duration); err == nil {
+		sr.TotalTime = isoDuration(d)
+	}
+	if r.Ingredients.Portions > 0 {
+		sr.RecipeYield = fmt.Sprintf("%d Portionen", r.Ingredients.Portions)
+	}
+	for i, step := range r.Steps {
+		sr.RecipeInstructions[i] = schemaStep{Type: "HowToStep", Text: step}
+	}
+	return sr
+}
+
+// Markdown returns the recipe as a Markdown document
+func (r RecipeDetail) Markdown() string {
+	var sb strings.Builder
+
+	sb.WriteString("# ")
+	sb.WriteString(r.Title)
+	sb.WriteString("\n\n")
+	if r.ImageURL != "" {
+		sb.WriteString(fmt.Sprintf("![%s](%s)\n\n", r.Title, r.ImageURL))
+	}
+
+	var meta []string
+	if r.Duration != "" {
+		meta = append(meta, "**Dauer:** "+r.Duration)
+	}
+	if r.DifficultyDescription != "" {
+		meta = append(meta, "**Schwierigkeit:** "+r.DifficultyDescription)
+	}
+	if r.Ingredients.Portions > 0 {
+		meta = append(meta, fmt.Sprintf("**Portionen:** %d", r.Ingredients.Portions))
+	}
+	if len(meta) > 0 {
+		sb.WriteString(strings.Join(meta, " · "))
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("## Zutaten\n\n")
+	for _, line := range r.ingredientLines() {
+		sb.WriteString("- ")
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteString("\n## Zubereitung\n\n")
+	for i, step := range r.Steps {
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, step))
+	}
+
+	if r.DetailURL != "" {
+		sb.WriteString(fmt.Sprintf("\nQuelle: <%s>\n", r.DetailURL))
+	}
+	return sb.String()
+}
+
+// Paprika returns the recipe as a gzipped .paprikarecipe file
+func (r RecipeDetail) Paprika() ([]byte, error) {
+	data, err := json.Marshal(r.paprika())
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling recipe: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, fmt.Errorf("error compressing recipe: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing recipe: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r RecipeDetail) paprika() paprikaRecipe {
+	steps := make([]string, len(r.Steps))
+	for i, step := range r.Steps {
+		steps[i] = fmt.Sprintf("%d. %s", i+1, step)
+	}
+
+	pr := paprikaRecipe{
+		UID:         strings.ToUpper(r.ID),
+		Name:        r.Title,
+		Ingredients: strings.Join(r.ingredientLines(), "\n"),
+		Directions:  strings.Join(steps, "\n\n"),
+		TotalTime:   r.Duration,
+		Difficulty:  r.DifficultyDescription,
+		Source:      "REWE",
+		SourceURL:   r.DetailURL,
+		ImageURL:    r.ImageURL,
+	}
+	if r.Ingredients.Portions > 0 {
+		pr.Servings = fmt.Sprintf("%d Portionen", r.Ingredients.Portions)
+	}
+	return pr
+}
+
+func (r RecipeDetail) ingredientLines() []string {
+	lines := make([]string, len(r.Ingredients.Items))
+	for i, ing := range r.Ingredients.Items {
+		lines[i] = ing.String()
+	}
+	return lines
+}
+
+// ExportRecipes writes recipes in the given format:
+//   - jsonld: a single recipe object, or an array for several recipes
+//   - md: the Markdown documents separated by horizontal rules
+//   - paprika: a .paprikarecipe file for a single recipe, or a .paprikarecipes zip archive
+func ExportRecipes(w io.Writer, recipes []RecipeDetail, format RecipeExportFormat) error {
+	if len(recipes) == 0 {
+		return fmt.Errorf("no recipes to export")
+	}
+
+	switch format {
+	case ExportJSONLD:
+		var v any = recipes[0].schema()
+		if len(recipes) > 1 {
+			all := make([]schemaRecipe, len(recipes))
+			for i, r := range recipes {
+				all[i] = r.schema()
+			}
+			v = all
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling recipes: %w", err)
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+
+	case ExportMarkdown:
+		for i, r := range recipes {
+			if i > 0 {
+				if _, err := io.WriteString(w, "\n---\n\n"); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, r.Markdown()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ExportPaprika:
+		if len(recipes) == 1 {
+			data, err := recipes[0].Paprika()
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		return writePaprikaArchive(w, recipes)
+	}
+
+	return fmt.Errorf("invalid export format: %q", format)
+}
+
+// writePaprikaArchive writes a .paprikarecipes archive: a zip of .paprikarecipe files
+func writePaprikaArchive(w io.Writer, recipes []RecipeDetail) (err error) {
+	zw := zip.NewWriter(w)
+	defer CloseWithWrap(zw, &err)
+
+	for i, r := range recipes {
+		data, err := r.Paprika()
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%03d %s.paprikarecipe", i+1, strings.ReplaceAll(r.Title, "/", "-"))
+		f, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("error creating archive entry: %w", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return fmt.Errorf("error writing archive entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetRecipeDetailsAll fetches the details of every recipe, e.g. of a RecipeSearch result page
+func GetRecipeDetailsAll(recipes []Recipe) ([]RecipeDetail, error) {
+	details := make([]RecipeDetail, 0, len(recipes))
+	for _, r := range recipes {
+		d, err := GetRecipeDetails(r.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading recipe %s: %w", r.ID, err)
+		}
+		details = append(details, d.Recipe)
+	}
+	return details, nil
+}
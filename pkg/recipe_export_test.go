@@ -0,0 +1,131 @@
+package rewerse
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecipeJSONLD(t *testing.T) {
+	var res RecipeDetails
+	loadJSONFixture(t, "recipe_details.json", &res)
+	r := res.Recipe
+
+	data, err := r.JSONLD()
+	if err != nil {
+		t.Fatalf("JSONLD failed: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if got["@type"] != "Recipe" || got["@context"] != "https://schema.org" {
+		t.Errorf("unexpected type/context: %v / %v", got["@type"], got["@context"])
+	}
+	if got["name"] != r.Title {
+		t.Errorf("name: expected %q, got %v", r.Title, got["name"])
+	}
+	if got["totalTime"] != "PT50M" {
+		t.Errorf("totalTime: expected PT50M, got %v", got["totalTime"])
+	}
+	if got["recipeYield"] != "2 Portionen" {
+		t.Errorf("recipeYield: expected 2 Portionen, got %v", got["recipeYield"])
+	}
+	if n := len(got["recipeIngredient"].([]any)); n != len(r.Ingredients.Items) {
+		t.Errorf("recipeIngredient: expected %d, got %d", len(r.Ingredients.Items), n)
+	}
+	if n := len(got["recipeInstructions"].([]any)); n != len(r.Steps) {
+		t.Errorf("recipeInstructions: expected %d, got %d", len(r.Steps), n)
+	}
+}
+
+func TestRecipeMarkdown(t *testing.T) {
+	var res RecipeDetails
+	loadJSONFixture(t, "recipe_details.json", &res)
+	r := res.Recipe
+	md := r.Markdown()
+
+	for _, want := range []string{
+		"# " + r.Title,
+		"**Dauer:** 50 min",
+		"**Schwierigkeit:** Einfach",
+		"## Zutaten",
+		"- " + r.Ingredients.Items[0].String(),
+		"1. " + r.Steps[0],
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q", want)
+		}
+	}
+}
+
+func TestExportRecipesPaprika(t *testing.T) {
+	var res RecipeDetails
+	loadJSONFixture(t, "recipe_details.json", &res)
+	r := res.Recipe
+
+	// single recipe: gzipped JSON
+	var buf bytes.Buffer
+	if err := ExportRecipes(&buf, []RecipeDetail{r}, ExportPaprika); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	got := readPaprika(t, buf.Bytes())
+	if got.Name != r.Title || got.Servings != "2 Portionen" || got.Difficulty != "Einfach" {
+		t.Errorf("unexpected recipe: %+v", got)
+	}
+	if n := strings.Count(got.Ingredients, "\n") + 1; n != len(r.Ingredients.Items) {
+		t.Errorf("ingredients: expected %d lines, got %d", len(r.Ingredients.Items), n)
+	}
+
+	// several recipes: zip of gzipped JSON files
+	buf.Reset()
+	if err := ExportRecipes(&buf, []RecipeDetail{r, r}, ExportPaprika); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid archive: %v", err)
+	}
+	if len(zr.File) != 2 {
+		t.Fatalf("expected 2 archive entries, got %d", len(zr.File))
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatalf("open entry: %v", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if got := readPaprika(t, data); got.Name != r.Title {
+		t.Errorf("archive entry: expected %q, got %q", r.Title, got.Name)
+	}
+}
+
+func readPaprika(t *testing.T, data []byte) paprikaRecipe {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("not gzipped: %v", err)
+	}
+	var pr paprikaRecipe
+	if err := json.NewDecoder(gz).Decode(&pr); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return pr
+}
+
+func TestParseRecipeExportFormat(t *testing.T) {
+	if f, err := ParseRecipeExportFormat("mealie"); err != nil || f != ExportJSONLD {
+		t.Errorf("mealie: expected jsonld, got %q, %v", f, err)
+	}
+	if _, err := ParseRecipeExportFormat("pdf"); err == nil {
+		t.Error("pdf: expected error")
+	}
+}
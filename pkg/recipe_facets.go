@@ -0,0 +1,104 @@
+package rewerse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RecipeFacets are the valid filter values for RecipeSearch. Discovering sortings is not
+// implemented: they are not part of the search metadata, and no endpoint listing them is
+// known. The RecipeSorting constants are the only known values.
+type RecipeFacets struct {
+	Collections []string `json:"collections"`
+	// Tags can only be filtered client-side, see RecipeFilter.Tags
+	Tags         []string `json:"tags"`
+	Difficulties []string `json:"difficulties"`
+}
+
+func (f RecipeFacets) String() string {
+	var sb strings.Builder
+
+	for _, facet := range []struct {
+		title  string
+		values []string
+	}{
+		{"Collections", f.Collections},
+		{"Tags", f.Tags},
+		{"Difficulties", f.Difficulties},
+	} {
+		sb.WriteString(sep(fmt.Sprintf("%s (%d)", facet.title, len(facet.values))))
+		sb.WriteByte('\n')
+		for _, v := range facet.values {
+			sb.WriteString("   ")
+			sb.WriteString(v)
+			sb.WriteByte('\n')
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+// GetRecipeFacets discovers the valid collections, tags and difficulties from the
+// metadata of an unfiltered recipe search
+func GetRecipeFacets() (RecipeFacets, error) {
+	res, err := RecipeSearch(&RecipeSearchOpts{ObjectsPerPage: 1})
+	if err != nil {
+		return RecipeFacets{}, err
+	}
+	return NewRecipeFacets(res.Metadata), nil
+}
+
+// NewRecipeFacets builds the facets from search metadata
+func NewRecipeFacets(m RecipeMetadata) RecipeFacets {
+	return RecipeFacets{
+		Collections:  m.Collections,
+		Tags:         m.Tags,
+		Difficulties: m.Difficulties,
+	}
+}
+
+// Validate checks the collection and difficulty of opts against the facets. Values are
+// matched case-insensitively; the returned copy of opts has their canonical spelling.
+// The sorting isn't checked, see RecipeFacets.
+func (f RecipeFacets) Validate(opts RecipeSearchOpts) (RecipeSearchOpts, error) {
+	if opts.Collection != "" {
+		v, err := matchFacet("collection", string(opts.Collection), f.Collections)
+		if err != nil {
+			return opts, err
+		}
+		opts.Collection = RecipeCollection(v)
+	}
+	if opts.Difficulty != "" {
+		v, err := matchFacet("difficulty", string(opts.Difficulty), f.Difficulties)
+		if err != nil {
+			return opts, err
+		}
+		opts.Difficulty = RecipeDifficulty(v)
+	}
+	return opts, nil
+}
+
+// ValidateTags checks tags for RecipeFilter.Tags against the facets and returns them in
+// their canonical spelling
+func (f RecipeFacets) ValidateTags(tags []string) ([]string, error) {
+	canonical := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		v, ok := findTag(tag, f.Tags)
+		if !ok {
+			return nil, fmt.Errorf("invalid recipe tag: %q (valid: %s)", tag, strings.Join(f.Tags, ", "))
+		}
+		canonical = append(canonical, v)
+	}
+	return canonical, nil
+}
+
+// matchFacet returns the canonical spelling of value or an error listing the valid values
+func matchFacet(name, value string, valid []string) (string, error) {
+	for _, v := range valid {
+		if strings.EqualFold(strings.TrimSpace(value), v) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("invalid recipe %s: %q (valid: %s)", name, value, strings.Join(valid, ", "))
+}
@@ -0,0 +1,44 @@
+package rewerse
+
+import (
+	"testing"
+)
+
+func TestRecipeFacetsValidate(t *testing.T) {
+	var res RecipeSearchResults
+	loadJSONFixture(t, "recipe_search.json", &res)
+	f := NewRecipeFacets(res.Metadata)
+	if len(f.Tags) != 20 || len(f.Collections) != 1 || len(f.Difficulties) != 3 {
+		t.Fatalf("unexpected facets: %+v", f)
+	}
+
+	opts := RecipeSearchOpts{Collection: "vegetarisch", Difficulty: "mittel"}
+	valid, err := f.Validate(opts)
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if valid.Collection != CollectionVegetarisch || valid.Difficulty != DifficultyMedium {
+		t.Errorf("expected canonical spelling, got %q / %q", valid.Collection, valid.Difficulty)
+	}
+	if opts.Collection != "vegetarisch" {
+		t.Errorf("input was modified: %q", opts.Collection)
+	}
+
+	for _, bad := range []RecipeSearchOpts{
+		{Collection: "Dessert"},
+		{Difficulty: "Einfach"},
+	} {
+		if _, err := f.Validate(bad); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+
+	input := []string{"VEGAN", "schnell"}
+	tags, err := f.ValidateTags(input)
+	if err != nil || tags[0] != "Vegan" || tags[1] != "schnell" || input[0] != "VEGAN" {
+		t.Errorf("unexpected tags: %v (input %v, %v)", tags, input, err)
+	}
+	if _, err := f.ValidateTags([]string{"Weihnachten"}); err == nil {
+		t.Error("expected error for unknown tag")
+	}
+}
@@ -0,0 +1,279 @@
+package rewerse
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ingredientNoiseRegex strips parentheticals and trailing remarks from ingredient names:
+// "frischer Rosenkohl (ersatzweise TK)" -> "frischer Rosenkohl"
+var ingredientNoiseRegex = regexp.MustCompile(`\s*\([^)]*\)|,.*$`)
+
+// defaultPantryItems are ingredients that are assumed to be at home and not searched
+var defaultPantryItems = []string{"Wasser", "Salz", "Pfeffer", "Salz und Pfeffer"}
+
+// RecipeShopOpts configures ProposeRecipeProducts
+type RecipeShopOpts struct {
+	// Portions is the number of servings to shop for (default: the recipe's portions)
+	Portions int
+	// ServiceType must match market capabilities (default: PICKUP)
+	ServiceType ServiceType
+	// Candidates is the number of search results ranked per ingredient (default 10)
+	Candidates int
+	// Pantry lists ingredient names that are skipped (default: Wasser, Salz, Pfeffer).
+	// Matching is case-insensitive on the cleaned ingredient name.
+	Pantry []string
+}
+
+// IngredientProduct is a recipe ingredient together with the product chosen for it
+type IngredientProduct struct {
+	// Ingredient is the ingredient, scaled to the requested portions
+	Ingredient RecipeIngredient
+	// Query is the search term used for the ingredient
+	Query string
+	// Product is the chosen product (nil if unresolved)
+	Product *Product
+	// Packs is the number of packs needed to cover the ingredient
+	Packs int
+	// Cost is Packs * price in euros
+	Cost float64
+	// Alternatives are the next best ranked candidates
+	Alternatives []Product
+	// Reason explains why the ingredient was skipped or not resolved
+	Reason string
+}
+
+// RecipeShoppingProposal is the product list proposed for a recipe
+type RecipeShoppingProposal struct {
+	RecipeID    string
+	RecipeTitle string
+	MarketID    string
+	Portions    int
+	// Items are the resolved ingredients
+	Items []IngredientProduct
+	// Skipped are pantry ingredients that were not searched
+	Skipped []IngredientProduct
+	// Unresolved are ingredients without a matching product
+	Unresolved []IngredientProduct
+	// TotalCost is the sum of all item costs in euros
+	TotalCost float64
+}
+
+func (p RecipeShoppingProposal) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep(fmt.Sprintf("%s (%d Portionen)", p.RecipeTitle, p.Portions)))
+	sb.WriteByte('\n')
+	for _, it := range p.Items {
+		sb.WriteString(fmt.Sprintf("   %s\n", it.Ingredient.String()))
+		sb.WriteString(alignL(fmt.Sprintf("%dx %s, %s (%.2f €)", it.Packs, it.Product.Title, it.Product.Listing.Grammage, it.Cost), 2))
+		sb.WriteByte('\n')
+	}
+
+	if len(p.Unresolved) > 0 {
+		sb.WriteByte('\n')
+		sb.WriteString(sep("Nicht gefunden"))
+		sb.WriteByte('\n')
+		for _, it := range p.Unresolved {
+			sb.WriteString(fmt.Sprintf("   %s: %s\n", it.Ingredient.Name, it.Reason))
+		}
+	}
+
+	if len(p.Skipped) > 0 {
+		sb.WriteByte('\n')
+		sb.WriteString(sep("Vorrat"))
+		sb.WriteByte('\n')
+		for _, it := range p.Skipped {
+			sb.WriteString("   ")
+			sb.WriteString(it.Ingredient.Name)
+			sb.WriteByte('\n')
+		}
+	}
+
+	sb.WriteByte('\n')
+	sb.WriteString(align("Gesamt"))
+	sb.WriteString(fmt.Sprintf("%.2f €", p.TotalCost))
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// BasketItems returns the proposal as listing ID -> quantity, suitable for ApplyItems.
+// Products used for several ingredients are summed up.
+func (p RecipeShoppingProposal) BasketItems() map[string]int {
+	items := make(map[string]int)
+	for _, it := range p.Items {
+		items[it.Product.Listing.ListingID] += it.Packs
+	}
+	return items
+}
+
+// AddToBasket puts all proposed products into the basket.
+// Quantities already in the basket are overwritten, not added to.
+func (p RecipeShoppingProposal) AddToBasket(s *BasketSession) (Basket, error) {
+	if s.MarketID != p.MarketID {
+		return Basket{}, fmt.Errorf("proposal is for market %s, basket is for market %s", p.MarketID, s.MarketID)
+	}
+	return s.ApplyItems(p.BasketItems())
+}
+
+// ProposeRecipeProducts scales the recipe to the requested portions and searches every
+// ingredient with GetProducts in the given market. Relevant candidates are ranked by the
+// cost of the packs needed to cover the ingredient; ties are broken by the leftover amount.
+// Products whose pack size can't be compared with the ingredient count as one pack.
+func ProposeRecipeProducts(recipe RecipeDetail, marketID string, opts *RecipeShopOpts) (RecipeShoppingProposal, error) {
+	if marketID == "" {
+		return RecipeShoppingProposal{}, fmt.Errorf("marketID: cannot be empty")
+	}
+	if opts == nil {
+		opts = &RecipeShopOpts{}
+	}
+	portions := opts.Portions
+	if portions <= 0 {
+		portions = recipe.Ingredients.Portions
+	}
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = 10
+	}
+	pantry := opts.Pantry
+	if pantry == nil {
+		pantry = defaultPantryItems
+	}
+
+	p := RecipeShoppingProposal{
+		RecipeID:    recipe.ID,
+		RecipeTitle: recipe.Title,
+		MarketID:    marketID,
+		Portions:    portions,
+	}
+
+	for _, ing := range recipe.Scale(portions).Ingredients.Items {
+		it := IngredientProduct{Ingredient: ing, Query: ingredientQuery(ing.Name)}
+
+		if isPantryItem(it.Query, pantry) {
+			it.Reason = "pantry item"
+			p.Skipped = append(p.Skipped, it)
+			continue
+		}
+
+		res, err := GetProducts(marketID, it.Query, &ProductOpts{
+			ObjectsPerPage: candidates,
+			ServiceType:    opts.ServiceType,
+		})
+		if err != nil {
+			it.Reason = err.Error()
+			p.Unresolved = append(p.Unresolved, it)
+			continue
+		}
+		if len(res.Products) == 0 {
+			it.Reason = "no products found"
+			p.Unresolved = append(p.Unresolved, it)
+			continue
+		}
+
+		ranked := rankIngredientProducts(ing, it.Query, res.Products)
+		best := ranked[0]
+		it.Product = &best.product
+		it.Packs = best.packs
+		it.Cost = best.cost
+		for i := 1; i < len(ranked) && i <= 3; i++ {
+			it.Alternatives = append(it.Alternatives, ranked[i].product)
+		}
+
+		p.Items = append(p.Items, it)
+		p.TotalCost += it.Cost
+	}
+
+	p.TotalCost = math.Round(p.TotalCost*100) / 100
+	return p, nil
+}
+
+type rankedProduct struct {
+	product Product
+	packs   int
+	cost    float64
+	// leftover is the unused share of the bought amount (0 = perfect fit)
+	leftover float64
+	// comparable is true if the pack size could be compared with the ingredient
+	comparable bool
+	// relevant is true if the title contains the ingredient's main word
+	relevant bool
+}
+
+// rankIngredientProducts orders products by how well they cover the ingredient.
+// Products whose title contains the main word of the query come first (the search also
+// returns loosely related products), then products with a comparable pack size,
+// then cost and leftover.
+func rankIngredientProducts(ing RecipeIngredient, query string, products []Product) []rankedProduct {
+	needed, needDim, hasNeed := ingredientAmount(ing)
+
+	// German ingredient names put the noun last: "frischer Rosenkohl"
+	var mainWord string
+	if words := strings.Fields(strings.ToLower(query)); len(words) > 0 {
+		mainWord = words[len(words)-1]
+	}
+
+	ranked := make([]rankedProduct, 0, len(products))
+	for _, prod := range products {
+		r := rankedProduct{product: prod, packs: 1}
+		r.relevant = mainWord != "" && strings.Contains(strings.ToLower(prod.Title), mainWord)
+
+		size, sizeDim, ok := parsePackSize(prod.Listing.Grammage)
+		if hasNeed && ok && sizeDim == needDim {
+			r.comparable = true
+			r.packs = int(math.Ceil(needed/size - 1e-9))
+			if r.packs < 1 {
+				r.packs = 1
+			}
+			bought := float64(r.packs) * size
+			r.leftover = (bought - needed) / bought
+		}
+		r.cost = float64(r.packs) * centsToEuros(prod.Listing.CurrentRetailPrice)
+		ranked = append(ranked, r)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.relevant != b.relevant {
+			return a.relevant
+		}
+		if a.comparable != b.comparable {
+			return a.comparable
+		}
+		if a.cost != b.cost {
+			return a.cost < b.cost
+		}
+		return a.leftover < b.leftover
+	})
+	return ranked
+}
+
+// ingredientAmount converts an ingredient quantity to the base unit of its dimension.
+// Ingredients without unit are counted in pieces, spoons are converted to milliliters.
+func ingredientAmount(ing RecipeIngredient) (amount float64, dim unitDim, ok bool) {
+	if ing.Quantity <= 0 {
+		return 0, dimUnknown, false
+	}
+	dim, factor, ok := unitBase(ing.Unit)
+	if !ok {
+		return 0, dimUnknown, false
+	}
+	return ing.Quantity * factor, dim, true
+}
+
+// ingredientQuery turns an ingredient name into a product search term
+func ingredientQuery(name string) string {
+	return strings.TrimSpace(ingredientNoiseRegex.ReplaceAllString(name, ""))
+}
+
+func isPantryItem(query string, pantry []string) bool {
+	for _, item := range pantry {
+		if strings.EqualFold(query, item) {
+			return true
+		}
+	}
+	return false
+}
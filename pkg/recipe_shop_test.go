@@ -0,0 +1,64 @@
+package rewerse
+
+import "testing"
+
+// testProduct returns a product with the listing fields used for ranking
+func testProduct(title, grammage string, price int, opts ...func(*Product)) Product {
+	var p Product
+	p.Title = title
+	p.Listing.Grammage = grammage
+	p.Listing.CurrentRetailPrice = price
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+func asVegan(p *Product)      { p.Attributes.IsVegan = true }
+func asGlutenFree(p *Product) { p.Attributes.IsGlutenFree = true }
+
+func TestParsePackSize(t *testing.T) {
+	tests := []struct {
+		grammage string
+		amount   float64
+		dim      unitDim
+		ok       bool
+	}{
+		{"150g (1 kg = 30,60 EUR)", 150, dimMass, true},
+		{"1kg", 1000, dimMass, true},
+		{"1,5 l (1 l = 0,66 EUR)", 1500, dimVolume, true},
+		{"4 x 125 g (1 kg = 5,98 EUR)", 500, dimMass, true},
+		{"6 Stück", 6, dimCount, true},
+		{"", 0, dimUnknown, false},
+	}
+
+	for _, tt := range tests {
+		amount, dim, ok := parsePackSize(tt.grammage)
+		if amount != tt.amount || dim != tt.dim || ok != tt.ok {
+			t.Errorf("parsePackSize(%q): expected (%v, %v, %v), got (%v, %v, %v)",
+				tt.grammage, tt.amount, tt.dim, tt.ok, amount, dim, ok)
+		}
+	}
+}
+
+func TestRankIngredientProducts(t *testing.T) {
+	products := []Product{
+		testProduct("Rosenkohl-Chips", "100g", 99),
+		testProduct("Rosenkohl 500g", "500g (1 kg = 3,98 EUR)", 199),
+		testProduct("Rosenkohl 250g", "250g (1 kg = 5,96 EUR)", 149),
+	}
+
+	// 600g are needed: 2x 500g cost 3.98, 3x 250g cost 4.47, 6x 100g cost 5.94
+	ing := RecipeIngredient{Name: "frischer Rosenkohl", Quantity: 600, Unit: "g"}
+	ranked := rankIngredientProducts(ing, "frischer Rosenkohl", products)
+	if ranked[0].product.Title != "Rosenkohl 500g" || ranked[0].packs != 2 {
+		t.Errorf("expected 2x Rosenkohl 500g first, got %dx %s", ranked[0].packs, ranked[0].product.Title)
+	}
+	if ranked[len(ranked)-1].product.Title != "Rosenkohl-Chips" {
+		t.Errorf("expected chips last, got %s", ranked[len(ranked)-1].product.Title)
+	}
+
+	if q := ingredientQuery("frischer Rosenkohl (ersatzweise TK)"); q != "frischer Rosenkohl" {
+		t.Errorf("ingredientQuery: expected %q, got %q", "frischer Rosenkohl", q)
+	}
+}
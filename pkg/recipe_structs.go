@@ -1,11 +1,14 @@
 package rewerse
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // RecipeSorting defines sorting options for recipe search
 type RecipeSorting string
 
-// Probably not exhaustive
+// Probably not exhaustive - the API does not list sortings
 
 const (
 	SortRelevance RecipeSorting = "RELEVANCE_DESC"
@@ -14,7 +17,7 @@ const (
 // RecipeCollection defines recipe collection filters
 type RecipeCollection string
 
-// Not exhaustive - other collections may exist
+// Not exhaustive - other collections may exist, GetRecipeFacets lists the current ones
 
 const (
 	CollectionVegetarisch RecipeCollection = "Vegetarisch"
@@ -29,7 +32,9 @@ const (
 	DifficultyHard   RecipeDifficulty = "Hoch"
 )
 
-// RecipeSearchOpts contains options for recipe search
+// RecipeSearchOpts contains options for recipe search.
+// There is no tag filter: the query parameter for tags is unknown, and a guessed "tags"
+// parameter could not be verified. Use SearchRecipesFiltered to approximate it.
 type RecipeSearchOpts struct {
 	// SearchTerm is the text query to search for
 	SearchTerm string
@@ -96,6 +101,8 @@ type RecipeDetail struct {
 	ImageURL string `json:"imageUrl"`
 	// Duration is the cooking time: "50 min"
 	Duration string `json:"duration"`
+	// CookingTime is Duration parsed (0 if it can't be parsed), in nanoseconds in JSON
+	CookingTime time.Duration `json:"cookingTime"`
 	// DifficultyLevel is numeric difficulty: 1=easy, 2=medium, 3=hard
 	DifficultyLevel int `json:"difficultyLevel"`
 	// DifficultyDescription is human-readable: "Einfach", "Mittel", "Schwer"
@@ -106,6 +113,27 @@ type RecipeDetail struct {
 	Steps []string `json:"steps"`
 }
 
+// Scale returns a copy of the recipe with all ingredient quantities scaled to the given
+// number of portions. Quantities are rounded to kitchen-friendly values (whole grams,
+// common fractions for spoons and pieces). Ingredients without quantity stay as they are.
+func (r RecipeDetail) Scale(portions int) RecipeDetail {
+	if portions <= 0 || r.Ingredients.Portions <= 0 || portions == r.Ingredients.Portions {
+		return r
+	}
+
+	factor := float64(portions) / float64(r.Ingredients.Portions)
+	items := make([]RecipeIngredient, len(r.Ingredients.Items))
+	for i, ing := range r.Ingredients.Items {
+		if ing.Quantity > 0 {
+			ing.Quantity = roundQuantity(ing.Quantity*factor, ing.Unit)
+		}
+		items[i] = ing
+	}
+
+	r.Ingredients = RecipeIngredients{Portions: portions, Items: items}
+	return r
+}
+
 func (r RecipeDetail) StringFull() string {
 	s := fmt.Sprintf("%s\n", r.Title)
 	s += fmt.Sprintf("  Dauer: %s, Schwierigkeit: %s\n", r.Duration, r.DifficultyDescription)
@@ -113,11 +141,7 @@ func (r RecipeDetail) StringFull() string {
 
 	s += fmt.Sprintf("Zutaten (für %d Portionen):\n", r.Ingredients.Portions)
 	for _, ing := range r.Ingredients.Items {
-		if ing.Quantity > 0 {
-			s += fmt.Sprintf("  - %.0f %s %s\n", ing.Quantity, ing.Unit, ing.Name)
-		} else {
-			s += fmt.Sprintf("  - %s\n", ing.Name)
-		}
+		s += fmt.Sprintf("  - %s\n", ing.String())
 	}
 
 	s += "\nZubereitung:\n"
@@ -146,6 +170,18 @@ type RecipeIngredient struct {
 	Unit string `json:"unit"`
 }
 
+// String renders the ingredient with a readable quantity: "1½ EL Olivenöl", "2 Zehen Knoblauch", "1,5 kg Mehl"
+func (ri RecipeIngredient) String() string {
+	if ri.Quantity <= 0 {
+		return ri.Name
+	}
+	q, unit := NormalizeQuantity(ri.Quantity, ri.Unit)
+	if unit == "" {
+		return fmt.Sprintf("%s %s", FormatQuantity(q, unit), ri.Name)
+	}
+	return fmt.Sprintf("%s %s %s", FormatQuantity(q, unit), FormatUnit(q, unit), ri.Name)
+}
+
 // PopularSearchTerm is a suggested search term
 // Endpoint: GET /api/v3/recipe-popular-search-terms
 type PopularSearchTerm struct {
@@ -47,6 +47,11 @@ func RecipeSearch(opts *RecipeSearchOpts) (results RecipeSearchResults, err erro
 	}
 
 	err = DoRequest(req, &results)
+	if err != nil {
+		return
+	}
+
+	setCookingTimes(results.Recipes)
 	return
 }
 
@@ -62,6 +67,11 @@ func GetRecipeDetails(recipeID string) (details RecipeDetails, err error) {
 	}
 
 	err = DoRequest(req, &details)
+	if err != nil {
+		return
+	}
+
+	details.Recipe.CookingTime, _ = ParseRecipeDuration(details.Recipe.Duration)
 	return
 }
 
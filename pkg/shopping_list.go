@@ -0,0 +1,273 @@
+package rewerse
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// listCountRegex matches a leading count: "2x Milch", "2 x Milch", "2 Milch"
+	listCountRegex = regexp.MustCompile(`^(\d+)\s*[xX×]?\s+(.+)$|^(\d+)[xX×](.+)$`)
+	// listAmountRegex matches a trailing amount: "Bananen 1kg", "Mehl 500 g", "Milch 1,5 l"
+	listAmountRegex = regexp.MustCompile(`(?i)^(.+?)\s+(\d+(?:[.,]\d+)?\s*(?:kg|g|l|ml|stk\.?|stück))$`)
+	listNumberRegex = regexp.MustCompile(`^\d+$`)
+)
+
+// ShoppingListEntry is a single parsed line of a shopping list
+type ShoppingListEntry struct {
+	// Raw is the original text of the entry: "2x Milch"
+	Raw string
+	// Query is the product search term: "Milch"
+	Query string
+	// Quantity is the number of items to put into the basket (default 1)
+	Quantity int
+	// Amount is an optional weight/volume hint: "1kg", "500 g". It picks the pack size
+	// among the matching products.
+	Amount string
+}
+
+// ParseShoppingList parses a plain-text or CSV shopping list.
+// Entries are separated by newlines, commas or semicolons: "2x Milch, Bananen 1kg".
+// A comma between digits is a decimal separator and doesn't split: "Milch 1,5 l".
+// A field that consists only of a number is the quantity of the preceding field,
+// so CSV rows like "Milch;2" work as well. Empty fields and lines starting with # are ignored.
+func ParseShoppingList(text string) []ShoppingListEntry {
+	var entries []ShoppingListEntry
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, field := range splitListFields(line) {
+			field = strings.TrimSpace(strings.Trim(strings.TrimSpace(field), `"`))
+			if field == "" {
+				continue
+			}
+
+			if listNumberRegex.MatchString(field) && len(entries) > 0 {
+				if qty, err := strconv.Atoi(field); err == nil && qty > 0 {
+					entries[len(entries)-1].Quantity = qty
+				}
+				continue
+			}
+
+			entries = append(entries, parseShoppingListEntry(field))
+		}
+	}
+
+	return entries
+}
+
+// splitListFields splits a line at commas, semicolons and tabs, except for decimal commas
+func splitListFields(line string) []string {
+	var fields []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ',':
+			if i > 0 && i+1 < len(line) && isDigit(line[i-1]) && isDigit(line[i+1]) {
+				continue
+			}
+		case ';', '\t':
+		default:
+			continue
+		}
+		fields = append(fields, line[start:i])
+		start = i + 1
+	}
+	return append(fields, line[start:])
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func parseShoppingListEntry(s string) ShoppingListEntry {
+	e := ShoppingListEntry{Raw: s, Query: s, Quantity: 1}
+
+	if m := listCountRegex.FindStringSubmatch(e.Query); m != nil {
+		count, rest := m[1], m[2]
+		if count == "" {
+			count, rest = m[3], m[4]
+		}
+		if qty, err := strconv.Atoi(count); err == nil && qty > 0 {
+			e.Quantity = qty
+			e.Query = strings.TrimSpace(rest)
+		}
+	}
+
+	if m := listAmountRegex.FindStringSubmatch(e.Query); m != nil {
+		e.Query = strings.TrimSpace(m[1])
+		e.Amount = strings.TrimSpace(m[2])
+	}
+
+	return e
+}
+
+// ShoppingListItem is an entry that was resolved to a product
+type ShoppingListItem struct {
+	Entry   ShoppingListEntry
+	Product Product
+}
+
+// ShoppingListMiss is an entry that could not be resolved unambiguously
+type ShoppingListMiss struct {
+	Entry ShoppingListEntry
+	// Reason explains why the entry was not resolved
+	Reason string
+	// Candidates are the top search results, if any
+	Candidates []Product
+}
+
+// ShoppingListImport is the result of ImportShoppingList
+type ShoppingListImport struct {
+	// Resolved entries were added to the basket
+	Resolved []ShoppingListItem
+	// Ambiguous entries had search results, but none matched all words of the query
+	Ambiguous []ShoppingListMiss
+	// Unresolved entries had no search results or the search failed
+	Unresolved []ShoppingListMiss
+	// Basket is the basket state after the import
+	Basket Basket
+}
+
+func (si ShoppingListImport) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(sep(fmt.Sprintf("Gefunden (%d)", len(si.Resolved))))
+	sb.WriteByte('\n')
+	for _, r := range si.Resolved {
+		sb.WriteString(fmt.Sprintf("   %dx %s -> %s (%.2f €)\n", r.Entry.Quantity, r.Entry.Raw, r.Product.Title, float64(r.Product.Listing.CurrentRetailPrice)/100))
+	}
+
+	if len(si.Ambiguous) > 0 {
+		sb.WriteByte('\n')
+		sb.WriteString(sep(fmt.Sprintf("Mehrdeutig (%d)", len(si.Ambiguous))))
+		sb.WriteByte('\n')
+		for _, m := range si.Ambiguous {
+			sb.WriteString("   ")
+			sb.WriteString(m.Entry.Raw)
+			sb.WriteByte('\n')
+			for _, c := range m.Candidates {
+				sb.WriteString(alignL(c.Title+" ("+c.ProductID+")", 2))
+				sb.WriteByte('\n')
+			}
+		}
+	}
+
+	if len(si.Unresolved) > 0 {
+		sb.WriteByte('\n')
+		sb.WriteString(sep(fmt.Sprintf("Nicht gefunden (%d)", len(si.Unresolved))))
+		sb.WriteByte('\n')
+		for _, m := range si.Unresolved {
+			sb.WriteString(fmt.Sprintf("   %s: %s\n", m.Entry.Raw, m.Reason))
+		}
+	}
+
+	sb.WriteByte('\n')
+	sb.WriteString(si.Basket.String())
+	return sb.String()
+}
+
+// maxListCandidates is the number of search results considered per shopping list entry
+const maxListCandidates = 10
+
+// ImportShoppingList parses a shopping list, resolves every entry via GetProducts in the
+// session's market and fills the basket with ApplyItems. An entry is resolved to the
+// best-ranked search result whose title contains every word of the query; with an Amount,
+// the result with the closest pack size wins. Entries that only produced loose matches
+// are reported as ambiguous and are not added.
+func (s *BasketSession) ImportShoppingList(text string) (ShoppingListImport, error) {
+	var res ShoppingListImport
+
+	entries := ParseShoppingList(text)
+	if len(entries) == 0 {
+		return res, fmt.Errorf("shopping list is empty")
+	}
+
+	items := make(map[string]int)
+	for _, e := range entries {
+		pr, err := GetProducts(s.MarketID, e.Query, &ProductOpts{
+			ObjectsPerPage: maxListCandidates,
+			ServiceType:    s.ServiceType,
+		})
+		if err != nil {
+			res.Unresolved = append(res.Unresolved, ShoppingListMiss{Entry: e, Reason: err.Error()})
+			continue
+		}
+		if len(pr.Products) == 0 {
+			res.Unresolved = append(res.Unresolved, ShoppingListMiss{Entry: e, Reason: "no products found"})
+			continue
+		}
+
+		p, ok := matchListEntry(e, pr.Products)
+		if !ok {
+			res.Ambiguous = append(res.Ambiguous, ShoppingListMiss{
+				Entry:      e,
+				Reason:     "no product matches all words",
+				Candidates: pr.Products[:minInt(3, len(pr.Products))],
+			})
+			continue
+		}
+
+		res.Resolved = append(res.Resolved, ShoppingListItem{Entry: e, Product: p})
+		items[p.Listing.ListingID] += e.Quantity
+	}
+
+	// With nothing resolved, ApplyItems just fetches the current basket
+	basket, err := s.ApplyItems(items)
+	res.Basket = basket
+	return res, err
+}
+
+// matchListEntry returns the first product, in search order, whose title contains every
+// word of the query. If the entry has an Amount, the matching product with the closest
+// pack size of the same unit is preferred.
+func matchListEntry(e ShoppingListEntry, products []Product) (Product, bool) {
+	var matches []Product
+	words := strings.Fields(strings.ToLower(e.Query))
+	for _, p := range products {
+		title := strings.ToLower(p.Title)
+		matched := true
+		for _, w := range words {
+			if !strings.Contains(title, w) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, p)
+		}
+	}
+	if len(matches) == 0 {
+		return Product{}, false
+	}
+
+	want, err := ParseGrammage(e.Amount)
+	if e.Amount == "" || err != nil {
+		return matches[0], true
+	}
+	best, bestDiff := 0, -1.0
+	for i, p := range matches {
+		amount, dim, ok := parsePackSize(p.Listing.Grammage)
+		if !ok || dim != want.dim {
+			continue
+		}
+		if diff := math.Abs(amount - want.amount); bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	return matches[best], true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
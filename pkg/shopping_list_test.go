@@ -0,0 +1,66 @@
+package rewerse
+
+import "testing"
+
+func TestParseShoppingList(t *testing.T) {
+	text := "2x Milch, Bananen 1kg\n" +
+		"# comment\n" +
+		"\n" +
+		"Butter;3\n" +
+		"3 Joghurt\n" +
+		"Mehl 500 g\n" +
+		"4xEier\n" +
+		"Milch 1,5 l, Sahne"
+
+	expected := []ShoppingListEntry{
+		{Raw: "2x Milch", Query: "Milch", Quantity: 2},
+		{Raw: "Bananen 1kg", Query: "Bananen", Quantity: 1, Amount: "1kg"},
+		{Raw: "Butter", Query: "Butter", Quantity: 3},
+		{Raw: "3 Joghurt", Query: "Joghurt", Quantity: 3},
+		{Raw: "Mehl 500 g", Query: "Mehl", Quantity: 1, Amount: "500 g"},
+		{Raw: "4xEier", Query: "Eier", Quantity: 4},
+		{Raw: "Milch 1,5 l", Query: "Milch", Quantity: 1, Amount: "1,5 l"},
+		{Raw: "Sahne", Query: "Sahne", Quantity: 1},
+	}
+
+	entries := ParseShoppingList(text)
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, e := range expected {
+		if entries[i] != e {
+			t.Errorf("entry %d: expected %+v, got %+v", i, e, entries[i])
+		}
+	}
+}
+
+func TestMatchListEntry(t *testing.T) {
+	products := []Product{
+		testProduct("REWE Bio Hafermilch", "1l", 199),
+		testProduct("ja! Frische Vollmilch 3,5%", "1l", 119),
+		testProduct("ja! Frische Vollmilch 3,5%", "1,5l", 169),
+	}
+
+	if p, ok := matchListEntry(ShoppingListEntry{Query: "Frische Milch"}, products); !ok || p.Listing.Grammage != "1l" {
+		t.Errorf("expected the first full match, got %+v", p)
+	}
+	if p, ok := matchListEntry(ShoppingListEntry{Query: "Sojamilch"}, products); ok {
+		t.Errorf("expected no match for Sojamilch, got %+v", p)
+	}
+
+	// several products contain "milch": the best-ranked one is taken
+	if p, ok := matchListEntry(ShoppingListEntry{Query: "Milch"}, products); !ok || p.Title != products[0].Title {
+		t.Errorf("expected %q, got %+v", products[0].Title, p)
+	}
+	// the amount picks the pack size
+	if p, ok := matchListEntry(ShoppingListEntry{Query: "Vollmilch", Amount: "1,5 l"}, products); !ok || p.Listing.Grammage != "1,5l" {
+		t.Errorf("expected the 1,5l pack, got %+v", p)
+	}
+	if p, ok := matchListEntry(ShoppingListEntry{Query: "Milch", Amount: "1400 ml"}, products); !ok || p.Listing.Grammage != "1,5l" {
+		t.Errorf("expected the closest pack size, got %+v", p)
+	}
+	// an amount of another unit doesn't change the order
+	if p, ok := matchListEntry(ShoppingListEntry{Query: "Milch", Amount: "500 g"}, products); !ok || p.Title != products[0].Title {
+		t.Errorf("expected %q, got %+v", products[0].Title, p)
+	}
+}
@@ -0,0 +1,219 @@
+package rewerse
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Canonical units as used by the recipe API
+const (
+	UnitGram       = "g"
+	UnitKilogram   = "kg"
+	UnitMilliliter = "ml"
+	UnitLiter      = "l"
+	UnitEL         = "EL" // Esslöffel, approximated as 15 ml
+	UnitTL         = "TL" // Teelöffel, approximated as 5 ml
+	UnitPinch      = "Prise"
+)
+
+// unitAliases maps lowercase spellings to canonical units
+var unitAliases = map[string]string{
+	"g":          UnitGram,
+	"gr":         UnitGram,
+	"gramm":      UnitGram,
+	"kg":         UnitKilogram,
+	"kilogramm":  UnitKilogram,
+	"ml":         UnitMilliliter,
+	"milliliter": UnitMilliliter,
+	"l":          UnitLiter,
+	"liter":      UnitLiter,
+	"el":         UnitEL,
+	"esslöffel":  UnitEL,
+	"tl":         UnitTL,
+	"teelöffel":  UnitTL,
+	"prise":      UnitPinch,
+}
+
+// unitPluralRegex matches units with an optional plural suffix: "Zehe(n)", "Stiel(e)"
+var unitPluralRegex = regexp.MustCompile(`^(.+)\((\p{L}+)\)$`)
+
+// unitFractions are the fractions rendered as glyphs by FormatQuantity
+var unitFractions = []struct {
+	value float64
+	glyph string
+}{
+	{1.0 / 8, "⅛"},
+	{1.0 / 4, "¼"},
+	{1.0 / 3, "⅓"},
+	{1.0 / 2, "½"},
+	{2.0 / 3, "⅔"},
+	{3.0 / 4, "¾"},
+}
+
+// NormalizeUnit returns the canonical spelling of a unit: "Esslöffel" -> "EL", "Gramm" -> "g".
+// Units without a canonical form are returned trimmed but otherwise unchanged.
+func NormalizeUnit(unit string) string {
+	unit = strings.TrimSpace(unit)
+	if canonical, ok := unitAliases[strings.ToLower(strings.TrimSuffix(unit, "."))]; ok {
+		return canonical
+	}
+	return unit
+}
+
+// unitBase returns the dimension of a unit and its factor to the base unit (g, ml or pieces).
+// EL and TL are volume approximations. An empty unit counts pieces.
+func unitBase(unit string) (dim unitDim, factor float64, ok bool) {
+	switch NormalizeUnit(unit) {
+	case "":
+		return dimCount, 1, true
+	case UnitEL:
+		return dimVolume, 15, true
+	case UnitTL:
+		return dimVolume, 5, true
+	}
+	u, known := baseUnits[strings.ToLower(NormalizeUnit(unit))]
+	if !known {
+		return dimUnknown, 0, false
+	}
+	return u.dim, u.factor, true
+}
+
+// ConvertQuantity converts a quantity between units of the same dimension, e.g. g to kg
+// or EL to ml. Spoon measures are approximations (1 EL = 15 ml, 1 TL = 5 ml).
+func ConvertQuantity(quantity float64, from, to string) (float64, error) {
+	fromDim, fromFactor, ok := unitBase(from)
+	if !ok || fromDim == dimCount {
+		return 0, fmt.Errorf("cannot convert from unit %q", from)
+	}
+	toDim, toFactor, ok := unitBase(to)
+	if !ok || toDim == dimCount {
+		return 0, fmt.Errorf("cannot convert to unit %q", to)
+	}
+	if fromDim != toDim {
+		return 0, fmt.Errorf("cannot convert %s to %s: different dimensions", from, to)
+	}
+	return quantity * fromFactor / toFactor, nil
+}
+
+// NormalizeQuantity picks a readable unit for a quantity: 1500 g -> 1.5 kg,
+// 1200 ml -> 1.2 l, 3 TL -> 1 EL. Other units are returned normalized but unchanged.
+func NormalizeQuantity(quantity float64, unit string) (float64, string) {
+	unit = NormalizeUnit(unit)
+	switch unit {
+	case UnitGram:
+		if quantity >= 1000 {
+			return quantity / 1000, UnitKilogram
+		}
+	case UnitMilliliter:
+		if quantity >= 1000 {
+			return quantity / 1000, UnitLiter
+		}
+	case UnitKilogram:
+		if quantity < 1 {
+			return quantity * 1000, UnitGram
+		}
+	case UnitLiter:
+		if quantity < 1 {
+			return quantity * 1000, UnitMilliliter
+		}
+	case UnitTL:
+		// only switch if the result is a clean spoon measure
+		if el := quantity / 3; quantity >= 3 && isNearFraction(el) {
+			return el, UnitEL
+		}
+	}
+	return quantity, unit
+}
+
+// FormatQuantity renders a quantity in unit the way recipes print it: whole numbers as-is,
+// common fractions of counts and spoon measures as glyphs ("½", "1¼", "⅓") and everything
+// else with a German decimal comma ("2,4"). Metric units never get glyphs: 1.5 kg is "1,5".
+func FormatQuantity(quantity float64, unit string) string {
+	if quantity <= 0 {
+		return ""
+	}
+	switch NormalizeUnit(unit) {
+	case UnitGram, UnitKilogram, UnitMilliliter, UnitLiter:
+		s := strconv.FormatFloat(math.Round(quantity*100)/100, 'f', -1, 64)
+		return strings.Replace(s, ".", ",", 1)
+	}
+
+	whole := math.Floor(quantity)
+	frac := quantity - whole
+	if frac < 0.02 {
+		return strconv.Itoa(int(whole))
+	}
+	if frac > 0.98 {
+		return strconv.Itoa(int(whole) + 1)
+	}
+
+	for _, f := range unitFractions {
+		if math.Abs(frac-f.value) < 0.02 {
+			if whole == 0 {
+				return f.glyph
+			}
+			return strconv.Itoa(int(whole)) + f.glyph
+		}
+	}
+
+	s := strconv.FormatFloat(math.Round(quantity*10)/10, 'f', -1, 64)
+	return strings.Replace(s, ".", ",", 1)
+}
+
+// FormatUnit renders a unit for the given quantity, resolving plural markers:
+// "Zehe(n)" becomes "Zehe" for 1 and "Zehen" for 2.
+func FormatUnit(quantity float64, unit string) string {
+	m := unitPluralRegex.FindStringSubmatch(unit)
+	if m == nil {
+		return unit
+	}
+	if quantity > 0 && quantity <= 1 {
+		return m[1]
+	}
+	return m[1] + m[2]
+}
+
+// roundQuantity rounds scaled quantities to values that make sense in a kitchen:
+// whole grams/milliliters above 10, otherwise the nearest whole number or common fraction.
+func roundQuantity(quantity float64, unit string) float64 {
+	switch NormalizeUnit(unit) {
+	case UnitGram, UnitMilliliter:
+		if quantity >= 10 {
+			return math.Round(quantity)
+		}
+	}
+
+	whole := math.Floor(quantity)
+	frac := quantity - whole
+	best, bestDiff := 0.0, frac
+	if 1-frac < bestDiff {
+		best, bestDiff = 1, 1-frac
+	}
+	for _, f := range unitFractions {
+		if d := math.Abs(frac - f.value); d < bestDiff {
+			best, bestDiff = f.value, d
+		}
+	}
+
+	// never round a required ingredient away
+	if whole+best == 0 {
+		return unitFractions[0].value
+	}
+	return whole + best
+}
+
+func isNearFraction(q float64) bool {
+	frac := q - math.Floor(q)
+	if frac < 0.02 || frac > 0.98 {
+		return true
+	}
+	for _, f := range unitFractions {
+		if math.Abs(frac-f.value) < 0.02 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package rewerse
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		q    float64
+		unit string
+		want string
+	}{
+		{250, "g", "250"},
+		{0.5, "", "½"},
+		{1.5, "EL", "1½"},
+		{0.25, "TL", "¼"},
+		{1.0 / 3, "Zehe(n)", "⅓"},
+		{2.0 / 3, "", "⅔"},
+		{2.75, "", "2¾"},
+		{2.4, "", "2,4"},
+		{0.999, "", "1"},
+		{0, "", ""},
+		// metric units use decimals
+		{1.5, "kg", "1,5"},
+		{1.25, "l", "1,25"},
+		{2, "kg", "2"},
+	}
+	for _, tt := range tests {
+		if got := FormatQuantity(tt.q, tt.unit); got != tt.want {
+			t.Errorf("FormatQuantity(%v, %q): expected %q, got %q", tt.q, tt.unit, tt.want, got)
+		}
+	}
+}
+
+func TestConvertQuantity(t *testing.T) {
+	tests := []struct {
+		q        float64
+		from, to string
+		want     float64
+		ok       bool
+	}{
+		{1500, "g", "kg", 1.5, true},
+		{0.5, "l", "ml", 500, true},
+		{2, "EL", "ml", 30, true},
+		{3, "TL", "EL", 1, true},
+		{1, "Esslöffel", "TL", 3, true},
+		{100, "g", "ml", 0, false},
+		{1, "Zehe(n)", "g", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ConvertQuantity(tt.q, tt.from, tt.to)
+		if (err == nil) != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ConvertQuantity(%v, %s, %s): expected %v (ok=%v), got %v (err=%v)", tt.q, tt.from, tt.to, tt.want, tt.ok, got, err)
+		}
+	}
+}
+
+func TestRecipeIngredientString(t *testing.T) {
+	tests := []struct {
+		ing  RecipeIngredient
+		want string
+	}{
+		{RecipeIngredient{Name: "frischer Rosenkohl (ersatzweise TK)", Quantity: 250, Unit: "g"}, "250 g frischer Rosenkohl (ersatzweise TK)"},
+		{RecipeIngredient{Name: "rote Zwiebel", Quantity: 1}, "1 rote Zwiebel"},
+		{RecipeIngredient{Name: "Knoblauch", Quantity: 1, Unit: "Zehe(n)"}, "1 Zehe Knoblauch"},
+		{RecipeIngredient{Name: "Knoblauch", Quantity: 2, Unit: "Zehe(n)"}, "2 Zehen Knoblauch"},
+		{RecipeIngredient{Name: "Thymian", Quantity: 1.5, Unit: "Stiel(e)"}, "1½ Stiele Thymian"},
+		{RecipeIngredient{Name: "Olivenöl", Quantity: 0.5, Unit: "EL"}, "½ EL Olivenöl"},
+		{RecipeIngredient{Name: "Zucker", Quantity: 6, Unit: "TL"}, "2 EL Zucker"},
+		{RecipeIngredient{Name: "Gnocchi (Fertigprodukt, Kühlregal)", Quantity: 1200, Unit: "g"}, "1,2 kg Gnocchi (Fertigprodukt, Kühlregal)"},
+		{RecipeIngredient{Name: "Mehl", Quantity: 1500, Unit: "g"}, "1,5 kg Mehl"},
+		{RecipeIngredient{Name: "Milch", Quantity: 1.25, Unit: "l"}, "1,25 l Milch"},
+		{RecipeIngredient{Name: "Salz", Quantity: 0}, "Salz"},
+	}
+	for _, tt := range tests {
+		if got := tt.ing.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestRecipeDetailScale(t *testing.T) {
+	var res RecipeDetails
+	loadJSONFixture(t, "recipe_details.json", &res)
+	r := res.Recipe
+	if r.Ingredients.Portions != 2 {
+		t.Fatalf("fixture portions: expected 2, got %d", r.Ingredients.Portions)
+	}
+
+	scaled := r.Scale(3)
+	if scaled.Ingredients.Portions != 3 {
+		t.Errorf("portions: expected 3, got %d", scaled.Ingredients.Portions)
+	}
+
+	want := map[string]string{
+		"frischer Rosenkohl (ersatzweise TK)": "375 g frischer Rosenkohl (ersatzweise TK)",
+		"rote Zwiebel":                        "1½ rote Zwiebel",
+		"Knoblauch":                           "1½ Zehen Knoblauch",
+		"Olivenöl":                            "6 EL Olivenöl",
+		"Balsamicoessig":                      "1½ EL Balsamicoessig",
+		"Salz":                                "Salz",
+	}
+	for _, ing := range scaled.Ingredients.Items {
+		if w, ok := want[ing.Name]; ok && ing.String() != w {
+			t.Errorf("expected %q, got %q", w, ing.String())
+		}
+	}
+
+	// the original must be untouched
+	if r.Ingredients.Items[0].Quantity != 250 {
+		t.Errorf("original quantity changed to %v", r.Ingredients.Items[0].Quantity)
+	}
+
+	if q := r.Scale(1).Ingredients.Items[2].Quantity; q != 0.5 {
+		t.Errorf("half clove: expected 0.5, got %v", q)
+	}
+}
+
+func TestRoundQuantity(t *testing.T) {
+	tests := []struct {
+		q    float64
+		unit string
+		want float64
+	}{
+		{166.666, "g", 167},
+		{7.5, "g", 7.5},
+		{0.3333, "", 1.0 / 3},
+		{1.1, "EL", 1.125},
+		{0.01, "TL", 0.125}, // never rounded away
+	}
+	for _, tt := range tests {
+		if got := roundQuantity(tt.q, tt.unit); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("roundQuantity(%v, %q): expected %v, got %v", tt.q, tt.unit, tt.want, got)
+		}
+	}
+}
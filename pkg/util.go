@@ -9,6 +9,8 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -124,7 +126,7 @@ func DoRequest(req *http.Request, dest any) (err error) {
 	}
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncateBody(body, 200))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: truncateBody(body, 200)}
 	}
 
 	if strings.HasPrefix(string(body), "<!DOCTYPE html>") {
@@ -156,7 +158,7 @@ func DoRequestRaw(req *http.Request) ([]byte, error) {
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncateBody(body, 200))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: truncateBody(body, 200)}
 	}
 
 	if strings.HasPrefix(string(body), "<!DOCTYPE html>") {
@@ -166,6 +168,18 @@ func DoRequestRaw(req *http.Request) ([]byte, error) {
 	return body, nil
 }
 
+// HTTPError is returned by DoRequest and DoRequestRaw for responses with status >= 400.
+// Use errors.As to inspect the status code.
+type HTTPError struct {
+	StatusCode int
+	// Body is the (truncated) response body
+	Body string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
 func truncateBody(body []byte, maxLen int) string {
 	if len(body) <= maxLen {
 		return string(body)
@@ -229,6 +243,24 @@ func CloseWithWrap(f io.Closer, e *error) {
 	}
 }
 
+// writeFileAtomic writes data to a temp file next to path and renames it into place, so a
+// crash never leaves a half-written file behind. Missing directories are created with the
+// read permissions of perm plus execute: 0o600 gives 0o700.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), perm|(perm&0o444)>>2); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
 // setCommonHeaders adds tracking headers used by most endpoints.
 // Callers must ensure SetCertificate was called first (buildRequest validates this).
 func setCommonHeaders(req *http.Request) {
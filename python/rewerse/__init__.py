@@ -393,6 +393,7 @@ class Rewerse:
         zip_code: str,
         service_type: str,
         listing_id: str,
+        version: int = 0,
     ) -> dict:
         """
         Remove item from basket.
@@ -403,15 +404,55 @@ class Rewerse:
             zip_code: Customer's postal code
             service_type: "PICKUP" or "DELIVERY"
             listing_id: Product listing ID to remove
+            version: Current basket version for optimistic locking (default: 0)
 
         Returns:
             Updated basket state
         """
         return call(
             "RemoveBasketItem",
-            basket_id, market_id, zip_code, service_type, listing_id
+            basket_id, market_id, zip_code, service_type, listing_id, version
         )
 
+    def get_basket_with_session(self, session: dict) -> dict:
+        """
+        Get current basket state using a stored session.
+
+        Args:
+            session: The session from create_basket or a previous *_with_session call
+
+        Returns:
+            {"session": updated session, "basket": full basket}
+        """
+        return call("GetBasketWithSession", json.dumps(session))
+
+    def set_basket_item_with_session(self, session: dict, listing_id: str, quantity: int) -> dict:
+        """
+        Set item quantity in basket (add/update) using a stored session.
+
+        Args:
+            session: The session from create_basket or a previous *_with_session call
+            listing_id: Product listing ID from search results
+            quantity: Desired quantity (0 removes the item)
+
+        Returns:
+            {"session": updated session, "basket": updated basket}
+        """
+        return call("SetBasketItemQuantityWithSession", json.dumps(session), listing_id, quantity)
+
+    def remove_basket_item_with_session(self, session: dict, listing_id: str) -> dict:
+        """
+        Remove item from basket using a stored session.
+
+        Args:
+            session: The session from create_basket or a previous *_with_session call
+            listing_id: Product listing ID to remove
+
+        Returns:
+            {"session": updated session, "basket": updated basket}
+        """
+        return call("RemoveBasketItemWithSession", json.dumps(session), listing_id)
+
     # --- Delivery ---
 
     def get_bulky_goods_config(
@@ -45,7 +45,10 @@ ffi.cdef("""
     char* CreateBasket(char* marketID, char* zipCode, char* serviceType);
     char* GetBasket(char* basketID, char* marketID, char* zipCode, char* serviceType, int version);
     char* SetBasketItemQuantity(char* basketID, char* marketID, char* zipCode, char* serviceType, char* listingID, int quantity, int version);
-    char* RemoveBasketItem(char* basketID, char* marketID, char* zipCode, char* serviceType, char* listingID);
+    char* RemoveBasketItem(char* basketID, char* marketID, char* zipCode, char* serviceType, char* listingID, int version);
+    char* GetBasketWithSession(char* sessionJSON);
+    char* SetBasketItemQuantityWithSession(char* sessionJSON, char* listingID, int quantity);
+    char* RemoveBasketItemWithSession(char* sessionJSON, char* listingID);
 
     // Delivery
     char* GetBulkyGoodsConfig(char* marketID, char* serviceType);